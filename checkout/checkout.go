@@ -0,0 +1,377 @@
+// Package checkout materializes trees into the worktree and index, moving
+// from the tree HEAD currently points at to a new one.
+package checkout
+
+import (
+	"fmt"
+	"gopract/objects"
+	"gopract/repository"
+	"gopract/staging"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Options controls how a checkout treats local changes.
+type Options struct {
+	Force     bool   // Discard local changes to tracked files instead of refusing
+	Operation string // Named in error messages; defaults to "checkout"
+}
+
+// ConflictError lists the paths a checkout refused to overwrite.
+type ConflictError struct {
+	Operation string   // What was refused, e.g. "checkout" or "merge"
+	Modified  []string // Tracked files with uncommitted changes
+	Untracked []string // Untracked files that the target tree would replace
+}
+
+func (e *ConflictError) Error() string {
+	op := e.Operation
+	if op == "" {
+		op = "checkout"
+	}
+	var b strings.Builder
+	if len(e.Modified) > 0 {
+		b.WriteString("your local changes to the following files would be overwritten by " + op + ":\n")
+		for _, p := range e.Modified {
+			fmt.Fprintf(&b, "\t%s\n", p)
+		}
+	}
+	if len(e.Untracked) > 0 {
+		b.WriteString("the following untracked working tree files would be overwritten by " + op + ":\n")
+		for _, p := range e.Untracked {
+			fmt.Fprintf(&b, "\t%s\n", p)
+		}
+	}
+	if op == "checkout" {
+		b.WriteString("Please commit your changes or stash them before you switch branches.")
+	} else {
+		b.WriteString("Please commit your changes or stash them before you " + op + ".")
+	}
+	return b.String()
+}
+
+// Tree switches the worktree and index from oldTree to newTree. oldTree is
+// empty when HEAD is unborn. Paths that are the same in both trees keep any
+// local changes; paths that differ are only touched when they are clean, so
+// no work is lost unless opts.Force is set.
+func Tree(repo *repository.Repository, oldTree, newTree string, opts Options) error {
+	oldFiles, err := flatten(repo, oldTree)
+	if err != nil {
+		return err
+	}
+	newFiles, err := flatten(repo, newTree)
+	if err != nil {
+		return err
+	}
+	// A crafted tree holding both "a" and "a/b" could write through a symlink
+	for p := range newFiles {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, ok := newFiles[dir]; ok {
+				return fmt.Errorf("path %s is both a file and a directory in the tree", dir)
+			}
+		}
+	}
+
+	index, err := staging.ReadIndex(repo.Worktree)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	current := make(map[string]staging.IndexEntry)
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			if !opts.Force {
+				return fmt.Errorf("you need to resolve your current index first: %s", entry.FilePath)
+			}
+			continue
+		}
+		current[entry.FilePath] = entry
+	}
+
+	// Work out which paths change and make sure that is safe
+	paths := make(map[string]bool)
+	for p := range oldFiles {
+		paths[p] = true
+	}
+	for p := range newFiles {
+		paths[p] = true
+	}
+	if opts.Force {
+		for p := range current {
+			paths[p] = true
+		}
+	}
+
+	trustFileMode := staging.TrustFileMode(repo.Worktree)
+	var changed []string
+	conflicts := &ConflictError{Operation: opts.Operation}
+	for p := range paths {
+		// A crafted tree must not reach outside the worktree or into .git
+		if err := objects.CheckPath(p); err != nil {
+			return err
+		}
+
+		oldEntry, inOld := oldFiles[p]
+		newEntry, inNew := newFiles[p]
+		entry, inIndex := current[p]
+
+		if opts.Force {
+			changed = append(changed, p)
+			continue
+		}
+		if inOld == inNew && oldEntry == newEntry {
+			continue // Unchanged between the trees; keep local state
+		}
+		changed = append(changed, p)
+
+		if inIndex && inNew && matchesTree(entry, newEntry) {
+			continue // Already staged as in the target
+		}
+		if inIndex != inOld || (inIndex && !matchesTree(entry, oldEntry)) {
+			conflicts.Modified = append(conflicts.Modified, p)
+			continue
+		}
+
+		info, err := os.Lstat(worktreePath(repo, p))
+		if err != nil {
+			continue // Missing from the worktree: nothing to lose
+		}
+		if !inIndex {
+			if inNew && !info.IsDir() {
+				conflicts.Untracked = append(conflicts.Untracked, p)
+			}
+			continue
+		}
+		clean, err := staging.FileMatches(repo.Worktree, entry, info, trustFileMode)
+		if err != nil {
+			return err
+		}
+		if !clean {
+			conflicts.Modified = append(conflicts.Modified, p)
+		}
+	}
+	inTheWay, err := checkDirectories(repo, oldFiles, newFiles, current, opts.Force, conflicts)
+	if err != nil {
+		return err
+	}
+	if len(conflicts.Modified)+len(conflicts.Untracked) > 0 {
+		sort.Strings(conflicts.Modified)
+		sort.Strings(conflicts.Untracked)
+		conflicts.Modified = slices.Compact(conflicts.Modified)
+		conflicts.Untracked = slices.Compact(conflicts.Untracked)
+		return conflicts
+	}
+
+	// Remove files first so directories can be replaced by files and back
+	sort.Strings(changed)
+	for _, p := range changed {
+		if _, inNew := newFiles[p]; inNew {
+			continue
+		}
+		if err := removeFile(repo, p); err != nil {
+			return err
+		}
+		delete(current, p)
+	}
+	for _, p := range inTheWay {
+		if err := os.RemoveAll(worktreePath(repo, p)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+	}
+	for _, p := range changed {
+		newEntry, inNew := newFiles[p]
+		if !inNew {
+			continue
+		}
+		if entry, ok := current[p]; ok && !opts.Force && matchesTree(entry, newEntry) {
+			continue
+		}
+		entry, err := writeFile(repo, p, newEntry)
+		if err != nil {
+			return err
+		}
+		current[p] = entry
+	}
+
+	updated := &staging.Index{Version: index.Version}
+	for _, entry := range current {
+		updated.Entries = append(updated.Entries, entry)
+	}
+	sort.Slice(updated.Entries, func(i, j int) bool { return updated.Entries[i].FilePath < updated.Entries[j].FilePath })
+	if err := staging.WriteIndex(repo.Worktree, updated); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// checkDirectories finds what would stop a directory from turning into a
+// file or back: a file can only replace a directory that holds nothing but
+// tracked files about to be removed, and a directory can only replace a
+// file that is itself about to be removed. Anything else in the way is
+// added to conflicts, so that nothing is touched before it is known that
+// the whole checkout can be done. With force, untracked files and
+// directories in the way are returned to be removed instead.
+func checkDirectories(repo *repository.Repository, oldFiles, newFiles map[string]objects.TreeEntry, current map[string]staging.IndexEntry, force bool, conflicts *ConflictError) ([]string, error) {
+	removed := func(p string) bool {
+		_, inOld := oldFiles[p]
+		_, inNew := newFiles[p]
+		_, inIndex := current[p]
+		return !inNew && (inOld || force && inIndex)
+	}
+	var inTheWay []string
+	blocked := func(p string) {
+		if force {
+			inTheWay = append(inTheWay, p)
+		} else if _, tracked := current[p]; tracked {
+			conflicts.Modified = append(conflicts.Modified, p)
+		} else {
+			conflicts.Untracked = append(conflicts.Untracked, p)
+		}
+	}
+
+	checkedDirs := make(map[string]bool)
+	for p, newEntry := range newFiles {
+		fullPath := worktreePath(repo, p)
+		info, err := os.Lstat(fullPath)
+		switch {
+		case err != nil || !info.IsDir() || newEntry.Mode == objects.ModeGitlink:
+		case force:
+			inTheWay = append(inTheWay, p)
+		default:
+			err := filepath.WalkDir(fullPath, func(walked string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					return nil
+				}
+				rel, err := filepath.Rel(repo.Worktree, walked)
+				if err != nil {
+					return err
+				}
+				if rel = filepath.ToSlash(rel); !removed(rel) {
+					blocked(rel)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan %s: %w", p, err)
+			}
+		}
+
+		for dir := path.Dir(p); dir != "." && !checkedDirs[dir]; dir = path.Dir(dir) {
+			checkedDirs[dir] = true
+			info, err := os.Lstat(worktreePath(repo, dir))
+			if err != nil || info.IsDir() || removed(dir) {
+				continue
+			}
+			blocked(dir)
+		}
+	}
+	return inTheWay, nil
+}
+
+// flatten lists the files of a tree, or nothing for an empty tree hash.
+func flatten(repo *repository.Repository, treeHash string) (map[string]objects.TreeEntry, error) {
+	if treeHash == "" {
+		return map[string]objects.TreeEntry{}, nil
+	}
+	return objects.FlattenTree(repo.Worktree, treeHash)
+}
+
+// matchesTree reports whether an index entry records the same blob and mode
+// as a tree entry.
+func matchesTree(entry staging.IndexEntry, treeEntry objects.TreeEntry) bool {
+	return entry.BlobHash == treeEntry.Hash && fmt.Sprintf("%o", entry.Mode) == treeEntry.Mode
+}
+
+// worktreePath converts a slash-separated index path to a worktree path.
+func worktreePath(repo *repository.Repository, p string) string {
+	return filepath.Join(repo.Worktree, filepath.FromSlash(p))
+}
+
+// writeFile writes the blob of a tree entry into the worktree and returns
+// the index entry describing it.
+func writeFile(repo *repository.Repository, p string, treeEntry objects.TreeEntry) (staging.IndexEntry, error) {
+	mode, err := strconv.ParseUint(treeEntry.Mode, 8, 32)
+	if err != nil {
+		return staging.IndexEntry{}, fmt.Errorf("invalid mode %s for %s", treeEntry.Mode, p)
+	}
+
+	fullPath := worktreePath(repo, p)
+	if treeEntry.Mode == objects.ModeGitlink {
+		// Submodules are not checked out; leave an empty directory
+		if err := os.MkdirAll(fullPath, 0755); err != nil {
+			return staging.IndexEntry{}, fmt.Errorf("failed to create %s: %w", p, err)
+		}
+		return staging.IndexEntry{Mode: uint32(mode), BlobHash: treeEntry.Hash, FilePath: p}, nil
+	}
+
+	obj, err := objects.ReadObject(repo.Worktree, treeEntry.Hash)
+	if err != nil {
+		return staging.IndexEntry{}, fmt.Errorf("failed to read blob %s: %w", treeEntry.Hash, err)
+	}
+	blob, ok := obj.(*objects.Blob)
+	if !ok {
+		return staging.IndexEntry{}, fmt.Errorf("object %s is a %s, not a blob", treeEntry.Hash, obj.Type())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return staging.IndexEntry{}, fmt.Errorf("failed to create directory for %s: %w", p, err)
+	}
+	// A directory left where the file belongs holds only untracked files
+	// that Git would also refuse to remove.
+	if info, err := os.Lstat(fullPath); err == nil {
+		if info.IsDir() {
+			return staging.IndexEntry{}, fmt.Errorf("cannot replace directory %s with a file", p)
+		}
+		// Replace rather than overwrite, so that a symlink is not followed
+		// and a new file gets the permissions of the tree entry
+		if err := os.Remove(fullPath); err != nil {
+			return staging.IndexEntry{}, fmt.Errorf("failed to replace %s: %w", p, err)
+		}
+	}
+	switch treeEntry.Mode {
+	case objects.ModeSymlink:
+		if err := os.Symlink(filepath.FromSlash(string(blob.Data)), fullPath); err != nil {
+			return staging.IndexEntry{}, fmt.Errorf("failed to create symlink %s: %w", p, err)
+		}
+	default:
+		perm := os.FileMode(0644)
+		if treeEntry.Mode == objects.ModeExecutable {
+			perm = 0755
+		}
+		if err := os.WriteFile(fullPath, blob.Data, perm); err != nil {
+			return staging.IndexEntry{}, fmt.Errorf("failed to write %s: %w", p, err)
+		}
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return staging.IndexEntry{}, fmt.Errorf("failed to stat %s: %w", p, err)
+	}
+	entry := staging.NewEntry(p, treeEntry.Hash, info)
+	entry.Mode = uint32(mode)
+	return entry, nil
+}
+
+// removeFile deletes a file from the worktree along with any directories
+// that become empty.
+func removeFile(repo *repository.Repository, p string) error {
+	fullPath := worktreePath(repo, p)
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", p, err)
+	}
+	for dir := filepath.Dir(fullPath); dir != repo.Worktree && strings.HasPrefix(dir, repo.Worktree); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
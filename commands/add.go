@@ -1,47 +1,229 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"gopract/ignore"
 	"gopract/objects"
 	"gopract/staging"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// Add adds a file to the staging area of the repository.
-func Add(repoPath, filePath string) error {
-	// Ensure the file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("file %s does not exist", filePath)
-	}
+// AddOptions controls which changes `add` stages.
+type AddOptions struct {
+	All    bool // Stage new, modified and deleted files; without pathspecs, across the whole tree
+	Update bool // Only stage modifications and deletions of files already in the index
+	Force  bool // Also stage files that are ignored
+}
 
+// Add stages the files matched by the given pathspecs, which are relative to
+// the current directory. Directories are added recursively and glob patterns
+// are matched against worktree and index paths alike. Tracked files that no
+// longer exist in the worktree are removed from the index. Untracked files
+// that are ignored are skipped unless Force is set.
+func Add(repoPath string, pathspecs []string, opts AddOptions) error {
 	// Ensure the repository exists
 	gitDir := filepath.Join(repoPath, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
 		return fmt.Errorf("not a Git repository: %s", repoPath)
 	}
 
-	// Read file contents
-	data, err := os.ReadFile(filePath)
+	root, err := filepath.Abs(repoPath)
 	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
 	}
 
-	// Create a Blob object
-	blob := &objects.Blob{Data: data}
+	specs := []string{""}
+	if len(pathspecs) > 0 {
+		if specs, err = resolvePathspecs(root, pathspecs); err != nil {
+			return err
+		}
+	} else if !opts.All && !opts.Update {
+		return errors.New("nothing specified, nothing added")
+	} else {
+		pathspecs = []string{"."}
+	}
 
-	// Write the Blob object to `.git/objects`
-	blobHash, err := objects.WriteObject(blob, repoPath)
+	index, err := staging.ReadIndex(root)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	ignored, err := ignore.New(root)
 	if err != nil {
-		return fmt.Errorf("failed to write blob object for %s: %w", filePath, err)
+		return err
 	}
 
-	// Update the index with the file path and blob hash
-	err = staging.UpdateIndex(repoPath, filePath, blobHash)
+	tracked := make(map[string]bool)
+	trackedDirs := make(map[string]bool)
+	var trackedPaths []string
+	for _, entry := range index.Entries {
+		if !tracked[entry.FilePath] {
+			tracked[entry.FilePath] = true
+			trackedPaths = append(trackedPaths, entry.FilePath)
+			for dir := path.Dir(entry.FilePath); dir != "."; dir = path.Dir(dir) {
+				trackedDirs[dir] = true
+			}
+		}
+	}
+
+	// Ignore rules never apply to tracked files
+	files, err := worktreeFiles(root, func(p string, isDir bool) bool {
+		if opts.Force || tracked[p] || isDir && trackedDirs[p] {
+			return false
+		}
+		return ignored.Ignored(p, isDir)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update index: %w", err)
+		return err
+	}
+
+	// Remember which pathspecs matched something so typos are reported
+	matched := make([]bool, len(specs))
+	selected := func(p string) bool {
+		found := false
+		for i, spec := range specs {
+			if matchPathspec(spec, p) {
+				matched[i] = true
+				found = true
+			}
+		}
+		return found
 	}
 
-	fmt.Printf("Added file %s to staging area\n", filePath)
+	trustFileMode := staging.TrustFileMode(root)
+	changed := false
+	present := make(map[string]bool, len(files))
+	for _, p := range files {
+		present[p] = true
+		if opts.Update && !tracked[p] {
+			continue
+		}
+		if !selected(p) {
+			continue
+		}
+		staged, err := stageFile(root, index, p, trustFileMode)
+		if err != nil {
+			return err
+		}
+		changed = changed || staged
+	}
+
+	var removed []string
+	for _, p := range trackedPaths {
+		if selected(p) && !present[p] {
+			removed = append(removed, p)
+		}
+	}
+
+	var ignoredPaths []string
+	for i, spec := range specs {
+		if matched[i] || spec == "" {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(spec)))
+		if err == nil && !opts.Update && ignored.Ignored(spec, info.IsDir()) {
+			ignoredPaths = append(ignoredPaths, pathspecs[i])
+			continue
+		}
+		return fmt.Errorf("pathspec '%s' did not match any files", pathspecs[i])
+	}
+	if len(ignoredPaths) > 0 {
+		return fmt.Errorf("the following paths are ignored by one of your .gitignore files (use -f if you really want to add them): %s",
+			strings.Join(ignoredPaths, ", "))
+	}
+
+	for _, p := range removed {
+		index.Remove(p)
+		fmt.Printf("Removed file %s from staging area\n", p)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	if err := staging.WriteIndex(root, index); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
 	return nil
 }
+
+// stageFile writes a worktree file or symlink as a blob and records it in
+// the index with its mode, skipping the work when the stat data shows it has
+// not changed. It reports whether the index was modified.
+func stageFile(root string, index *staging.Index, p string, trustFileMode bool) (bool, error) {
+	info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(p)))
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", p, err)
+	}
+	var previous uint32
+	entry, ok := index.Entry(p)
+	if ok {
+		previous = entry.Mode
+	}
+	mode := staging.ModeFor(info, previous, trustFileMode)
+	if ok && entry.StatMatches(info) && entry.Mode == mode {
+		return false, nil
+	}
+
+	data, err := staging.ReadFile(root, p)
+	if err != nil {
+		return false, err
+	}
+	blobHash, err := objects.WriteRawObject(root, "blob", data)
+	if err != nil {
+		return false, fmt.Errorf("failed to write blob object for %s: %w", p, err)
+	}
+
+	unchanged := ok && entry.BlobHash == blobHash && entry.Mode == mode
+	fresh := staging.NewEntry(p, blobHash, info)
+	fresh.Mode = mode
+	if err := index.Add(fresh); err != nil {
+		return false, err
+	}
+	if !unchanged {
+		fmt.Printf("Added file %s to staging area\n", p)
+	}
+	return true, nil
+}
+
+// worktreeFiles lists the regular files and symlinks in the worktree,
+// relative to its root with forward slashes. The `.git` directory, nested
+// repositories and any path for which skip returns true are left out.
+func worktreeFiles(root string, skip func(p string, isDir bool) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if fullPath == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, fullPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == ".git" || skip(rel, true) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(fullPath, ".git")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if (d.Type().IsRegular() || d.Type()&fs.ModeSymlink != 0) && !skip(rel, false) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan worktree: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/merge"
+	"gopract/refs"
+	"os"
+	"path/filepath"
+)
+
+// ListBranches prints every local branch, marking the one HEAD points at.
+func ListBranches(repoPath string) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	current, onBranch, err := refs.CurrentBranch(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if !onBranch {
+		if head, err := refs.Resolve(repoPath, "HEAD"); err == nil {
+			fmt.Printf("* (HEAD detached at %s)\n", head[:7])
+		}
+	}
+
+	branches, err := refs.List(repoPath, "refs/heads/")
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
+	}
+	for _, branch := range branches {
+		marker := " "
+		if onBranch && branch.Name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, refs.ShortName(branch.Name))
+	}
+	return nil
+}
+
+// CreateBranch creates a branch pointing at the commit named by startPoint.
+// An existing branch is only moved when force is set, and never while it is
+// checked out.
+func CreateBranch(repoPath, name, startPoint string, force bool) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	refName := "refs/heads/" + name
+	if !refs.ValidName(refName) {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+
+	message := "branch: Created from " + startPoint
+	if _, err := refs.Resolve(repoPath, refName); err == nil {
+		message = "branch: Reset to " + startPoint
+		if !force {
+			return fmt.Errorf("a branch named '%s' already exists", name)
+		}
+		if current, onBranch, _ := refs.CurrentBranch(repoPath); onBranch && current == refName {
+			return fmt.Errorf("cannot force update the current branch '%s'", name)
+		}
+	}
+
+	sha, err := resolveRevision(repoPath, startPoint)
+	if err != nil {
+		return fmt.Errorf("not a valid object name: '%s'", startPoint)
+	}
+	sha, err = peelToCommit(repoPath, sha)
+	if err != nil {
+		return err
+	}
+
+	if err := refs.Update(repoPath, refName, sha, "", message); err != nil {
+		return fmt.Errorf("failed to create branch: %w", err)
+	}
+
+	fmt.Printf("Created branch %s at %s\n", name, sha[:7])
+	return nil
+}
+
+// RenameBranch renames a branch, moving HEAD along with it when it is the
+// current branch.
+func RenameBranch(repoPath, oldName, newName string, force bool) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	oldRef := "refs/heads/" + oldName
+	newRef := "refs/heads/" + newName
+	if !refs.ValidName(newRef) {
+		return fmt.Errorf("'%s' is not a valid branch name", newName)
+	}
+
+	current, onBranch, err := refs.CurrentBranch(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	isCurrent := onBranch && current == oldRef
+
+	hash, err := refs.Resolve(repoPath, oldRef)
+	unborn := errors.Is(err, refs.ErrNotFound) && isCurrent
+	if err != nil && !unborn {
+		if errors.Is(err, refs.ErrNotFound) {
+			return fmt.Errorf("branch '%s' not found", oldName)
+		}
+		return err
+	}
+
+	if oldRef != newRef {
+		if _, err := refs.Resolve(repoPath, newRef); err == nil {
+			if !force {
+				return fmt.Errorf("a branch named '%s' already exists", newName)
+			}
+			if unborn {
+				// Nothing is renamed, so the branch is simply replaced
+				if err := refs.Delete(repoPath, newRef); err != nil {
+					return fmt.Errorf("failed to replace branch '%s': %w", newName, err)
+				}
+			}
+		}
+		if !unborn {
+			// Any branch at the new name is replaced only if the rename succeeds
+			if err := refs.Rename(repoPath, oldRef, newRef, force); err != nil {
+				return fmt.Errorf("failed to rename branch: %w", err)
+			}
+		}
+	}
+
+	if isCurrent {
+		// The old ref is gone, so HEAD's previous value cannot be looked up
+		if err := refs.SetSymbolicFrom(repoPath, "HEAD", newRef, hash, refs.RenameMessage(oldRef, newRef)); err != nil {
+			return fmt.Errorf("failed to update HEAD: %w", err)
+		}
+	}
+
+	fmt.Printf("Renamed branch %s to %s\n", oldName, newName)
+	return nil
+}
+
+// DeleteBranch removes a branch. Unless force is set, the branch must be
+// fully merged into HEAD so no commits are lost.
+func DeleteBranch(repoPath, name string, force bool) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	refName := "refs/heads/" + name
+	sha, err := refs.Resolve(repoPath, refName)
+	if err != nil {
+		if errors.Is(err, refs.ErrNotFound) {
+			return fmt.Errorf("branch '%s' not found", name)
+		}
+		return err
+	}
+
+	if current, onBranch, _ := refs.CurrentBranch(repoPath); onBranch && current == refName {
+		return fmt.Errorf("cannot delete branch '%s' checked out at '%s'", name, repoPath)
+	}
+
+	if !force {
+		head, err := refs.Resolve(repoPath, "HEAD")
+		merged := false
+		if err == nil {
+			if merged, err = merge.IsAncestor(repoPath, sha, head); err != nil {
+				return err
+			}
+		}
+		if !merged {
+			return fmt.Errorf("the branch '%s' is not fully merged; use -D to delete it anyway", name)
+		}
+	}
+
+	if err := refs.Delete(repoPath, refName); err != nil {
+		return fmt.Errorf("failed to delete branch: %w", err)
+	}
+
+	fmt.Printf("Deleted branch %s (was %s).\n", name, sha[:7])
+	return nil
+}
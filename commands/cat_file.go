@@ -5,16 +5,23 @@ import (
 	"gopract/objects"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-// CatFile retrieves and displays the raw content of a Git object.
-func CatFile(repoPath, sha string) error {
+// CatFile retrieves and displays the raw content of a Git object. The object
+// may be named by any revision, e.g. an abbreviated SHA or "HEAD:README.md".
+func CatFile(repoPath, rev string) error {
 	// Ensure the repository exists
 	gitDir := filepath.Join(repoPath, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
 		return fmt.Errorf("not a Git repository: %s", repoPath)
 	}
 
+	sha, err := resolveRevision(repoPath, rev)
+	if err != nil {
+		return err
+	}
+
 	// Read the object from the `.git/objects` directory
 	obj, err := objects.ReadObject(repoPath, sha)
 	if err != nil {
@@ -36,7 +43,22 @@ func CatFile(repoPath, sha string) error {
 			fmt.Printf("parent %s\n", parent)
 		}
 		fmt.Printf("author %s\n", commit.Author)
+		if !commit.Committer.IsZero() {
+			fmt.Printf("committer %s\n", commit.Committer)
+		}
+		for _, h := range commit.ExtraHeaders {
+			fmt.Printf("%s %s\n", h.Key, strings.ReplaceAll(h.Value, "\n", "\n "))
+		}
 		fmt.Printf("\n%s\n", commit.Message)
+	case "tag":
+		tag := obj.(*objects.Tag)
+		fmt.Printf("object %s\n", tag.Object)
+		fmt.Printf("type %s\n", tag.ObjectType)
+		fmt.Printf("tag %s\n", tag.Name)
+		if tag.Tagger != nil {
+			fmt.Printf("tagger %s\n", tag.Tagger)
+		}
+		fmt.Printf("\n%s\n", tag.Message)
 	default:
 		return fmt.Errorf("unknown object type: %s", obj.Type())
 	}
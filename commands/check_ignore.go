@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/ignore"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckIgnoreOptions controls the output of `check-ignore`.
+type CheckIgnoreOptions struct {
+	Verbose     bool // Show the rule that decided each path, including negated ones
+	NonMatching bool // With Verbose, also list the paths no rule matched
+}
+
+// CheckIgnore prints those of the given paths, relative to the current
+// directory, that are ignored. In verbose mode each line is prefixed with
+// the source, line number and pattern of the deciding rule. Tracked files
+// are never reported, since ignore rules do not apply to them. It reports
+// whether any path was ignored.
+func CheckIgnore(repoPath string, paths []string, opts CheckIgnoreOptions) (bool, error) {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return false, fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+	if len(paths) == 0 {
+		return false, fmt.Errorf("no path specified")
+	}
+
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	specs, err := resolvePathspecs(root, paths)
+	if err != nil {
+		return false, err
+	}
+	index, err := staging.ReadIndex(root)
+	if err != nil {
+		return false, fmt.Errorf("failed to read index: %w", err)
+	}
+	matcher, err := ignore.New(root)
+	if err != nil {
+		return false, err
+	}
+
+	anyIgnored := false
+	for i, p := range specs {
+		if p == "" {
+			continue
+		}
+		if isTracked(index, p) {
+			continue
+		}
+		isDir := strings.HasSuffix(paths[i], "/")
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(p))); err == nil {
+			isDir = info.IsDir()
+		}
+
+		rule := matcher.Match(p, isDir)
+		ignored := rule != nil && !rule.Negate
+		anyIgnored = anyIgnored || ignored
+		switch {
+		case opts.Verbose && rule != nil:
+			fmt.Printf("%s:%d:%s\t%s\n", rule.Source, rule.Line, rule.Pattern, paths[i])
+		case opts.Verbose && opts.NonMatching:
+			fmt.Printf("::\t%s\n", paths[i])
+		case ignored:
+			fmt.Println(paths[i])
+		}
+	}
+	return anyIgnored, nil
+}
+
+// isTracked reports whether the index has an entry, at any stage, for a path.
+func isTracked(index *staging.Index, p string) bool {
+	for _, entry := range index.Entries {
+		if entry.FilePath == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/checkout"
+	"gopract/refs"
+	"gopract/repository"
+	"gopract/revparse"
+	"strconv"
+	"strings"
+)
+
+// CheckoutOptions controls how `checkout` and `switch` move HEAD.
+type CheckoutOptions struct {
+	NewBranch   string // Create this branch at the target and switch to it
+	ResetBranch bool   // Allow NewBranch to replace an existing branch (-B / -C)
+	Detach      bool   // Detach HEAD at the target even if it names a branch
+	Force       bool   // Throw away local changes to tracked files
+}
+
+// Checkout updates the worktree and index to match target and moves HEAD:
+// onto the branch when target names one, or detached at the commit otherwise.
+func Checkout(repoPath, target string, opts CheckoutOptions) error {
+	repo, err := repository.NewRepository(repoPath, false)
+	if err != nil {
+		return err
+	}
+
+	if target == "" {
+		target = "HEAD"
+	}
+	if target, err = previousBranch(repoPath, target); err != nil {
+		return err
+	}
+
+	current, onBranch, err := refs.CurrentBranch(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	// Decide where HEAD ends up before touching any files
+	branch := ""
+	if opts.NewBranch != "" {
+		branch = "refs/heads/" + opts.NewBranch
+		if !refs.ValidName(branch) {
+			return fmt.Errorf("'%s' is not a valid branch name", opts.NewBranch)
+		}
+		if _, err := refs.Resolve(repoPath, branch); err == nil && !opts.ResetBranch {
+			return fmt.Errorf("a branch named '%s' already exists", opts.NewBranch)
+		}
+	} else if target == "HEAD" && onBranch && !opts.Detach {
+		branch = current
+	} else if !opts.Detach {
+		if _, err := refs.Resolve(repoPath, "refs/heads/"+target); err == nil {
+			branch = "refs/heads/" + target
+		}
+	}
+
+	oldCommit, oldTree, err := headTree(repoPath)
+	if err != nil {
+		return err
+	}
+
+	var newCommit, newTree string
+	if target == "HEAD" && oldCommit == "" {
+		// Branching off an unborn HEAD: nothing to check out
+		if opts.NewBranch == "" {
+			return fmt.Errorf("you are on a branch yet to be born")
+		}
+	} else {
+		sha, err := resolveRevision(repoPath, target)
+		if err != nil {
+			return fmt.Errorf("pathspec '%s' did not match any revision", target)
+		}
+		if newCommit, err = peelToCommit(repoPath, sha); err != nil {
+			return err
+		}
+		commit, err := readCommit(repoPath, newCommit)
+		if err != nil {
+			return err
+		}
+		newTree = commit.Tree
+	}
+
+	if oldTree != newTree || opts.Force {
+		if err := checkout.Tree(repo, oldTree, newTree, checkout.Options{Force: opts.Force}); err != nil {
+			return err
+		}
+	}
+
+	// Move HEAD, logging where from in the form `@{-n}` looks for
+	from := refs.ShortName(current)
+	if !onBranch {
+		from = oldCommit
+	}
+	switch {
+	case opts.NewBranch != "":
+		if newCommit != "" {
+			message := "branch: Created from " + target
+			if _, err := refs.Resolve(repoPath, branch); err == nil {
+				message = "branch: Reset to " + target
+			}
+			if err := refs.Update(repoPath, branch, newCommit, "", message); err != nil {
+				return fmt.Errorf("failed to create branch: %w", err)
+			}
+		}
+		if err := refs.SetSymbolic(repoPath, "HEAD", branch, "checkout: moving from "+from+" to "+opts.NewBranch); err != nil {
+			return fmt.Errorf("failed to update HEAD: %w", err)
+		}
+		fmt.Printf("Switched to a new branch '%s'\n", opts.NewBranch)
+	case branch != "":
+		if onBranch && current == branch {
+			fmt.Printf("Already on '%s'\n", refs.ShortName(branch))
+			return nil
+		}
+		if err := refs.SetSymbolic(repoPath, "HEAD", branch, "checkout: moving from "+from+" to "+target); err != nil {
+			return fmt.Errorf("failed to update HEAD: %w", err)
+		}
+		fmt.Printf("Switched to branch '%s'\n", target)
+	default:
+		if err := detachHead(repoPath, newCommit, "checkout: moving from "+from+" to "+target); err != nil {
+			return err
+		}
+		commit, err := readCommit(repoPath, newCommit)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD is now at %s %s\n", newCommit[:7], firstLine(commit.Message))
+	}
+	return nil
+}
+
+// Switch is the stricter form of Checkout: the target must be a branch
+// unless detaching is requested explicitly.
+func Switch(repoPath, target string, opts CheckoutOptions) error {
+	target, err := previousBranch(repoPath, target)
+	if err != nil {
+		return err
+	}
+	if opts.NewBranch == "" && !opts.Detach {
+		if _, err := refs.Resolve(repoPath, "refs/heads/"+target); err != nil {
+			if errors.Is(err, refs.ErrNotFound) {
+				return fmt.Errorf("a branch is expected, got '%s'; use --detach to check out a commit", target)
+			}
+			return err
+		}
+	}
+	return Checkout(repoPath, target, opts)
+}
+
+// previousBranch expands "-" and "@{-n}" to the branch (or detached commit)
+// checked out before, so that switching back lands on the branch rather
+// than detaching at its commit. Other targets are returned unchanged.
+func previousBranch(repoPath, target string) (string, error) {
+	if target == "-" {
+		target = "@{-1}"
+	}
+	spec, ok := strings.CutPrefix(target, "@{-")
+	if !ok || !strings.HasSuffix(spec, "}") {
+		return target, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(spec, "}"))
+	if err != nil || n <= 0 {
+		return target, nil
+	}
+	return revparse.PreviousCheckout(repoPath, n)
+}
+
+// headTree returns the commit and tree HEAD points at, or empty strings when
+// HEAD is unborn.
+func headTree(repoPath string) (string, string, error) {
+	head, err := refs.Resolve(repoPath, "HEAD")
+	if errors.Is(err, refs.ErrNotFound) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	commit, err := readCommit(repoPath, head)
+	if err != nil {
+		return "", "", err
+	}
+	return head, commit.Tree, nil
+}
+
+// detachHead points HEAD directly at a commit, logging message.
+func detachHead(repoPath, commitHash, message string) error {
+	if err := refs.UpdateNoDeref(repoPath, "HEAD", commitHash, message); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+	return nil
+}
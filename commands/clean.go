@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/config"
+	"gopract/ignore"
+	"gopract/staging"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CleanOptions controls which untracked paths `clean` removes.
+type CleanOptions struct {
+	DryRun      bool // Only list what would be removed
+	Force       bool // Required to remove anything unless clean.requireForce is false
+	Directories bool // Also remove untracked directories
+	Ignored     bool // Also remove ignored files (-x)
+	OnlyIgnored bool // Remove only ignored files (-X)
+}
+
+// cleaner collects the paths `clean` should remove.
+type cleaner struct {
+	root        string
+	opts        CleanOptions
+	specs       []string
+	tracked     map[string]bool
+	trackedDirs map[string]bool
+	ignored     *ignore.Matcher
+	removals    []string // Worktree paths; directories end in "/"
+}
+
+// Clean removes untracked files, restricted to the given pathspecs (relative
+// to the current directory) when there are any. Ignored files are kept
+// unless opts.Ignored or opts.OnlyIgnored is set, and untracked directories
+// are only removed with opts.Directories.
+func Clean(repoPath string, pathspecs []string, opts CleanOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	if !opts.Force && !opts.DryRun {
+		if _, set := config.ReadValue(repoPath, "clean", "requireForce"); !set || config.ReadBool(repoPath, "clean", "requireForce") {
+			return errors.New("clean.requireForce defaults to true and neither -n nor -f given; refusing to clean")
+		}
+	}
+	if opts.Ignored && opts.OnlyIgnored {
+		return errors.New("-x and -X cannot be used together")
+	}
+
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	c := &cleaner{root: root, opts: opts, specs: []string{""}, tracked: make(map[string]bool), trackedDirs: make(map[string]bool)}
+	if len(pathspecs) > 0 {
+		if c.specs, err = resolvePathspecs(root, pathspecs); err != nil {
+			return err
+		}
+	}
+
+	index, err := staging.ReadIndex(root)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	for _, entry := range index.Entries {
+		c.tracked[entry.FilePath] = true
+		for dir := path.Dir(entry.FilePath); dir != "."; dir = path.Dir(dir) {
+			c.trackedDirs[dir] = true
+		}
+	}
+	if c.ignored, err = ignore.New(root); err != nil {
+		return err
+	}
+
+	if _, err := c.collect(""); err != nil {
+		return err
+	}
+
+	for _, p := range c.removals {
+		if opts.DryRun {
+			fmt.Printf("Would remove %s\n", p)
+			continue
+		}
+		fmt.Printf("Removing %s\n", p)
+		if err := os.RemoveAll(filepath.Join(root, filepath.FromSlash(strings.TrimSuffix(p, "/")))); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// collect records the removable paths in a worktree directory and reports
+// whether everything in it can go.
+func (c *cleaner) collect(dir string) (bool, error) {
+	entries, err := os.ReadDir(filepath.Join(c.root, filepath.FromSlash(dir)))
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	whole := true
+	for _, e := range entries {
+		p := path.Join(dir, e.Name())
+		if !e.IsDir() {
+			if c.tracked[p] || !c.removable(p, false) {
+				whole = false
+				continue
+			}
+			c.removals = append(c.removals, p)
+			continue
+		}
+
+		if e.Name() == ".git" || c.trackedDirs[p] || c.tracked[p] || c.nestedRepository(p) {
+			whole = false
+			if e.Name() != ".git" && c.trackedDirs[p] {
+				if _, err := c.collect(p); err != nil {
+					return false, err
+				}
+			}
+			continue
+		}
+
+		// An untracked directory is removed as a whole when everything in
+		// it may be removed, and otherwise file by file. Without -d that is
+		// only done for directories a pathspec points at; other ones are
+		// only searched for ignored files (-X).
+		dirsAllowed := c.opts.Directories || c.named(p)
+		if !dirsAllowed && !c.leadsInto(p) && !c.opts.OnlyIgnored {
+			whole = false
+			continue
+		}
+		if c.ignored.Ignored(p, true) {
+			if dirsAllowed && c.removable(p, true) {
+				c.removals = append(c.removals, p+"/")
+			} else {
+				whole = false
+			}
+			continue
+		}
+		mark := len(c.removals)
+		subWhole, err := c.collect(p)
+		if err != nil {
+			return false, err
+		}
+		// With -X a directory only goes when it held ignored files; an empty
+		// one is not ignored
+		collected := len(c.removals) > mark
+		switch {
+		case subWhole && dirsAllowed && (collected || c.inScope(p) && !c.opts.OnlyIgnored):
+			c.removals = append(c.removals[:mark], p+"/")
+		case subWhole && !dirsAllowed && c.opts.OnlyIgnored:
+			// Holding nothing but ignored files makes it an ignored
+			// directory, which is kept without -d
+			c.removals = c.removals[:mark]
+			whole = false
+		default:
+			whole = false
+		}
+	}
+	return whole, nil
+}
+
+// removable reports whether an untracked path may be removed given the
+// pathspecs and how ignored paths are treated.
+func (c *cleaner) removable(p string, isDir bool) bool {
+	if !c.inScope(p) {
+		return false
+	}
+	ignored := c.ignored.Ignored(p, isDir)
+	if c.opts.OnlyIgnored {
+		return ignored
+	}
+	return !ignored || c.opts.Ignored
+}
+
+// inScope reports whether a path is selected by the pathspecs.
+func (c *cleaner) inScope(p string) bool {
+	for _, spec := range c.specs {
+		if matchPathspec(spec, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// named reports whether a pathspec other than the whole tree selects a
+// directory. As in Git, a glob such as "dir/*" also names dir itself.
+func (c *cleaner) named(dir string) bool {
+	for _, spec := range c.specs {
+		if spec != "" && (matchPathspec(spec, dir) || hasGlob(spec) && wildmatch(spec, dir+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadsInto reports whether a pathspec selects something inside a
+// directory, so that it has to be searched even without -d.
+func (c *cleaner) leadsInto(dir string) bool {
+	for _, spec := range c.specs {
+		if spec != "" && (strings.HasPrefix(spec, dir+"/") || hasGlob(spec)) {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedRepository reports whether a directory is the worktree of another
+// repository, which `clean` never removes.
+func (c *cleaner) nestedRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(c.root, filepath.FromSlash(dir), ".git"))
+	return err == nil
+}
@@ -1,15 +1,22 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"gopract/config"
 	"gopract/objects"
+	"gopract/refs"
 	"gopract/staging"
 	"os"
-	"time"
+	"path/filepath"
+	"strings"
 )
 
-// Commit creates a new commit object and updates the repository state.
-func Commit(repoPath, message string) error {
+// Commit creates a new commit object and updates the repository state. While
+// a merge is in progress the merged commit becomes the second parent, and an
+// empty message falls back to the prepared merge message. The commit is
+// signed when sign is set or `commit.gpgsign` is enabled.
+func Commit(repoPath, message string, sign bool) error {
 	// Ensure the repository exists
 	gitDir := fmt.Sprintf("%s/.git", repoPath)
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
@@ -22,55 +29,164 @@ func Commit(repoPath, message string) error {
 		return fmt.Errorf("failed to read index: %w", err)
 	}
 
-	// Create a tree object from the index
-	tree := &objects.Tree{}
-	for filePath, blobHash := range index {
-		tree.Entries = append(tree.Entries, objects.TreeEntry{
-			Mode: "100644", // Regular file mode
-			Hash: blobHash,
-			Name: filePath,
-		})
-	}
-
-	// Write the tree object to `.git/objects`
-	treeHash, err := objects.WriteObject(tree, repoPath)
+	// Write one tree per directory from the index
+	treeHash, err := staging.WriteTree(repoPath, index)
 	if err != nil {
 		return fmt.Errorf("failed to write tree object: %w", err)
 	}
 
 	// Determine the parent commit (if any)
 	var parents []string
-	headPath := fmt.Sprintf("%s/HEAD", gitDir)
-	head, err := os.ReadFile(headPath)
+	parentHash, err := refs.Resolve(repoPath, "HEAD")
+	if err == nil {
+		parents = append(parents, parentHash)
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		return fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	// Conclude a merge in progress
+	mergeHead, err := refs.Resolve(repoPath, "MERGE_HEAD")
 	if err == nil {
-		// Read the current HEAD, if it exists
-		ref := string(head)
-		refPath := fmt.Sprintf("%s/%s", gitDir, ref)
-		if parentHash, err := os.ReadFile(refPath); err == nil {
-			parents = append(parents, string(parentHash))
+		parents = append(parents, mergeHead)
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		return fmt.Errorf("failed to read MERGE_HEAD: %w", err)
+	}
+	if message == "" {
+		message = readMergeMessage(gitDir)
+	}
+	if cleanupMessage(message) == "" {
+		return fmt.Errorf("aborting commit due to empty commit message")
+	}
+
+	commit := &objects.Commit{Tree: treeHash, Parents: parents, Message: message}
+
+	// Concluding a cherry-pick keeps the author of the picked commit
+	pickedHash, err := refs.Resolve(repoPath, "CHERRY_PICK_HEAD")
+	cherryPicking := err == nil
+	if cherryPicking {
+		picked, err := readCommit(repoPath, pickedHash)
+		if err != nil {
+			return fmt.Errorf("failed to read CHERRY_PICK_HEAD: %w", err)
 		}
+		commit.Author = picked.Author
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		return fmt.Errorf("failed to read CHERRY_PICK_HEAD: %w", err)
+	}
+	action := "commit"
+	switch {
+	case len(parents) == 0:
+		action = "commit (initial)"
+	case len(parents) > 1:
+		action = "commit (merge)"
+	case cherryPicking:
+		action = "commit (cherry-pick)"
+	}
+	reflog := action + ": " + firstLine(cleanupMessage(message))
+	commitHash, err := writeCommit(repoPath, commit, sign, reflog)
+	if err != nil {
+		return err
 	}
 
-	// Create a new commit object
-	commit := &objects.Commit{
-		Tree:    treeHash,
-		Parents: parents,
-		Author:  fmt.Sprintf("Your Name <your.email@example.com> %d +0000", time.Now().Unix()), // Example author
-		Message: message,
+	// The merge, cherry-pick or revert, if any, is now recorded
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "CHERRY_PICK_HEAD", "REVERT_HEAD"} {
+		os.Remove(filepath.Join(gitDir, name))
+	}
+
+	fmt.Printf("Committed with hash %s\n", commitHash)
+	return nil
+}
+
+// writeCommit stores a commit object (see storeCommit) and advances the
+// branch HEAD points at, or HEAD itself when detached, logging reflog as the
+// reason. The first parent must be the commit HEAD currently resolves to.
+// The commit is signed when sign is set or `commit.gpgsign` is enabled.
+func writeCommit(repoPath string, commit *objects.Commit, sign bool, reflog string) (string, error) {
+	commitHash, err := storeCommit(repoPath, commit, sign)
+	if err != nil {
+		return "", err
+	}
+
+	// Only move HEAD if nobody else has moved it in the meantime
+	oldHash := refs.ZeroHash
+	if len(commit.Parents) > 0 {
+		oldHash = commit.Parents[0]
+	}
+	if err := refs.Update(repoPath, "HEAD", commitHash, oldHash, reflog); err != nil {
+		return "", fmt.Errorf("failed to update HEAD: %w", err)
+	}
+	return commitHash, nil
+}
+
+// storeCommit fills in the committer, the author unless one is already set
+// (as when cherry-picking) and the cleaned-up message, then writes the
+// commit object without moving any ref. The commit is signed when sign is
+// set or `commit.gpgsign` is enabled.
+func storeCommit(repoPath string, commit *objects.Commit, sign bool) (string, error) {
+	if commit.Author.IsZero() {
+		author, err := newSignature(repoPath, roleAuthor)
+		if err != nil {
+			return "", err
+		}
+		commit.Author = author
+	}
+	committer, err := newSignature(repoPath, roleCommitter)
+	if err != nil {
+		return "", err
+	}
+	commit.Committer = committer
+	commit.Message = cleanupMessage(commit.Message)
+
+	if sign || config.ReadBool(repoPath, "commit", "gpgsign") {
+		if err := signCommit(repoPath, commit); err != nil {
+			return "", fmt.Errorf("failed to sign commit: %w", err)
+		}
 	}
 
 	// Write the commit object to `.git/objects`
 	commitHash, err := objects.WriteObject(commit, repoPath)
 	if err != nil {
-		return fmt.Errorf("failed to write commit object: %w", err)
+		return "", fmt.Errorf("failed to write commit object: %w", err)
 	}
+	return commitHash, nil
+}
 
-	// Update the HEAD to point to the new commit
-	refPath := fmt.Sprintf("%s/refs/heads/master", gitDir)
-	if err := os.WriteFile(refPath, []byte(commitHash), 0644); err != nil {
-		return fmt.Errorf("failed to update HEAD: %w", err)
+// readMergeMessage returns the prepared `.git/MERGE_MSG` without its comment
+// lines, or an empty string when there is none.
+func readMergeMessage(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "MERGE_MSG"))
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
 	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
 
-	fmt.Printf("Committed with hash %s\n", commitHash)
-	return nil
+// cleanupMessage normalizes a message the way `git commit -m` does: trailing
+// whitespace is stripped from every line, runs of blank lines are collapsed,
+// leading and trailing blank lines are dropped and the message ends with a
+// single newline.
+func cleanupMessage(message string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
 }
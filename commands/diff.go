@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/diff"
+	"gopract/objects"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+)
+
+// Output formats supported by `diff`.
+const (
+	DiffPatch      = "patch"
+	DiffStat       = "stat"
+	DiffNameOnly   = "name-only"
+	DiffNameStatus = "name-status"
+)
+
+// DiffOptions selects what `diff` compares and how it reports it.
+type DiffOptions struct {
+	Cached    bool     // Compare a commit (HEAD by default) with the index
+	Revisions []string // Zero, one or two commits, trees or blobs
+	Paths     []string // Only report changes at or below these paths
+	Format    string   // One of the Diff* formats
+	Context   int      // Lines of context in patches
+	Algorithm diff.Algorithm
+}
+
+// diffSnapshot is one side of a comparison. Worktree snapshots read file
+// contents from disk rather than from the object database.
+type diffSnapshot struct {
+	files    map[string]diff.File
+	worktree bool
+}
+
+// Diff shows changes between the index and the worktree, a commit and the
+// index, a commit and the worktree, two commits or trees, or two blobs.
+func Diff(repoPath string, opts DiffOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	diffOpts := diff.Options{Context: opts.Context, Algorithm: opts.Algorithm}
+
+	var oldSide, newSide *diffSnapshot
+	var err error
+	switch {
+	case len(opts.Revisions) == 2:
+		oldHash, err := resolveRevision(repoPath, opts.Revisions[0])
+		if err != nil {
+			return err
+		}
+		newHash, err := resolveRevision(repoPath, opts.Revisions[1])
+		if err != nil {
+			return err
+		}
+
+		// Two blobs are compared directly
+		oldType, oldData, err := objects.ReadRawObject(repoPath, oldHash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %w", oldHash, err)
+		}
+		newType, newData, err := objects.ReadRawObject(repoPath, newHash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %w", newHash, err)
+		}
+		if oldType == "blob" && newType == "blob" {
+			return diffBlobs(oldHash, newHash, oldData, newData, opts.Format, diffOpts)
+		}
+
+		if oldSide, err = treeSnapshot(repoPath, oldHash); err != nil {
+			return err
+		}
+		if newSide, err = treeSnapshot(repoPath, newHash); err != nil {
+			return err
+		}
+	case opts.Cached:
+		rev := "HEAD"
+		if len(opts.Revisions) == 1 {
+			rev = opts.Revisions[0]
+		}
+		if oldSide, err = revisionSnapshot(repoPath, rev); err != nil {
+			return err
+		}
+		if newSide, err = indexSnapshot(repoPath); err != nil {
+			return err
+		}
+	case len(opts.Revisions) == 1:
+		if oldSide, err = revisionSnapshot(repoPath, opts.Revisions[0]); err != nil {
+			return err
+		}
+		if newSide, err = worktreeSnapshot(repoPath); err != nil {
+			return err
+		}
+	default:
+		if oldSide, err = indexSnapshot(repoPath); err != nil {
+			return err
+		}
+		if newSide, err = worktreeSnapshot(repoPath); err != nil {
+			return err
+		}
+	}
+
+	changes := diff.FilterPaths(diff.Compare(oldSide.files, newSide.files), opts.Paths)
+
+	switch opts.Format {
+	case DiffNameOnly:
+		diff.WriteNameOnly(os.Stdout, changes)
+		return nil
+	case DiffNameStatus:
+		diff.WriteNameStatus(os.Stdout, changes)
+		return nil
+	}
+
+	var stats []diff.FileStat
+	for _, c := range changes {
+		oldData, err := snapshotData(repoPath, oldSide, c.Old)
+		if err != nil {
+			return err
+		}
+		newData, err := snapshotData(repoPath, newSide, c.New)
+		if err != nil {
+			return err
+		}
+
+		if opts.Format == DiffStat {
+			stats = append(stats, diff.StatFor(c.Path, oldData, newData, diffOpts))
+			continue
+		}
+		if err := diff.WritePatch(os.Stdout, c, oldData, newData, diffOpts); err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+	}
+
+	if opts.Format == DiffStat && len(stats) > 0 {
+		diff.WriteStat(os.Stdout, stats)
+	}
+	return nil
+}
+
+// diffBlobs compares two blobs named on the command line.
+func diffBlobs(oldHash, newHash string, oldData, newData []byte, format string, opts diff.Options) error {
+	switch format {
+	case DiffNameOnly, DiffNameStatus:
+		if oldHash != newHash {
+			fmt.Println(newHash)
+		}
+		return nil
+	case DiffStat:
+		if oldHash != newHash {
+			diff.WriteStat(os.Stdout, []diff.FileStat{diff.StatFor(newHash, oldData, newData, opts)})
+		}
+		return nil
+	}
+
+	if oldHash == newHash {
+		return nil
+	}
+	fmt.Printf("diff --git a/%s b/%s\n", oldHash, newHash)
+	fmt.Printf("index %s..%s %s\n", oldHash[:7], newHash[:7], objects.ModeFile)
+	if diff.IsBinary(oldData) || diff.IsBinary(newData) {
+		fmt.Printf("Binary files a/%s and b/%s differ\n", oldHash, newHash)
+		return nil
+	}
+	fmt.Printf("--- a/%s\n+++ b/%s\n", oldHash, newHash)
+	return diff.WriteHunks(os.Stdout, oldData, newData, opts)
+}
+
+// revisionSnapshot flattens the tree of a commit, tag or tree.
+func revisionSnapshot(repoPath, rev string) (*diffSnapshot, error) {
+	hash, err := resolveRevision(repoPath, rev)
+	if err != nil {
+		// An unborn HEAD compares as an empty tree
+		if rev == "HEAD" {
+			return &diffSnapshot{files: map[string]diff.File{}}, nil
+		}
+		return nil, err
+	}
+	return treeSnapshot(repoPath, hash)
+}
+
+// treeSnapshot flattens the tree behind a commit, tag or tree hash.
+func treeSnapshot(repoPath, hash string) (*diffSnapshot, error) {
+	treeHash, err := peelToTree(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	files, err := diff.TreeFiles(repoPath, treeHash)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSnapshot{files: files}, nil
+}
+
+// indexSnapshot lists the stage 0 entries of the index.
+func indexSnapshot(repoPath string) (*diffSnapshot, error) {
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	files := make(map[string]diff.File)
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			continue
+		}
+		files[entry.FilePath] = diff.File{Path: entry.FilePath, Mode: fmt.Sprintf("%o", entry.Mode), Hash: entry.BlobHash}
+	}
+	return &diffSnapshot{files: files}, nil
+}
+
+// worktreeSnapshot describes the worktree copies of every tracked file.
+func worktreeSnapshot(repoPath string) (*diffSnapshot, error) {
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	trustFileMode := staging.TrustFileMode(repoPath)
+	files := make(map[string]diff.File)
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(repoPath, filepath.FromSlash(entry.FilePath)))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		hash := entry.BlobHash
+		if !entry.StatMatches(info) {
+			if hash, err = staging.HashFile(repoPath, entry.FilePath); err != nil {
+				return nil, err
+			}
+		}
+		mode := staging.ModeFor(info, entry.Mode, trustFileMode)
+		files[entry.FilePath] = diff.File{Path: entry.FilePath, Mode: fmt.Sprintf("%o", mode), Hash: hash}
+	}
+	return &diffSnapshot{files: files, worktree: true}, nil
+}
+
+// snapshotData loads the contents of one side of a change.
+func snapshotData(repoPath string, side *diffSnapshot, file diff.File) ([]byte, error) {
+	if file.Hash == "" {
+		return nil, nil
+	}
+	if side.worktree {
+		return staging.ReadFile(repoPath, file.Path)
+	}
+	obj, err := objects.ReadObject(repoPath, file.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", file.Hash, err)
+	}
+	blob, ok := obj.(*objects.Blob)
+	if !ok {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", file.Hash, obj.Type())
+	}
+	return blob.Data, nil
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/objects"
+	"gopract/reachability"
+	"gopract/refs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FsckOptions controls what `fsck` reports besides corruption.
+type FsckOptions struct {
+	Unreachable bool // List every unreachable object, not just dangling ones
+	NoDangling  bool // Do not list dangling objects
+}
+
+// fsckObject is an object that was read and hashed successfully.
+type fsckObject struct {
+	Type  string
+	Links []objects.Link // Empty when the object is malformed
+}
+
+// Fsck verifies the integrity of the object database: every loose and packed
+// object must hash to its name and be well formed, and everything reachable
+// from refs, the index and reflogs must exist. Objects that cannot be reached
+// are reported as dangling or unreachable, which is not an error. An error
+// is returned when any problem was found.
+func Fsck(repoPath string, opts FsckOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	problems := 0
+	found := make(map[string]*fsckObject)
+	record := func(sha, objType string, data []byte) {
+		if _, ok := found[sha]; ok {
+			return
+		}
+		obj := &fsckObject{Type: objType}
+		links, err := objects.CheckObject(objType, data)
+		if err != nil {
+			fmt.Printf("error in %s %s: %v\n", objType, sha, err)
+			problems++
+		} else {
+			obj.Links = links
+		}
+		found[sha] = obj
+	}
+
+	// Re-hash every loose object
+	loose, err := objects.ListLooseObjects(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	for _, sha := range loose {
+		objType, data, err := objects.VerifyLooseObject(repoPath, sha)
+		if err != nil {
+			fmt.Printf("error: %s: %v\n", sha, err)
+			problems++
+			continue
+		}
+		record(sha, objType, data)
+	}
+
+	// And every packed one
+	packs, err := objects.OpenPackfiles(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open packfiles: %w", err)
+	}
+	for _, pack := range packs {
+		if err := pack.VerifyChecksum(); err != nil {
+			fmt.Printf("error: %s: %v\n", filepath.Base(pack.Path), err)
+			problems++
+		}
+		for i := 0; i < pack.Index.Count(); i++ {
+			sha := pack.Index.Hash(i)
+			objType, data, err := pack.ReadAt(repoPath, pack.Index.Offsets[i])
+			if err != nil {
+				fmt.Printf("error: %s in %s: %v\n", sha, filepath.Base(pack.Path), err)
+				problems++
+				continue
+			}
+			if actual := objects.HashRaw(objType, data); actual != sha {
+				fmt.Printf("error: %s in %s: hash mismatch: content hashes to %s\n", sha, filepath.Base(pack.Path), actual)
+				problems++
+				continue
+			}
+			record(sha, objType, data)
+		}
+	}
+
+	shas := make([]string, 0, len(found))
+	for sha := range found {
+		shas = append(shas, sha)
+	}
+	sort.Strings(shas)
+
+	// Refs, the index and reflogs are the roots of reachability
+	roots, rootProblems, err := fsckRoots(repoPath, found)
+	if err != nil {
+		return err
+	}
+	problems += rootProblems
+
+	// Every link of a reachable object must lead to an existing object of
+	// the expected type. Unreachable objects are garbage and may be broken.
+	reachable := make(map[string]bool)
+	missing := make(map[string]string)
+	pending := roots
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[sha] {
+			continue
+		}
+		reachable[sha] = true
+
+		obj := found[sha]
+		for _, link := range obj.Links {
+			target, ok := found[link.Hash]
+			if !ok {
+				fmt.Printf("broken link from %7s %s\n              to %7s %s\n", obj.Type, sha, link.Type, link.Hash)
+				missing[link.Hash] = link.Type
+				problems++
+				continue
+			}
+			if target.Type != link.Type {
+				fmt.Printf("error: object %s is a %s, not a %s (referenced by %s %s)\n", link.Hash, target.Type, link.Type, obj.Type, sha)
+				problems++
+			}
+			pending = append(pending, link.Hash)
+		}
+	}
+	for _, sha := range sortedKeys(missing) {
+		fmt.Printf("missing %s %s\n", missing[sha], sha)
+	}
+
+	// Dangling objects are unreachable ones that nothing else points to
+	referenced := make(map[string]bool)
+	for _, obj := range found {
+		for _, link := range obj.Links {
+			referenced[link.Hash] = true
+		}
+	}
+	for _, sha := range shas {
+		if reachable[sha] {
+			continue
+		}
+		switch {
+		case opts.Unreachable:
+			fmt.Printf("unreachable %s %s\n", found[sha].Type, sha)
+		case !opts.NoDangling && !referenced[sha]:
+			fmt.Printf("dangling %s %s\n", found[sha].Type, sha)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problem(s) in the repository", problems)
+	}
+	return nil
+}
+
+// fsckRoots returns the objects that refs, the index and reflogs point at,
+// and the number of those pointers that lead nowhere.
+func fsckRoots(repoPath string, found map[string]*fsckObject) ([]string, int, error) {
+	roots, err := reachability.Roots(repoPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := refs.Resolve(repoPath, "HEAD"); errors.Is(err, refs.ErrNotFound) {
+		if branch, onBranch, err := refs.CurrentBranch(repoPath); err == nil && onBranch {
+			fmt.Printf("notice: HEAD points to an unborn branch (%s)\n", branch)
+		}
+	}
+
+	var hashes []string
+	problems := 0
+	for _, root := range roots {
+		if _, ok := found[root.Hash]; !ok {
+			fmt.Printf("error: %s: invalid sha1 pointer %s\n", root.Source, root.Hash)
+			problems++
+			continue
+		}
+		hashes = append(hashes, root.Hash)
+	}
+	return hashes, problems, nil
+}
+
+// sortedKeys returns the keys of a string map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/objects"
+	"gopract/reachability"
+	"gopract/refs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PruneOptions controls which unreachable loose objects `prune` removes.
+type PruneOptions struct {
+	Expire time.Time // Only remove objects last modified before this; zero removes all
+	DryRun bool      // List what would be removed without removing it
+}
+
+// GCOptions controls `gc`.
+type GCOptions struct {
+	Expire  time.Time // Unreachable objects modified before this are deleted; zero deletes all
+	NoPrune bool      // Keep every unreachable object
+	DryRun  bool      // List what would be removed without changing anything
+}
+
+// Prune deletes loose objects that cannot be reached from any ref, the
+// index or a reflog. Packed objects are left to `gc`.
+func Prune(repoPath string, opts PruneOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	reachable, err := reachableObjects(repoPath)
+	if err != nil {
+		return err
+	}
+	loose, err := objects.ListLooseObjects(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list loose objects: %w", err)
+	}
+
+	var expired []string
+	for _, sha := range loose {
+		if _, ok := reachable[sha]; ok {
+			continue
+		}
+		if info, err := os.Stat(looseObjectPath(repoPath, sha)); err == nil && isExpired(info.ModTime(), opts.Expire) {
+			expired = append(expired, sha)
+		}
+	}
+
+	if opts.DryRun {
+		for _, sha := range expired {
+			fmt.Printf("%s %s\n", sha, objectType(repoPath, sha))
+		}
+		return nil
+	}
+	if err := removeLooseObjects(repoPath, expired); err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d unreachable objects\n", len(expired))
+	return nil
+}
+
+// GC tidies the repository: refs are packed, every reachable object is
+// packed into a single packfile, and unreachable objects older than the
+// grace period are deleted. Unreachable objects that are still within the
+// grace period are kept as loose objects so that a later run can expire them.
+func GC(repoPath string, opts GCOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	if !opts.DryRun {
+		count, err := refs.Pack(repoPath)
+		if err != nil {
+			return fmt.Errorf("failed to pack refs: %w", err)
+		}
+		if count > 0 {
+			fmt.Printf("Packed %d refs\n", count)
+		}
+	}
+
+	reachable, err := reachableObjects(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// Sort loose objects into those to pack, to delete and to keep
+	loose, err := objects.ListLooseObjects(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	var packedLoose, expired []string
+	for _, sha := range loose {
+		if _, ok := reachable[sha]; ok {
+			packedLoose = append(packedLoose, sha)
+			continue
+		}
+		info, err := os.Stat(looseObjectPath(repoPath, sha))
+		if err == nil && !opts.NoPrune && isExpired(info.ModTime(), opts.Expire) {
+			expired = append(expired, sha)
+		}
+	}
+
+	// Unreachable objects in packs expire with the pack they are in;
+	// recent ones are written out as loose objects before the pack goes
+	packs, err := objects.OpenPackfiles(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open packfiles: %w", err)
+	}
+	type packedObject struct {
+		pack   *objects.Packfile
+		offset uint64
+		mtime  time.Time
+	}
+	dropped := make(map[string]bool)
+	loosen := make(map[string]packedObject)
+	for _, pack := range packs {
+		info, err := os.Stat(pack.Path)
+		if err != nil {
+			return fmt.Errorf("failed to stat packfile: %w", err)
+		}
+		for i := 0; i < pack.Index.Count(); i++ {
+			sha := pack.Index.Hash(i)
+			if _, ok := reachable[sha]; ok {
+				continue
+			}
+			if _, err := os.Stat(looseObjectPath(repoPath, sha)); err == nil {
+				continue // Handled with the loose objects
+			}
+			if !opts.NoPrune && isExpired(info.ModTime(), opts.Expire) {
+				dropped[sha] = true
+				continue
+			}
+			loosen[sha] = packedObject{pack: pack, offset: pack.Index.Offsets[i], mtime: info.ModTime()}
+		}
+	}
+
+	if opts.DryRun {
+		removed := append([]string{}, expired...)
+		for sha := range dropped {
+			if _, ok := loosen[sha]; !ok {
+				removed = append(removed, sha)
+			}
+		}
+		sort.Strings(removed)
+		for _, sha := range removed {
+			fmt.Printf("Would remove %s %s\n", sha, objectType(repoPath, sha))
+		}
+		fmt.Printf("Would remove %d unreachable objects and pack %d reachable ones\n", len(removed), len(reachable))
+		return nil
+	}
+
+	for sha, obj := range loosen {
+		delete(dropped, sha) // It may also be in a pack that has expired
+		objType, data, err := obj.pack.ReadAt(repoPath, obj.offset)
+		if err != nil {
+			return fmt.Errorf("failed to read unreachable object %s: %w", sha, err)
+		}
+		if _, err := objects.WriteRawObject(repoPath, objType, data); err != nil {
+			return fmt.Errorf("failed to keep unreachable object %s: %w", sha, err)
+		}
+		// Keep the pack's age so the object still expires on schedule
+		os.Chtimes(looseObjectPath(repoPath, sha), obj.mtime, obj.mtime)
+	}
+
+	// Pack everything reachable, then retire what the new pack supersedes
+	var packPath string
+	if len(reachable) > 0 {
+		shas := make([]string, 0, len(reachable))
+		for sha := range reachable {
+			shas = append(shas, sha)
+		}
+		sort.Strings(shas)
+		if packPath, err = objects.WritePackfile(repoPath, shas); err != nil {
+			return fmt.Errorf("failed to write packfile: %w", err)
+		}
+		fmt.Printf("Packed %d objects into %s\n", len(shas), filepath.Base(packPath))
+	}
+	for _, pack := range packs {
+		if pack.Path == packPath {
+			continue
+		}
+		base := strings.TrimSuffix(pack.Path, ".pack")
+		for _, ext := range []string{".pack", ".idx"} {
+			if err := os.Remove(base + ext); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove old pack %s: %w", base+ext, err)
+			}
+		}
+	}
+	if err := removeLooseObjects(repoPath, packedLoose); err != nil {
+		return err
+	}
+	if err := removeLooseObjects(repoPath, expired); err != nil {
+		return err
+	}
+
+	if removed := len(expired) + len(dropped); removed > 0 {
+		fmt.Printf("Removed %d unreachable objects\n", removed)
+	}
+	return nil
+}
+
+// reachableObjects walks the repository from all of its roots.
+func reachableObjects(repoPath string) (map[string]string, error) {
+	roots, err := reachability.Roots(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	reachable, err := reachability.Walk(repoPath, roots)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to remove objects, the repository is damaged (run fsck): %w", err)
+	}
+	return reachable, nil
+}
+
+// isExpired reports whether an object modified at mtime is past the expiry
+// date. A zero expiry means every object has expired.
+func isExpired(mtime, expire time.Time) bool {
+	return expire.IsZero() || mtime.Before(expire)
+}
+
+// looseObjectPath returns the file that stores a loose object.
+func looseObjectPath(repoPath, sha string) string {
+	return filepath.Join(repoPath, ".git", "objects", sha[:2], sha[2:])
+}
+
+// objectType returns the type of an object, or "unknown" when it cannot be
+// read.
+func objectType(repoPath, sha string) string {
+	objType, _, err := objects.ReadRawObject(repoPath, sha)
+	if err != nil {
+		return "unknown"
+	}
+	return objType
+}
+
+// removeLooseObjects deletes loose objects and any fan-out directories they
+// leave empty.
+func removeLooseObjects(repoPath string, shas []string) error {
+	for _, sha := range shas {
+		path := looseObjectPath(repoPath, sha)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove loose object %s: %w", sha, err)
+		}
+		os.Remove(filepath.Dir(path))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// gcTestRepo is a repository whose objects are each kept alive by a single
+// kind of root, plus objects nothing refers to.
+type gcTestRepo struct {
+	path string
+	kept map[string]string // Objects that must survive, by what keeps them
+}
+
+func newGCTestRepo(t *testing.T) *gcTestRepo {
+	t.Helper()
+	repo := &gcTestRepo{path: t.TempDir(), kept: make(map[string]string)}
+	if err := os.MkdirAll(filepath.Join(repo.path, ".git", "refs", "heads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo.file(t, "HEAD", "ref: refs/heads/master\n")
+
+	sig := objects.Signature{Name: "Tester", Email: "tester@example.com", When: time.Unix(1700000000, 0).UTC()}
+	write := func(obj objects.GitObject) string {
+		t.Helper()
+		hash, err := objects.WriteObject(obj, repo.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	// Each commit gets a tree and blob of its own, so that they too are only
+	// reachable through that root
+	commit := func(root string) string {
+		t.Helper()
+		blob := write(&objects.Blob{Data: []byte(root + "\n")})
+		tree := write(&objects.Tree{Entries: []objects.TreeEntry{{Mode: objects.ModeFile, Name: "f", Hash: blob}}})
+		hash := write(&objects.Commit{Tree: tree, Author: sig, Committer: sig, Message: root + "\n"})
+		repo.kept[root+" blob"], repo.kept[root+" tree"], repo.kept[root] = blob, tree, hash
+		return hash
+	}
+	line := func(old, new, message string) string {
+		return fmt.Sprintf("%s %s Tester <tester@example.com> 1700000000 +0000\t%s\n", old, new, message)
+	}
+
+	master := commit("branch")
+	repo.file(t, "refs/heads/master", master+"\n")
+
+	amended := commit("reflog")
+	repo.file(t, "logs/refs/heads/master",
+		line(refs.ZeroHash, amended, "commit (initial): reflog")+
+			line(amended, master, "commit (amend): branch"))
+
+	stash := commit("stash")
+	older := commit("older stash")
+	repo.file(t, "refs/stash", stash+"\n")
+	repo.file(t, "logs/refs/stash",
+		line(refs.ZeroHash, older, "WIP on master: older")+
+			line(older, stash, "WIP on master: newer"))
+
+	repo.file(t, "MERGE_HEAD", commit("MERGE_HEAD")+"\n")
+
+	staged := write(&objects.Blob{Data: []byte("staged\n")})
+	repo.kept["index"] = staged
+	index := &staging.Index{Entries: []staging.IndexEntry{{Mode: 0100644, BlobHash: staged, FilePath: "f"}}}
+	if err := staging.WriteIndex(repo.path, index); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// file writes a file below `.git`.
+func (r *gcTestRepo) file(t *testing.T, name, contents string) {
+	t.Helper()
+	path := filepath.Join(r.path, ".git", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// garbage writes a blob nothing refers to, last modified at mtime.
+func (r *gcTestRepo) garbage(t *testing.T, contents string, mtime time.Time) string {
+	t.Helper()
+	hash, err := objects.WriteRawObject(r.path, "blob", []byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(looseObjectPath(r.path, hash), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// checkKept fails the test if any object that has a root is gone.
+func (r *gcTestRepo) checkKept(t *testing.T) {
+	t.Helper()
+	for root, hash := range r.kept {
+		if _, _, err := objects.ReadRawObject(r.path, hash); err != nil {
+			t.Errorf("object %s kept by %s was lost: %v", hash, root, err)
+		}
+	}
+}
+
+func TestGCPruneNowKeepsRoots(t *testing.T) {
+	repo := newGCTestRepo(t)
+	looseGarbage := repo.garbage(t, "loose garbage\n", time.Now().Add(-time.Hour))
+
+	// Unreachable objects in an old pack go with it
+	packedGarbage := repo.garbage(t, "packed garbage\n", time.Now())
+	if _, err := objects.WritePackfile(repo.path, []string{packedGarbage}); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeLooseObjects(repo.path, []string{packedGarbage}); err != nil {
+		t.Fatal(err)
+	}
+
+	expire, err := ParseDate("now")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GC(repo.path, GCOptions{Expire: expire}); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+
+	repo.checkKept(t)
+	for _, hash := range []string{looseGarbage, packedGarbage} {
+		if objects.HasObject(repo.path, hash) {
+			t.Errorf("unreachable object %s survived --prune=now", hash)
+		}
+	}
+	if loose, err := objects.ListLooseObjects(repo.path); err != nil || len(loose) != 0 {
+		t.Errorf("loose objects left after gc: %v, %v", loose, err)
+	}
+}
+
+func TestGCKeepsRecentUnreachableObjects(t *testing.T) {
+	repo := newGCTestRepo(t)
+	now := time.Now()
+	expired := repo.garbage(t, "expired\n", now.Add(-2*time.Hour))
+	recent := repo.garbage(t, "recent\n", now)
+
+	// A recent unreachable object in a pack must come out of it loose
+	packed := repo.garbage(t, "packed\n", now)
+	if _, err := objects.WritePackfile(repo.path, []string{packed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeLooseObjects(repo.path, []string{packed}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GC(repo.path, GCOptions{Expire: now.Add(-time.Hour)}); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+
+	repo.checkKept(t)
+	if objects.HasObject(repo.path, expired) {
+		t.Errorf("unreachable object %s past the grace period survived", expired)
+	}
+	for _, hash := range []string{recent, packed} {
+		info, err := os.Stat(looseObjectPath(repo.path, hash))
+		if err != nil {
+			t.Errorf("unreachable object %s within the grace period is not loose: %v", hash, err)
+			continue
+		}
+		if info.ModTime().Before(now.Add(-time.Minute)) {
+			t.Errorf("object %s was loosened with mtime %v, want about %v", hash, info.ModTime(), now)
+		}
+	}
+	for root, hash := range repo.kept {
+		if _, err := os.Stat(looseObjectPath(repo.path, hash)); err == nil {
+			t.Errorf("reachable object %s kept by %s was left loose", hash, root)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/config"
+	"gopract/objects"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Roles accepted by newSignature, named after the GIT_<ROLE>_* variables.
+const (
+	roleAuthor    = "AUTHOR"
+	roleCommitter = "COMMITTER"
+)
+
+// newSignature builds the identity recorded in a new commit or tag. The
+// GIT_<role>_NAME, GIT_<role>_EMAIL and GIT_<role>_DATE environment variables
+// take precedence over the configured user and the current time.
+func newSignature(repoPath, role string) (objects.Signature, error) {
+	name, email, err := config.ReadUser(repoPath)
+	if err != nil {
+		return objects.Signature{}, err
+	}
+	if value, ok := os.LookupEnv("GIT_" + role + "_NAME"); ok {
+		name = value
+	}
+	if value, ok := os.LookupEnv("GIT_" + role + "_EMAIL"); ok {
+		email = value
+	}
+	if name == "" || email == "" {
+		return objects.Signature{}, fmt.Errorf("%s identity unknown; set them with `set-config --key user.name --value <name>` and `set-config --key user.email --value <email>`", strings.ToLower(role))
+	}
+
+	when := time.Now()
+	if value := os.Getenv("GIT_" + role + "_DATE"); value != "" {
+		if when, err = parseSignatureDate(value); err != nil {
+			return objects.Signature{}, fmt.Errorf("invalid GIT_%s_DATE: %w", role, err)
+		}
+	}
+	// Like Git, write the offset in its canonical form ("-0000" becomes "+0000")
+	_, offset := when.Zone()
+	when = when.In(time.FixedZone("", offset))
+
+	// Angle brackets and newlines would corrupt the header line
+	clean := func(s string) string {
+		return strings.TrimSpace(strings.Map(func(r rune) rune {
+			if r == '<' || r == '>' || r == '\n' {
+				return -1
+			}
+			return r
+		}, s))
+	}
+	return objects.Signature{Name: clean(name), Email: clean(email), When: when}, nil
+}
+
+// parseSignatureDate understands Git's internal "<unix seconds> <+hhmm>"
+// format (optionally prefixed with "@"), RFC 2822 and ISO 8601 dates, and
+// everything ParseDate accepts.
+func parseSignatureDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	fields := strings.Fields(strings.TrimPrefix(value, "@"))
+	if len(fields) == 2 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			if loc, ok := objects.ParseTimezone(fields[1]); ok {
+				return time.Unix(seconds, 0).In(loc), nil
+			}
+		}
+	}
+
+	for _, layout := range []string{
+		time.RFC1123Z,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"2006-01-02T15:04:05-0700",
+	} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return ParseDate(value)
+}
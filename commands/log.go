@@ -0,0 +1,559 @@
+package commands
+
+import (
+	"container/heap"
+	"fmt"
+	"gopract/objects"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit orderings supported by `log`.
+const (
+	OrderDefault = ""     // Newest first, by commit date
+	OrderDate    = "date" // Never show a parent before its children; otherwise by date
+	OrderTopo    = "topo" // Never show a parent before its children; keep lines of history together
+)
+
+// LogOptions controls which commits `log` shows and how they are printed.
+type LogOptions struct {
+	Revision string    // Where to start walking (defaults to HEAD)
+	MaxCount int       // Stop after this many commits (0 means no limit)
+	Oneline  bool      // Print "<short sha> <subject>" per commit
+	Graph    bool      // Draw the history graph next to the log
+	Paths    []string  // Only show commits touching these paths
+	Author   string    // Only show commits whose author matches this pattern
+	Since    time.Time // Only show commits more recent than this
+	Until    time.Time // Only show commits older than this
+	Order    string    // One of the Order* constants
+}
+
+// logCommit is a commit loaded during the walk.
+type logCommit struct {
+	Hash   string
+	Commit *objects.Commit
+	Name   string    // Author name
+	Email  string    // Author email
+	Date   time.Time // Author date, as shown on the Date: line
+	When   time.Time // Committer date, used for ordering and --since/--until
+}
+
+// Log walks history from a revision and prints the commits it finds.
+func Log(repoPath string, opts LogOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	rev := opts.Revision
+	if rev == "" {
+		rev = "HEAD"
+	}
+	start, err := resolveRevision(repoPath, rev)
+	if err != nil {
+		return err
+	}
+	if start, err = peelToCommit(repoPath, start); err != nil {
+		return err
+	}
+
+	var authorPattern *regexp.Regexp
+	if opts.Author != "" {
+		if authorPattern, err = regexp.Compile(opts.Author); err != nil {
+			return fmt.Errorf("invalid author pattern: %w", err)
+		}
+	}
+
+	// The graph only makes sense when parents follow their children
+	order := opts.Order
+	if opts.Graph && order == OrderDefault {
+		order = OrderTopo
+	}
+
+	walker := &logWalker{repoPath: repoPath, loaded: make(map[string]*logCommit)}
+	var next func() (*logCommit, error)
+	if order == OrderDefault {
+		// Commits are read as they are shown, so a limited log stops early
+		next = walker.dateWalk(start)
+	} else {
+		commits, err := walker.topoWalk(start, order == OrderTopo)
+		if err != nil {
+			return err
+		}
+		next = func() (*logCommit, error) {
+			if len(commits) == 0 {
+				return nil, nil
+			}
+			c := commits[0]
+			commits = commits[1:]
+			return c, nil
+		}
+	}
+
+	graph := &logGraph{}
+	shown := 0
+	for opts.MaxCount <= 0 || shown < opts.MaxCount {
+		c, err := next()
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			break
+		}
+
+		include := true
+		if authorPattern != nil && !authorPattern.MatchString(fmt.Sprintf("%s <%s>", c.Name, c.Email)) {
+			include = false
+		}
+		if !opts.Since.IsZero() && c.When.Before(opts.Since) {
+			include = false
+		}
+		if !opts.Until.IsZero() && c.When.After(opts.Until) {
+			include = false
+		}
+		if include && len(opts.Paths) > 0 {
+			if include, err = walker.touchesPaths(c, opts.Paths); err != nil {
+				return err
+			}
+		}
+
+		if !opts.Graph {
+			if include {
+				printLogCommit(c, opts.Oneline, "", nil, "")
+				shown++
+			}
+			continue
+		}
+
+		row, expansion, padding, collapse := graph.place(c.Hash, c.Commit.Parents)
+		if include {
+			printLogCommit(c, opts.Oneline, row, expansion, padding)
+			shown++
+		}
+		for _, line := range collapse {
+			fmt.Println(line)
+		}
+	}
+
+	return nil
+}
+
+// printLogCommit prints one commit, prefixing every line with graph art when
+// a graph is being drawn.
+func printLogCommit(c *logCommit, oneline bool, row string, expansion []string, padding string) {
+	prefix := ""
+	if row != "" {
+		prefix = row + " "
+	}
+
+	if oneline {
+		fmt.Printf("%s%s %s\n", prefix, c.Hash[:7], firstLine(c.Commit.Message))
+		for _, line := range expansion {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	fmt.Printf("%scommit %s\n", prefix, c.Hash)
+	for _, line := range expansion {
+		fmt.Println(line)
+	}
+
+	body := []string{}
+	if len(c.Commit.Parents) > 1 {
+		short := make([]string, len(c.Commit.Parents))
+		for i, p := range c.Commit.Parents {
+			short[i] = p[:7]
+		}
+		body = append(body, "Merge: "+strings.Join(short, " "))
+	}
+	body = append(body,
+		fmt.Sprintf("Author: %s <%s>", c.Name, c.Email),
+		fmt.Sprintf("Date:   %s", c.Date.Format("Mon Jan 2 15:04:05 2006 -0700")),
+		"",
+	)
+	for _, line := range strings.Split(strings.TrimRight(c.Commit.Message, "\n"), "\n") {
+		body = append(body, "    "+line)
+	}
+	body = append(body, "")
+
+	for _, line := range body {
+		fmt.Println(strings.TrimRight(padding+line, " "))
+	}
+}
+
+// logWalker loads commits on demand and caches them for the whole walk.
+type logWalker struct {
+	repoPath string
+	loaded   map[string]*logCommit
+}
+
+// load reads a commit and parses its author and committer lines.
+func (w *logWalker) load(hash string) (*logCommit, error) {
+	if c, ok := w.loaded[hash]; ok {
+		return c, nil
+	}
+	commit, err := readCommit(w.repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	author := commit.Author
+	c := &logCommit{Hash: hash, Commit: commit, Name: author.Name, Email: author.Email, Date: author.When, When: commit.Committer.When}
+	if commit.Committer.IsZero() {
+		c.When = author.When
+	}
+	w.loaded[hash] = c
+	return c, nil
+}
+
+// dateWalk returns a function that yields every reachable commit, newest
+// first, and nil once the history is exhausted. Commits are only read when
+// the walk gets to their children.
+func (w *logWalker) dateWalk(start string) func() (*logCommit, error) {
+	queue := &commitQueue{}
+	seen := map[string]bool{start: true}
+	pending := []string{start}
+	return func() (*logCommit, error) {
+		for _, hash := range pending {
+			c, err := w.load(hash)
+			if err != nil {
+				return nil, err
+			}
+			heap.Push(queue, c)
+		}
+		pending = pending[:0]
+		if queue.Len() == 0 {
+			return nil, nil
+		}
+
+		c := heap.Pop(queue).(*logCommit)
+		for _, parent := range c.Commit.Parents {
+			if !seen[parent] {
+				seen[parent] = true
+				pending = append(pending, parent)
+			}
+		}
+		return c, nil
+	}
+}
+
+// topoWalk returns every reachable commit with children always before their
+// parents. Ties are broken by date, or by following one line of history as
+// far as possible when grouped is set.
+func (w *logWalker) topoWalk(start string, grouped bool) ([]*logCommit, error) {
+	// Count how many reachable children each commit has
+	children := make(map[string]int)
+	stack := []string{start}
+	seen := map[string]bool{start: true}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		c, err := w.load(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range c.Commit.Parents {
+			children[parent]++
+			if !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+
+	// Commits whose children have all been emitted
+	ready := []*logCommit{w.loaded[start]}
+	var out []*logCommit
+	for len(ready) > 0 {
+		// Take the newest ready commit, or the most recently readied one
+		// to keep following the same line of history
+		pick := len(ready) - 1
+		if !grouped {
+			for i := range ready {
+				if ready[i].When.After(ready[pick].When) {
+					pick = i
+				}
+			}
+		}
+		c := ready[pick]
+		ready = append(ready[:pick], ready[pick+1:]...)
+		out = append(out, c)
+
+		// Like Git, the last parent readied is followed first
+		for _, parent := range c.Commit.Parents {
+			children[parent]--
+			if children[parent] == 0 {
+				ready = append(ready, w.loaded[parent])
+			}
+		}
+	}
+	return out, nil
+}
+
+// touchesPaths reports whether a commit changes any of the given paths
+// compared to every one of its parents (or adds them, for a root commit).
+func (w *logWalker) touchesPaths(c *logCommit, paths []string) (bool, error) {
+	current, err := w.pathHashes(c.Commit.Tree, paths)
+	if err != nil {
+		return false, err
+	}
+	if len(c.Commit.Parents) == 0 {
+		return current != strings.Repeat("\x00", len(paths)), nil
+	}
+	for _, parent := range c.Commit.Parents {
+		p, err := w.load(parent)
+		if err != nil {
+			return false, err
+		}
+		previous, err := w.pathHashes(p.Commit.Tree, paths)
+		if err != nil {
+			return false, err
+		}
+		if previous == current {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// pathHashes summarises the objects found at each path in a tree. Missing
+// paths are represented by a NUL byte.
+func (w *logWalker) pathHashes(treeHash string, paths []string) (string, error) {
+	var sb strings.Builder
+	for _, p := range paths {
+		entry, ok, err := objects.LookupPath(w.repoPath, treeHash, filepath.ToSlash(filepath.Clean(p)))
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			sb.WriteByte(0)
+			continue
+		}
+		sb.WriteString(entry.Mode + entry.Hash)
+	}
+	return sb.String(), nil
+}
+
+// commitQueue is a max-heap of commits ordered by date.
+type commitQueue []*logCommit
+
+func (q commitQueue) Len() int            { return len(q) }
+func (q commitQueue) Less(i, j int) bool  { return q[i].When.After(q[j].When) }
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*logCommit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// logGraph tracks which commit each column of the graph is waiting for.
+type logGraph struct {
+	columns []string
+}
+
+// place puts a commit into the graph. It returns the art for the commit's own
+// line, rows that fan out to a merge's extra parents, the prefix for the
+// remaining lines of the commit, and rows that join columns which now wait
+// for the same commit.
+func (g *logGraph) place(hash string, parents []string) (string, []string, string, []string) {
+	idx := -1
+	for i, h := range g.columns {
+		if h == hash {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		g.columns = append(g.columns, hash)
+		idx = len(g.columns) - 1
+	}
+
+	row := make([]string, len(g.columns))
+	for i := range g.columns {
+		row[i] = "|"
+		if i == idx {
+			row[i] = "*"
+		}
+	}
+
+	// Replace the commit's column with its parents
+	var added []string
+	for i, parent := range parents {
+		if i == 0 || !contains(g.columns, parent) {
+			added = append(added, parent)
+		}
+	}
+	expanded := append([]string{}, g.columns[:idx]...)
+	expanded = append(expanded, added...)
+	expanded = append(expanded, g.columns[idx+1:]...)
+
+	var expansion []string
+	if extra := len(added) - 1; extra > 0 {
+		art := newGraphRow(2 * len(expanded))
+		for i := 0; i <= idx; i++ {
+			art.set(2*i, '|')
+		}
+		for j := 1; j <= extra; j++ {
+			art.set(2*idx+2*j-1, '\\')
+		}
+		for i := idx + 1; i < len(g.columns); i++ {
+			art.set(2*(i+extra)-1, '\\')
+		}
+		expansion = append(expansion, art.String())
+	}
+
+	// Columns waiting for the same commit are joined into the leftmost one
+	deduped := []string{}
+	position := make([]int, len(expanded))
+	for i, h := range expanded {
+		at := -1
+		for j, d := range deduped {
+			if d == h {
+				at = j
+				break
+			}
+		}
+		if at < 0 {
+			deduped = append(deduped, h)
+			at = len(deduped) - 1
+		}
+		position[i] = at
+	}
+
+	var collapse []string
+	if len(added) == 0 {
+		// A root commit leaves a gap that later columns slide into
+		if idx < len(g.columns)-1 {
+			art := newGraphRow(2 * len(g.columns))
+			for i := 0; i < idx; i++ {
+				art.set(2*i, '|')
+			}
+			for i := idx + 1; i < len(g.columns); i++ {
+				art.set(2*i-1, '/')
+			}
+			collapse = append(collapse, art.String())
+		}
+	} else {
+		moved := false
+		art := newGraphRow(2 * len(expanded))
+		for i, p := range position {
+			if p == i {
+				art.set(2*i, '|')
+			} else {
+				art.set(2*i-1, '/')
+				moved = true
+			}
+		}
+		if moved {
+			collapse = append(collapse, art.String())
+		}
+	}
+
+	padding := strings.Repeat("| ", len(expanded))
+	if padding == "" {
+		padding = "  "
+	}
+
+	g.columns = deduped
+	return strings.Join(row, " "), expansion, padding, collapse
+}
+
+// graphRow is one line of graph art.
+type graphRow []byte
+
+func newGraphRow(width int) graphRow {
+	row := make(graphRow, width)
+	for i := range row {
+		row[i] = ' '
+	}
+	return row
+}
+
+func (r graphRow) set(i int, c byte) {
+	if i >= 0 && i < len(r) {
+		r[i] = c
+	}
+}
+
+func (r graphRow) String() string {
+	return strings.TrimRight(string(r), " ")
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// firstLine returns the subject line of a commit message.
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n")
+	return line
+}
+
+// ParseDate understands the date formats accepted by --since and --until:
+// absolute dates, Unix timestamps, "now" and "<n> <unit>s ago" (also written
+// "<n>.<unit>s.ago", as in Git's configuration).
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "now" {
+		return time.Now(), nil
+	}
+	if strings.HasSuffix(value, ".ago") {
+		value = strings.ReplaceAll(value, ".", " ")
+	}
+
+	for _, layout := range []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"Mon Jan 2 15:04:05 2006 -0700",
+	} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if seconds, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil {
+			now := time.Now()
+			switch strings.TrimSuffix(fields[1], "s") {
+			case "second":
+				return now.Add(-time.Duration(n) * time.Second), nil
+			case "minute":
+				return now.Add(-time.Duration(n) * time.Minute), nil
+			case "hour":
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case "day":
+				return now.AddDate(0, 0, -n), nil
+			case "week":
+				return now.AddDate(0, 0, -7*n), nil
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date: %s", value)
+}
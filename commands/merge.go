@@ -0,0 +1,401 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/checkout"
+	"gopract/merge"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/repository"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MergeOptions controls how `merge` combines another commit into HEAD.
+type MergeOptions struct {
+	Revision string              // Commit to merge into HEAD
+	NoFF     bool                // Create a merge commit even when a fast-forward is possible
+	Message  string              // Message for the merge commit
+	Style    merge.ConflictStyle // How conflicts are marked in the worktree
+	Abort    bool                // Throw away a conflicted merge in progress
+}
+
+// Merge joins the history of another commit into the current branch. When
+// the merge cannot be resolved automatically the conflicted files are left
+// with conflict markers, their stages are recorded in the index and
+// MERGE_HEAD is written so that `commit` can conclude the merge.
+func Merge(repoPath string, opts MergeOptions) error {
+	repo, err := repository.NewRepository(repoPath, false)
+	if err != nil {
+		return err
+	}
+	gitDir := repo.Gitdir
+
+	if opts.Abort {
+		return abortMerge(repo)
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "MERGE_HEAD")); err == nil {
+		return fmt.Errorf("you have not concluded your merge (MERGE_HEAD exists); commit or abort it first")
+	}
+
+	ours, oursTree, err := headTree(repoPath)
+	if err != nil {
+		return err
+	}
+	sha, err := resolveRevision(repoPath, opts.Revision)
+	if err != nil {
+		return fmt.Errorf("%s - not something we can merge", opts.Revision)
+	}
+	theirs, err := peelToCommit(repoPath, sha)
+	if err != nil {
+		return err
+	}
+	theirsCommit, err := readCommit(repoPath, theirs)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIndexMatchesHead(repoPath, "merging"); err != nil {
+		return err
+	}
+
+	// Fast-forward when HEAD has nothing the other side lacks
+	if ours == "" {
+		return fastForward(repo, opts.Revision, "", "", theirs, theirsCommit.Tree)
+	}
+	if merged, err := merge.IsAncestor(repoPath, theirs, ours); err != nil {
+		return err
+	} else if merged {
+		fmt.Println("Already up to date.")
+		return nil
+	}
+	if !opts.NoFF {
+		if ff, err := merge.IsAncestor(repoPath, ours, theirs); err != nil {
+			return err
+		} else if ff {
+			return fastForward(repo, opts.Revision, ours, oursTree, theirs, theirsCommit.Tree)
+		}
+	}
+
+	base, err := merge.Base(repoPath, ours, theirs)
+	if err != nil {
+		return err
+	}
+	if base == "" {
+		return fmt.Errorf("refusing to merge unrelated histories")
+	}
+	baseCommit, err := readCommit(repoPath, base)
+	if err != nil {
+		return err
+	}
+
+	result, err := merge.Trees(repoPath, baseCommit.Tree, oursTree, theirsCommit.Tree, merge.ContentOptions{
+		Style:       opts.Style,
+		OursLabel:   "HEAD",
+		BaseLabel:   base[:7],
+		TheirsLabel: opts.Revision,
+	})
+	if err != nil {
+		return err
+	}
+
+	mergedTree, err := writeMergedTree(repoPath, result)
+	if err != nil {
+		return err
+	}
+
+	if err := checkConflictPaths(repo, result.Conflicts); err != nil {
+		return err
+	}
+	if err := checkout.Tree(repo, oursTree, mergedTree, checkout.Options{Operation: "merge"}); err != nil {
+		return err
+	}
+
+	message := opts.Message
+	if message == "" {
+		message = defaultMergeMessage(repoPath, opts.Revision)
+	}
+
+	if len(result.Conflicts) == 0 {
+		// Only one merge base is used, which Git calls the 'resolve' strategy
+		reflog := fmt.Sprintf("merge %s: Merge made by the 'resolve' strategy.", opts.Revision)
+		commitHash, err := writeCommit(repoPath, &objects.Commit{Tree: mergedTree, Parents: []string{ours, theirs}, Message: message}, false, reflog)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Merge made with commit %s\n", commitHash)
+		return nil
+	}
+
+	if err := recordConflicts(repo, result.Conflicts); err != nil {
+		return err
+	}
+
+	// Leave the state `commit` needs to conclude the merge
+	var msg strings.Builder
+	msg.WriteString(message + "\n\n# Conflicts:\n")
+	for _, c := range result.Conflicts {
+		fmt.Fprintf(&msg, "#\t%s\n", c.Path)
+	}
+	files := map[string]string{
+		"ORIG_HEAD":  ours + "\n",
+		"MERGE_HEAD": theirs + "\n",
+		"MERGE_MSG":  msg.String(),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(gitDir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	printConflicts(result.Conflicts, "HEAD", opts.Revision)
+	return fmt.Errorf("automatic merge failed; fix conflicts and then commit the result")
+}
+
+// printConflicts reports each conflicted path the way Git does, naming the
+// two sides of the merge.
+func printConflicts(conflicts []merge.Conflict, ours, theirs string) {
+	for _, c := range conflicts {
+		switch c.Reason {
+		case "modify/delete":
+			fmt.Printf("CONFLICT (modify/delete): %s deleted in %s and modified in %s.  Version %s of %s left in tree.\n", c.Path, theirs, ours, ours, c.Path)
+		case "delete/modify":
+			fmt.Printf("CONFLICT (modify/delete): %s deleted in %s and modified in %s.  Version %s of %s left in tree.\n", c.Path, ours, theirs, theirs, c.Path)
+		default:
+			fmt.Printf("CONFLICT (%s): Merge conflict in %s\n", c.Reason, c.Path)
+		}
+	}
+}
+
+// fastForward moves HEAD (and the worktree) forward to a descendant commit
+// named by revision.
+func fastForward(repo *repository.Repository, revision, ours, oursTree, theirs, theirsTree string) error {
+	if err := checkout.Tree(repo, oursTree, theirsTree, checkout.Options{Operation: "merge"}); err != nil {
+		return err
+	}
+	oldHash := ours
+	if oldHash == "" {
+		oldHash = refs.ZeroHash
+	}
+	if err := refs.Update(repo.Worktree, "HEAD", theirs, oldHash, "merge "+revision+": Fast-forward"); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+	if ours != "" {
+		fmt.Printf("Updating %s..%s\n", ours[:7], theirs[:7])
+	}
+	fmt.Println("Fast-forward")
+	return nil
+}
+
+// abortMerge throws away a conflicted merge, restoring the index and
+// worktree to HEAD.
+func abortMerge(repo *repository.Repository) error {
+	if _, err := os.Stat(filepath.Join(repo.Gitdir, "MERGE_HEAD")); os.IsNotExist(err) {
+		return fmt.Errorf("there is no merge to abort (MERGE_HEAD missing)")
+	}
+	_, tree, err := headTree(repo.Worktree)
+	if err != nil {
+		return err
+	}
+	if err := checkout.Tree(repo, tree, tree, checkout.Options{Force: true}); err != nil {
+		return err
+	}
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG"} {
+		os.Remove(filepath.Join(repo.Gitdir, name))
+	}
+	fmt.Println("Merge aborted")
+	return nil
+}
+
+// checkIndexMatchesHead refuses to merge on top of staged changes or an
+// unresolved index, which a merge would silently fold into its result.
+// doing names the operation in the error, e.g. "merging".
+func checkIndexMatchesHead(repoPath, doing string) error {
+	headEntries, err := headTreeEntries(repoPath)
+	if err != nil {
+		return err
+	}
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	staged := 0
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			return fmt.Errorf("you need to resolve your current index first: %s", entry.FilePath)
+		}
+		headEntry, ok := headEntries[entry.FilePath]
+		if !ok || headEntry.Hash != entry.BlobHash || headEntry.Mode != fmt.Sprintf("%o", entry.Mode) {
+			staged++
+		}
+	}
+	if staged > 0 || len(index.Entries) != len(headEntries) {
+		return fmt.Errorf("your index contains uncommitted changes; commit or stash them before %s", doing)
+	}
+	return nil
+}
+
+// checkConflictPaths makes sure the files that will receive conflict
+// markers hold no local modifications or untracked data.
+func checkConflictPaths(repo *repository.Repository, conflicts []merge.Conflict) error {
+	index, err := staging.ReadIndex(repo.Worktree)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	trustFileMode := staging.TrustFileMode(repo.Worktree)
+	var dirty []string
+	for _, c := range conflicts {
+		info, err := os.Lstat(filepath.Join(repo.Worktree, filepath.FromSlash(c.Path)))
+		if err != nil {
+			continue
+		}
+		entry, ok := index.Entry(c.Path)
+		if !ok {
+			dirty = append(dirty, c.Path)
+			continue
+		}
+		clean, err := staging.FileMatches(repo.Worktree, *entry, info, trustFileMode)
+		if err != nil {
+			return err
+		}
+		if !clean {
+			dirty = append(dirty, c.Path)
+		}
+	}
+	if len(dirty) > 0 {
+		return &checkout.ConflictError{Operation: "merge", Modified: dirty}
+	}
+	return nil
+}
+
+// recordConflicts writes the conflicted contents into the worktree and the
+// base, ours and theirs versions into index stages 1 to 3.
+func recordConflicts(repo *repository.Repository, conflicts []merge.Conflict) error {
+	index, err := staging.ReadIndex(repo.Worktree)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	for _, c := range conflicts {
+		if c.Mode != objects.ModeGitlink {
+			if err := writeConflictFile(repo, c); err != nil {
+				return err
+			}
+		}
+
+		var stages []staging.IndexEntry
+		for stage, side := range []*objects.TreeEntry{c.Base, c.Ours, c.Theirs} {
+			if side == nil {
+				continue
+			}
+			mode, err := strconv.ParseUint(side.Mode, 8, 32)
+			if err != nil {
+				return fmt.Errorf("invalid mode %s for %s", side.Mode, c.Path)
+			}
+			stages = append(stages, staging.IndexEntry{
+				Mode:     uint32(mode),
+				BlobHash: side.Hash,
+				Stage:    stage + 1,
+				FilePath: c.Path,
+			})
+		}
+		if err := index.AddConflict(stages...); err != nil {
+			return err
+		}
+	}
+
+	if err := staging.WriteIndex(repo.Worktree, index); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// writeConflictFile leaves the conflicted contents of a path in the
+// worktree. Whatever is at the path is replaced rather than written
+// through, so that a symlink there is not followed.
+func writeConflictFile(repo *repository.Repository, c merge.Conflict) error {
+	fullPath := filepath.Join(repo.Worktree, filepath.FromSlash(c.Path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", c.Path, err)
+	}
+	if info, err := os.Lstat(fullPath); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("cannot replace directory %s with a file", c.Path)
+		}
+		if err := os.Remove(fullPath); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", c.Path, err)
+		}
+	}
+
+	switch c.Mode {
+	case objects.ModeSymlink:
+		if err := os.Symlink(filepath.FromSlash(string(c.Data)), fullPath); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", c.Path, err)
+		}
+	default:
+		perm := os.FileMode(0644)
+		if c.Mode == objects.ModeExecutable {
+			perm = 0755
+		}
+		if err := os.WriteFile(fullPath, c.Data, perm); err != nil {
+			return fmt.Errorf("failed to write %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeMergedTree writes the tree the worktree gets from a merge: every
+// cleanly merged file plus our side of each conflict. The conflicted
+// contents are written over it afterwards.
+func writeMergedTree(repoPath string, result *merge.Result) (string, error) {
+	target := &staging.Index{Version: 2}
+	for _, entry := range result.Files {
+		if err := addTreeEntry(target, entry.Name, entry); err != nil {
+			return "", err
+		}
+	}
+	for _, c := range result.Conflicts {
+		if c.Ours != nil {
+			if err := addTreeEntry(target, c.Path, *c.Ours); err != nil {
+				return "", err
+			}
+		}
+	}
+	treeHash, err := staging.WriteTree(repoPath, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to write merged tree: %w", err)
+	}
+	return treeHash, nil
+}
+
+// addTreeEntry adds a tree entry to an index that is only used to build a
+// tree, so it carries no stat data.
+func addTreeEntry(index *staging.Index, filePath string, entry objects.TreeEntry) error {
+	mode, err := strconv.ParseUint(entry.Mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode %s for %s", entry.Mode, filePath)
+	}
+	return index.Add(staging.IndexEntry{Mode: uint32(mode), BlobHash: entry.Hash, FilePath: filePath})
+}
+
+// defaultMergeMessage describes a merge the way Git does.
+func defaultMergeMessage(repoPath, revision string) string {
+	kind := "commit"
+	if _, err := refs.Resolve(repoPath, "refs/heads/"+revision); err == nil {
+		kind = "branch"
+	} else if _, err := refs.Resolve(repoPath, "refs/tags/"+revision); err == nil {
+		kind = "tag"
+	}
+	message := fmt.Sprintf("Merge %s '%s'", kind, revision)
+
+	current, onBranch, err := refs.CurrentBranch(repoPath)
+	if err == nil && onBranch && current != "refs/heads/master" && current != "refs/heads/main" {
+		message += " into " + refs.ShortName(current)
+	}
+	return message
+}
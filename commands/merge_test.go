@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"gopract/merge"
+	"gopract/objects"
+	"gopract/repository"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordConflictsReplacesSymlink(t *testing.T) {
+	dir := t.TempDir()
+	worktree := filepath.Join(dir, "repo")
+	repo := &repository.Repository{Worktree: worktree, Gitdir: filepath.Join(worktree, ".git")}
+	if err := os.MkdirAll(repo.Gitdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The conflicted path is a symlink to a file outside the worktree
+	outside := filepath.Join(dir, "outside.txt")
+	if err := os.WriteFile(outside, []byte("outside\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(worktree, "f")); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	conflict := merge.Conflict{
+		Path:   "f",
+		Ours:   &objects.TreeEntry{Mode: objects.ModeExecutable, Hash: hash, Name: "f"},
+		Theirs: &objects.TreeEntry{Mode: objects.ModeExecutable, Hash: hash, Name: "f"},
+		Reason: "add/add",
+		Data:   []byte("<<<<<<< ours\na\n=======\nb\n>>>>>>> theirs\n"),
+		Mode:   objects.ModeExecutable,
+	}
+	if err := recordConflicts(repo, []merge.Conflict{conflict}); err != nil {
+		t.Fatalf("recordConflicts: %v", err)
+	}
+
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "outside\n" {
+		t.Errorf("symlink target now holds %q, %v", data, err)
+	}
+	info, err := os.Lstat(filepath.Join(worktree, "f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Fatalf("f has mode %v, want a regular file", info.Mode())
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("f has mode %v, want it executable", info.Mode())
+	}
+	if data, err := os.ReadFile(filepath.Join(worktree, "f")); err != nil || string(data) != string(conflict.Data) {
+		t.Errorf("f holds %q, %v; want the conflicted contents", data, err)
+	}
+}
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/objects"
+	"gopract/staging"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MvOptions controls how `mv` treats existing destinations and what it
+// reports.
+type MvOptions struct {
+	Force   bool // Overwrite an existing destination file
+	DryRun  bool // Only report what would be moved
+	Verbose bool // Report each move
+}
+
+// move is one rename `mv` has checked and is about to carry out.
+type move struct {
+	source, destination string // Worktree paths relative to the root
+	overwrite           bool   // The destination is a tracked file being replaced
+	replace             bool   // The destination exists and is replaced
+	backup              string // Where the replaced destination is kept until the index is written
+}
+
+// Mv renames tracked files or directories in both the worktree and the
+// index, so that the rename can be committed. Paths are relative to the
+// current directory. When the destination is an existing directory, or there
+// are several sources, each source is moved into it. Every move is checked
+// before anything is touched.
+func Mv(repoPath string, sources []string, destination string, opts MvOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("usage: mv <source>... <destination>")
+	}
+
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	srcs, err := resolvePathspecs(root, sources)
+	if err != nil {
+		return err
+	}
+	dsts, err := resolvePathspecs(root, []string{destination})
+	if err != nil {
+		return err
+	}
+	dst := dsts[0]
+	index, err := staging.ReadIndex(root)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	intoDir := dst == ""
+	if info, err := os.Lstat(worktreeFile(root, dst)); err == nil && info.IsDir() {
+		intoDir = true
+	}
+	if len(srcs) > 1 && !intoDir {
+		return fmt.Errorf("destination '%s' is not a directory", destination)
+	}
+
+	var moves []move
+	targets := make(map[string]string)
+	for i, src := range srcs {
+		target, shown := dst, destination
+		if intoDir {
+			target = path.Join(dst, path.Base(src))
+			shown = path.Join(destination, path.Base(sources[i]))
+		}
+		if err := objects.CheckPath(target); err != nil {
+			return err
+		}
+		if _, ok := targets[target]; ok {
+			return fmt.Errorf("multiple sources for the same target, source=%s, destination=%s", sources[i], shown)
+		}
+		targets[target] = src
+		m, err := checkMove(root, index, src, target, opts.Force)
+		if err != nil {
+			return fmt.Errorf("%s, source=%s, destination=%s", err, sources[i], shown)
+		}
+		moves = append(moves, m)
+	}
+
+	// On failure the renames already done are undone, and the files they
+	// replaced put back, so the worktree still matches the index, which is
+	// only written at the end
+	var done []move
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.Rename(worktreeFile(root, done[i].destination), worktreeFile(root, done[i].source))
+			if done[i].backup != "" {
+				os.Rename(done[i].backup, worktreeFile(root, done[i].destination))
+			}
+		}
+	}
+	for _, m := range moves {
+		if opts.DryRun || opts.Verbose {
+			fmt.Printf("Renaming %s to %s\n", m.source, m.destination)
+		}
+		if opts.DryRun {
+			continue
+		}
+		if m.replace {
+			backup, err := backupFile(worktreeFile(root, m.destination))
+			if err != nil {
+				undo()
+				return err
+			}
+			m.backup = backup
+		}
+		if err := os.Rename(worktreeFile(root, m.source), worktreeFile(root, m.destination)); err != nil {
+			if m.backup != "" {
+				os.Rename(m.backup, worktreeFile(root, m.destination))
+			}
+			undo()
+			return fmt.Errorf("failed to rename %s to %s: %w", m.source, m.destination, err)
+		}
+		done = append(done, m)
+		if m.overwrite {
+			index.Remove(m.destination)
+		}
+		if err := renameEntries(index, m.source, m.destination); err != nil {
+			undo()
+			return err
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+	if err := staging.WriteIndex(root, index); err != nil {
+		undo()
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	for _, m := range done {
+		if m.backup != "" {
+			os.Remove(m.backup)
+		}
+	}
+	return nil
+}
+
+// backupFile moves a file that a forced move replaces out of the way, next
+// to where it was, so that it can be put back if a later step fails.
+func backupFile(fullPath string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".mv-backup-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", fullPath, err)
+	}
+	tmp.Close()
+	if err := os.Rename(fullPath, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to back up %s: %w", fullPath, err)
+	}
+	return tmp.Name(), nil
+}
+
+// checkMove makes sure a source can be renamed to a destination: the source
+// must be a tracked file or a directory holding tracked files, and the
+// destination must not exist unless force allows replacing a file.
+func checkMove(root string, index *staging.Index, src, dst string, force bool) (move, error) {
+	m := move{source: src, destination: dst}
+	if src == "" {
+		return m, fmt.Errorf("bad source")
+	}
+	info, err := os.Lstat(worktreeFile(root, src))
+	if err != nil {
+		return m, fmt.Errorf("bad source")
+	}
+
+	if info.IsDir() {
+		if dst == src || strings.HasPrefix(dst, src+"/") {
+			return m, fmt.Errorf("can not move directory into itself")
+		}
+		tracked := false
+		for _, entry := range index.Entries {
+			if strings.HasPrefix(entry.FilePath, src+"/") {
+				if entry.Stage != 0 {
+					return m, fmt.Errorf("conflicted")
+				}
+				tracked = true
+			}
+		}
+		if !tracked {
+			return m, fmt.Errorf("source directory is empty")
+		}
+	} else {
+		if !isTracked(index, src) {
+			return m, fmt.Errorf("not under version control")
+		}
+		if _, ok := index.Entry(src); !ok {
+			return m, fmt.Errorf("conflicted")
+		}
+	}
+
+	if dstInfo, err := os.Lstat(worktreeFile(root, dst)); err == nil {
+		if !force || info.IsDir() || dstInfo.IsDir() {
+			return m, fmt.Errorf("destination exists")
+		}
+		m.overwrite = isTracked(index, dst)
+		m.replace = true
+	}
+	if parent := path.Dir(dst); parent != "." {
+		if info, err := os.Stat(worktreeFile(root, parent)); err != nil || !info.IsDir() {
+			return m, fmt.Errorf("destination directory does not exist")
+		}
+	}
+	return m, nil
+}
+
+// renameEntries moves the index entry for a file, or the entries under a
+// directory, to a new path. The stat data is kept since a rename does not
+// change the contents.
+func renameEntries(index *staging.Index, src, dst string) error {
+	var renamed []staging.IndexEntry
+	entries := index.Entries[:0]
+	for _, entry := range index.Entries {
+		switch {
+		case entry.FilePath == src:
+			entry.FilePath = dst
+		case strings.HasPrefix(entry.FilePath, src+"/"):
+			entry.FilePath = dst + strings.TrimPrefix(entry.FilePath, src)
+		default:
+			entries = append(entries, entry)
+			continue
+		}
+		renamed = append(renamed, entry)
+	}
+	index.Entries = entries
+	for _, entry := range renamed {
+		if err := index.Add(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// worktreeFile converts a slash-separated path relative to the root into a
+// worktree path.
+func worktreeFile(root, p string) string {
+	return filepath.Join(root, filepath.FromSlash(p))
+}
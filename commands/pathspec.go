@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// resolvePathspecs turns command-line paths, which are relative to the
+// current directory, into patterns relative to the worktree root using
+// forward slashes. The root itself becomes the empty pattern.
+func resolvePathspecs(root string, args []string) ([]string, error) {
+	specs := make([]string, 0, len(args))
+	for _, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve path %s: %w", arg, err)
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: '%s' is outside repository at '%s'", arg, abs, root)
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		specs = append(specs, rel)
+	}
+	return specs, nil
+}
+
+// matchPathspec reports whether a worktree path is selected by a pattern:
+// the pattern names the path itself or one of its parent directories, or
+// it is a glob matching the path. As in Git, `*` in a pathspec also matches
+// across directory separators.
+func matchPathspec(spec, p string) bool {
+	if spec == "" || p == spec || strings.HasPrefix(p, spec+"/") {
+		return true
+	}
+	return hasGlob(spec) && wildmatch(spec, p)
+}
+
+// hasGlob reports whether a pattern contains glob metacharacters.
+func hasGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// wildmatch matches name against a glob in which `*` matches any sequence
+// of characters, including slashes.
+func wildmatch(pattern, name string) bool {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if wildmatch(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if name == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(name)
+			pattern, name = pattern[1:], name[size:]
+			continue
+		case '[':
+			if end := classEnd(pattern); end > 0 {
+				if name == "" {
+					return false
+				}
+				r, size := utf8.DecodeRuneInString(name)
+				class := pattern[:end+1]
+				if strings.HasPrefix(class, "[!") {
+					class = "[^" + class[2:]
+				}
+				if ok, err := path.Match(class, string(r)); err != nil || !ok {
+					return false
+				}
+				pattern, name = pattern[end+1:], name[size:]
+				continue
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+		}
+		if name == "" || name[0] != pattern[0] {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return name == ""
+}
+
+// classEnd returns the index of the `]` closing the character class that
+// starts the pattern, or -1 if it is not closed.
+func classEnd(pattern string) int {
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	if end := strings.IndexByte(pattern[i:], ']'); end >= 0 {
+		return i + end
+	}
+	return -1
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/merge"
+	"gopract/refs"
+	"gopract/revparse"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReflogExpireOptions controls which reflog entries `reflog expire` drops.
+type ReflogExpireOptions struct {
+	Expire            time.Time // Drop entries older than this; zero keeps them
+	ExpireUnreachable time.Time // Drop entries older than this whose commit the ref no longer reaches; zero keeps them
+	All               bool      // Expire the reflogs of every ref
+	DryRun            bool      // Only list the entries that would be dropped
+}
+
+// ReflogShow prints the reflog of a ref (HEAD by default), newest entry
+// first, in the `<sha> <ref>@{<n>}: <message>` form Git uses. maxCount
+// limits the number of entries when positive.
+func ReflogShow(repoPath, ref string, maxCount int) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+	name, err := reflogName(repoPath, ref)
+	if err != nil {
+		return err
+	}
+	entries, err := refs.ReadReflog(repoPath, name)
+	if err != nil {
+		return err
+	}
+
+	for n := 0; n < len(entries); n++ {
+		if maxCount > 0 && n >= maxCount {
+			break
+		}
+		entry := entries[len(entries)-1-n]
+		short, err := revparse.Abbreviate(repoPath, entry.New, 7)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s@{%d}: %s\n", short, ref, n, entry.Message)
+	}
+	return nil
+}
+
+// ReflogExpire drops old entries from the reflogs of the given refs, or of
+// every ref with opts.All. Entries for commits the ref can no longer reach
+// (after a reset or an amended commit, say) expire sooner, at
+// opts.ExpireUnreachable.
+func ReflogExpire(repoPath string, names []string, opts ReflogExpireOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	var logs []string
+	if opts.All {
+		all, err := refs.ListReflogs(repoPath)
+		if err != nil {
+			return err
+		}
+		logs = all
+	}
+	for _, ref := range names {
+		name, err := reflogName(repoPath, ref)
+		if err != nil {
+			return err
+		}
+		logs = append(logs, name)
+	}
+	if len(logs) == 0 {
+		return errors.New("no reflog specified; name a ref or use --all")
+	}
+
+	for _, name := range logs {
+		entries, err := refs.ReadReflog(repoPath, name)
+		if err != nil {
+			return err
+		}
+
+		// Commits the ref still reaches are only needed for the
+		// unreachable cutoff
+		var reachable map[string]bool
+		if tip, err := refs.Resolve(repoPath, name); err == nil && !opts.ExpireUnreachable.IsZero() {
+			if tip, err = peelToCommit(repoPath, tip); err == nil {
+				if reachable, err = merge.Ancestors(repoPath, tip); err != nil {
+					return err
+				}
+			}
+		}
+
+		var kept []refs.ReflogEntry
+		expired := 0
+		for i, entry := range entries {
+			when := entry.Committer.When
+			drop := !opts.Expire.IsZero() && when.Before(opts.Expire) ||
+				!opts.ExpireUnreachable.IsZero() && when.Before(opts.ExpireUnreachable) && !reachable[entry.New]
+			if !drop {
+				kept = append(kept, entry)
+				continue
+			}
+			expired++
+			if opts.DryRun {
+				fmt.Printf("Would expire %s@{%d}: %s\n", refs.ShortName(name), len(entries)-1-i, entry.Message)
+			}
+		}
+		if expired == 0 || opts.DryRun {
+			continue
+		}
+		if err := refs.WriteReflog(repoPath, name, kept); err != nil {
+			return err
+		}
+		fmt.Printf("Expired %d entries from the reflog of %s\n", expired, refs.ShortName(name))
+	}
+	return nil
+}
+
+// reflogName turns a ref as given on the command line (HEAD, a branch or a
+// full ref name) into the name its reflog is stored under.
+func reflogName(repoPath, ref string) (string, error) {
+	if ref == "HEAD" || ref == "@" {
+		return "HEAD", nil
+	}
+	if found, err := refs.Lookup(repoPath, ref); err == nil {
+		return found.Name, nil
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		return "", err
+	}
+	// A deleted ref may still have a log when given by its full name
+	if entries, err := refs.ReadReflog(repoPath, ref); err == nil && len(entries) > 0 {
+		return ref, nil
+	}
+	return "", fmt.Errorf("%w: %s", revparse.ErrUnknownRevision, ref)
+}
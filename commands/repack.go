@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/objects"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repack consolidates every loose and packed object into a single packfile.
+// When deleteRedundant is set, loose objects and old packs that are now
+// covered by the new pack are removed.
+func Repack(repoPath string, deleteRedundant bool) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	loose, err := objects.ListLooseObjects(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list loose objects: %w", err)
+	}
+
+	oldPacks, err := objects.OpenPackfiles(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open packfiles: %w", err)
+	}
+
+	shas := append([]string{}, loose...)
+	seen := make(map[string]bool, len(loose))
+	for _, sha := range loose {
+		seen[sha] = true
+	}
+	for _, pack := range oldPacks {
+		for i := 0; i < pack.Index.Count(); i++ {
+			if sha := pack.Index.Hash(i); !seen[sha] {
+				seen[sha] = true
+				shas = append(shas, sha)
+			}
+		}
+	}
+
+	if len(shas) == 0 {
+		fmt.Println("Nothing new to pack.")
+		return nil
+	}
+
+	packPath, err := objects.WritePackfile(repoPath, shas)
+	if err != nil {
+		return fmt.Errorf("failed to write packfile: %w", err)
+	}
+	fmt.Printf("Packed %d objects into %s\n", len(shas), filepath.Base(packPath))
+
+	if !deleteRedundant {
+		return nil
+	}
+
+	// Remove the old packs that the new one supersedes
+	for _, pack := range oldPacks {
+		if pack.Path == packPath {
+			continue
+		}
+		base := strings.TrimSuffix(pack.Path, ".pack")
+		for _, ext := range []string{".pack", ".idx"} {
+			if err := os.Remove(base + ext); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove old pack %s: %w", base+ext, err)
+			}
+		}
+	}
+
+	// Remove loose objects that are now stored in the pack
+	objectsDir := filepath.Join(gitDir, "objects")
+	for _, sha := range loose {
+		if err := os.Remove(filepath.Join(objectsDir, sha[:2], sha[2:])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove loose object %s: %w", sha, err)
+		}
+		// Drop the fan-out directory once it is empty
+		os.Remove(filepath.Join(objectsDir, sha[:2]))
+	}
+	fmt.Printf("Removed %d loose objects\n", len(loose))
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/checkout"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/repository"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Modes supported by `reset`.
+const (
+	ResetSoft  = "soft"  // Move the branch only
+	ResetMixed = "mixed" // Move the branch and reset the index
+	ResetHard  = "hard"  // Move the branch and reset the index and worktree
+)
+
+// ResetOptions selects what `reset` moves and how much it rewrites.
+type ResetOptions struct {
+	Mode     string   // One of the Reset* modes; defaults to ResetMixed
+	Revision string   // Commit to reset to (HEAD by default)
+	Paths    []string // Restore only these index entries, relative to the current directory
+}
+
+// stateFiles are removed by a reset, which ends any merge, cherry-pick or
+// revert in progress.
+var stateFiles = []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "CHERRY_PICK_HEAD", "REVERT_HEAD"}
+
+// Reset moves the current branch (or a detached HEAD) to a commit and, with
+// the mixed and hard modes, rewrites the index and worktree to match it.
+// With paths only the index entries for those paths are restored from the
+// commit and HEAD stays where it is.
+func Reset(repoPath string, opts ResetOptions) error {
+	repo, err := repository.NewRepository(repoPath, false)
+	if err != nil {
+		return err
+	}
+	if opts.Mode == "" {
+		opts.Mode = ResetMixed
+	}
+	if len(opts.Paths) > 0 {
+		if opts.Mode != ResetMixed {
+			return fmt.Errorf("cannot do a %s reset with paths", opts.Mode)
+		}
+		return resetPaths(repo, opts.Revision, opts.Paths)
+	}
+
+	oldCommit, oldTree, err := headTree(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// Resetting an unborn HEAD to itself just empties the index
+	revision := opts.Revision
+	if revision == "" {
+		revision = "HEAD"
+	}
+	var newCommit, newTree string
+	if revision != "HEAD" || oldCommit != "" {
+		sha, err := resolveRevision(repoPath, revision)
+		if err != nil {
+			return fmt.Errorf("ambiguous argument '%s': unknown revision", revision)
+		}
+		if newCommit, err = peelToCommit(repoPath, sha); err != nil {
+			return err
+		}
+		commit, err := readCommit(repoPath, newCommit)
+		if err != nil {
+			return err
+		}
+		newTree = commit.Tree
+	}
+
+	if opts.Mode == ResetSoft {
+		if _, err := os.Stat(filepath.Join(repo.Gitdir, "MERGE_HEAD")); err == nil {
+			return errors.New("cannot do a soft reset in the middle of a merge")
+		}
+	}
+
+	switch opts.Mode {
+	case ResetSoft:
+	case ResetMixed:
+		index, err := staging.ReadIndex(repoPath)
+		if err != nil {
+			return fmt.Errorf("failed to read index: %w", err)
+		}
+		files, err := flattenTree(repoPath, newTree)
+		if err != nil {
+			return err
+		}
+		updated := &staging.Index{Version: index.Version}
+		for p, treeEntry := range files {
+			entry, err := resetEntry(index, p, treeEntry)
+			if err != nil {
+				return err
+			}
+			updated.Entries = append(updated.Entries, entry)
+		}
+		sort.Slice(updated.Entries, func(i, j int) bool { return updated.Entries[i].FilePath < updated.Entries[j].FilePath })
+		if err := writeResetIndex(repo, updated); err != nil {
+			return err
+		}
+	case ResetHard:
+		if err := checkout.Tree(repo, oldTree, newTree, checkout.Options{Force: true}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown reset mode: %s", opts.Mode)
+	}
+
+	if newCommit != "" {
+		if oldCommit != "" {
+			if err := os.WriteFile(filepath.Join(repo.Gitdir, "ORIG_HEAD"), []byte(oldCommit+"\n"), 0644); err != nil {
+				return fmt.Errorf("failed to write ORIG_HEAD: %w", err)
+			}
+		}
+		if err := refs.Update(repoPath, "HEAD", newCommit, "", "reset: moving to "+revision); err != nil {
+			return fmt.Errorf("failed to update HEAD: %w", err)
+		}
+	}
+	if opts.Mode != ResetSoft {
+		for _, name := range stateFiles {
+			os.Remove(filepath.Join(repo.Gitdir, name))
+		}
+	}
+
+	if opts.Mode == ResetHard && newCommit != "" {
+		commit, err := readCommit(repoPath, newCommit)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD is now at %s %s\n", newCommit[:7], firstLine(commit.Message))
+	}
+	return nil
+}
+
+// resetPaths restores the index entries matching the pathspecs to their
+// state in a commit (HEAD by default), removing those the commit lacks. The
+// worktree is left alone.
+func resetPaths(repo *repository.Repository, revision string, pathspecs []string) error {
+	specs, err := resolvePathspecs(repo.Worktree, pathspecs)
+	if err != nil {
+		return err
+	}
+
+	var files map[string]objects.TreeEntry
+	if revision == "" {
+		if files, err = headTreeEntries(repo.Worktree); err != nil {
+			return err
+		}
+	} else {
+		sha, err := resolveRevision(repo.Worktree, revision)
+		if err != nil {
+			return fmt.Errorf("ambiguous argument '%s': unknown revision", revision)
+		}
+		tree, err := peelToTree(repo.Worktree, sha)
+		if err != nil {
+			return err
+		}
+		if files, err = flattenTree(repo.Worktree, tree); err != nil {
+			return err
+		}
+	}
+
+	index, err := staging.ReadIndex(repo.Worktree)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	selected := func(p string) bool {
+		for _, spec := range specs {
+			if matchPathspec(spec, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Collect first: restoring entries rewrites the slice being read
+	var removals []string
+	for _, entry := range index.Entries {
+		if _, inTree := files[entry.FilePath]; !inTree && selected(entry.FilePath) {
+			removals = append(removals, entry.FilePath)
+		}
+	}
+	for _, p := range removals {
+		index.Remove(p)
+	}
+	for p, treeEntry := range files {
+		if !selected(p) {
+			continue
+		}
+		entry, err := resetEntry(index, p, treeEntry)
+		if err != nil {
+			return err
+		}
+		if err := index.Add(entry); err != nil {
+			return err
+		}
+	}
+	return writeResetIndex(repo, index)
+}
+
+// resetEntry returns the index entry recording a tree entry, keeping the
+// stat data of the current entry when it already records the same blob so
+// that the file need not be re-hashed.
+func resetEntry(index *staging.Index, p string, treeEntry objects.TreeEntry) (staging.IndexEntry, error) {
+	if entry, ok := index.Entry(p); ok && entry.BlobHash == treeEntry.Hash && fmt.Sprintf("%o", entry.Mode) == treeEntry.Mode {
+		return *entry, nil
+	}
+	mode, err := strconv.ParseUint(treeEntry.Mode, 8, 32)
+	if err != nil {
+		return staging.IndexEntry{}, fmt.Errorf("invalid mode %s for %s", treeEntry.Mode, p)
+	}
+	return staging.IndexEntry{Mode: uint32(mode), BlobHash: treeEntry.Hash, FilePath: p}, nil
+}
+
+// writeResetIndex refreshes the stat data of unchanged files, writes the
+// index and lists the files whose worktree contents differ from it.
+func writeResetIndex(repo *repository.Repository, index *staging.Index) error {
+	trustFileMode := staging.TrustFileMode(repo.Worktree)
+	var unstaged []string
+	for i, entry := range index.Entries {
+		if entry.Stage != 0 || fmt.Sprintf("%o", entry.Mode) == objects.ModeGitlink {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(repo.Worktree, filepath.FromSlash(entry.FilePath)))
+		if err != nil {
+			unstaged = append(unstaged, "D\t"+entry.FilePath)
+			continue
+		}
+		clean, err := staging.FileMatches(repo.Worktree, entry, info, trustFileMode)
+		if err != nil {
+			return err
+		}
+		if !clean {
+			unstaged = append(unstaged, "M\t"+entry.FilePath)
+			continue
+		}
+		fresh := staging.NewEntry(entry.FilePath, entry.BlobHash, info)
+		fresh.Mode = entry.Mode
+		index.Entries[i] = fresh
+	}
+
+	if err := staging.WriteIndex(repo.Worktree, index); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	if len(unstaged) > 0 {
+		fmt.Println("Unstaged changes after reset:")
+		for _, line := range unstaged {
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+// flattenTree lists the files of a tree, or nothing for an empty tree hash.
+func flattenTree(repoPath, treeHash string) (map[string]objects.TreeEntry, error) {
+	if treeHash == "" {
+		return map[string]objects.TreeEntry{}, nil
+	}
+	return objects.FlattenTree(repoPath, treeHash)
+}
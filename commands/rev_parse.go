@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/revparse"
+	"os"
+	"path/filepath"
+)
+
+// RevParseOptions controls how `rev-parse` prints what it resolves.
+type RevParseOptions struct {
+	Verify    bool // Require exactly one revision naming an existing object
+	Short     int  // Abbreviate SHAs to at least this many characters (0 prints them in full)
+	AbbrevRef bool // Print the short ref name instead of the SHA
+}
+
+// RevParse resolves each revision and prints the object it names, one per
+// line.
+func RevParse(repoPath string, revisions []string, opts RevParseOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	if opts.Verify && len(revisions) != 1 {
+		return fmt.Errorf("needed a single revision")
+	}
+
+	for _, rev := range revisions {
+		if opts.AbbrevRef {
+			name, err := abbrevRef(repoPath, rev)
+			if err != nil {
+				return err
+			}
+			fmt.Println(name)
+			continue
+		}
+
+		sha, err := resolveRevision(repoPath, rev)
+		if err != nil {
+			return err
+		}
+		if opts.Verify && !objects.HasObject(repoPath, sha) {
+			return fmt.Errorf("needed a single revision: %s does not exist", sha)
+		}
+		if opts.Short > 0 {
+			if sha, err = revparse.Abbreviate(repoPath, sha, opts.Short); err != nil {
+				return err
+			}
+		}
+		fmt.Println(sha)
+	}
+	return nil
+}
+
+// abbrevRef returns the shortest unambiguous name of the ref a revision
+// names, such as "master" for HEAD. A detached HEAD stays "HEAD".
+func abbrevRef(repoPath, rev string) (string, error) {
+	if rev == "HEAD" || rev == "@" {
+		branch, onBranch, err := refs.CurrentBranch(repoPath)
+		if err != nil {
+			return "", err
+		}
+		if !onBranch {
+			return "HEAD", nil
+		}
+		return refs.ShortName(branch), nil
+	}
+
+	ref, err := refs.Lookup(repoPath, rev)
+	if errors.Is(err, refs.ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", revparse.ErrUnknownRevision, rev)
+	}
+	if err != nil {
+		return "", err
+	}
+	return refs.ShortName(ref.Name), nil
+}
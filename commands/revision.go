@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/objects"
+	"gopract/revparse"
+)
+
+// resolveRevision turns any revision Git understands (HEAD, ref names,
+// abbreviated SHAs, HEAD~2, v1.0^{tree}, HEAD:path, ...) into an object SHA.
+func resolveRevision(repoPath, rev string) (string, error) {
+	return revparse.Resolve(repoPath, rev)
+}
+
+// readCommit reads a commit object, peeling annotated tags along the way.
+func readCommit(repoPath, sha string) (*objects.Commit, error) {
+	sha, err := peelToCommit(repoPath, sha)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := objects.ReadObject(repoPath, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", sha, err)
+	}
+	return obj.(*objects.Commit), nil
+}
+
+// peelToCommit resolves a SHA that may name an annotated tag to the commit
+// it ultimately points at.
+func peelToCommit(repoPath, sha string) (string, error) {
+	return revparse.Peel(repoPath, sha, "commit")
+}
+
+// peelToTree resolves a commit, tag or tree hash to a tree hash.
+func peelToTree(repoPath, sha string) (string, error) {
+	return revparse.Peel(repoPath, sha, "tree")
+}
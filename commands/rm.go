@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RmOptions controls what `rm` removes and how careful it is.
+type RmOptions struct {
+	Cached    bool // Only remove the paths from the index, keeping the files
+	Recursive bool // Allow a pathspec naming a directory to remove what is under it
+	Force     bool // Skip the checks that protect modified files
+	DryRun    bool // Only list the files that would be removed
+}
+
+// Rm removes the tracked files matched by the given pathspecs, which are
+// relative to the current directory, from the index and (unless Cached is
+// set) from the worktree. Unless Force is set it refuses to remove files
+// whose changes would be lost: local modifications, or staged content that
+// exists neither in HEAD nor in the worktree.
+func Rm(repoPath string, pathspecs []string, opts RmOptions) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+	if len(pathspecs) == 0 {
+		return fmt.Errorf("no pathspec given; which files should be removed?")
+	}
+
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	specs, err := resolvePathspecs(root, pathspecs)
+	if err != nil {
+		return err
+	}
+	index, err := staging.ReadIndex(root)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	// Every pathspec has to match something, and a directory only with -r
+	selected := make(map[string]bool)
+	for i, spec := range specs {
+		matched := false
+		for _, entry := range index.Entries {
+			p := entry.FilePath
+			if !matchPathspec(spec, p) {
+				continue
+			}
+			if !opts.Recursive && p != spec && !(hasGlob(spec) && wildmatch(spec, p)) {
+				return fmt.Errorf("not removing '%s' recursively without -r", pathspecs[i])
+			}
+			selected[p] = true
+			matched = true
+		}
+		if !matched {
+			return fmt.Errorf("pathspec '%s' did not match any files", pathspecs[i])
+		}
+	}
+	paths := make([]string, 0, len(selected))
+	for p := range selected {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	if !opts.Force {
+		if err := checkRemovable(root, index, paths, opts.Cached); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range paths {
+		fmt.Printf("rm '%s'\n", p)
+		if opts.DryRun {
+			continue
+		}
+		index.Remove(p)
+		if !opts.Cached {
+			if err := removeWorktreeFile(root, p); err != nil {
+				return err
+			}
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+	if err := staging.WriteIndex(root, index); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// checkRemovable reports the files `rm` would lose changes to. Removing a
+// file from the worktree loses its local modifications and, unless they are
+// also in the worktree, its staged changes; removing it from the index only
+// loses staged content found in neither HEAD nor the worktree.
+func checkRemovable(root string, index *staging.Index, paths []string, cached bool) error {
+	headEntries, err := headTreeEntries(root)
+	if err != nil {
+		return err
+	}
+	trustFileMode := staging.TrustFileMode(root)
+
+	var both, staged, local []string
+	for _, p := range paths {
+		entry, ok := index.Entry(p)
+		if !ok {
+			continue // Unmerged paths may always be removed
+		}
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(p)))
+		if err != nil || info.IsDir() {
+			continue // Already gone from the worktree
+		}
+
+		headEntry, inHead := headEntries[p]
+		stagedChange := !inHead || headEntry.Hash != entry.BlobHash || headEntry.Mode != fmt.Sprintf("%o", entry.Mode)
+		clean, err := staging.FileMatches(root, *entry, info, trustFileMode)
+		if err != nil {
+			return err
+		}
+		switch {
+		case stagedChange && !clean:
+			both = append(both, p)
+		case cached:
+		case stagedChange:
+			staged = append(staged, p)
+		case !clean:
+			local = append(local, p)
+		}
+	}
+
+	var b strings.Builder
+	describe := func(files []string, what, hint string) {
+		if len(files) == 0 {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if len(files) == 1 {
+			b.WriteString("the following file has " + what + ":\n")
+		} else {
+			b.WriteString("the following files have " + what + ":\n")
+		}
+		for _, p := range files {
+			fmt.Fprintf(&b, "    %s\n", p)
+		}
+		b.WriteString(hint)
+	}
+	describe(both, "staged content different from both the\nfile and the HEAD", "(use -f to force removal)")
+	describe(staged, "changes staged in the index", "(use --cached to keep the file, or -f to force removal)")
+	describe(local, "local modifications", "(use --cached to keep the file, or -f to force removal)")
+	if b.Len() > 0 {
+		return errors.New(b.String())
+	}
+	return nil
+}
+
+// removeWorktreeFile deletes a file along with any directories that become
+// empty. A file that is already gone is not an error.
+func removeWorktreeFile(root, p string) error {
+	fullPath := filepath.Join(root, filepath.FromSlash(p))
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", p, err)
+	}
+	for dir := filepath.Dir(fullPath); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,514 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gopract/checkout"
+	"gopract/merge"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/repository"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SequencerOptions controls `cherry-pick` and `revert`.
+type SequencerOptions struct {
+	Mainline int  // Parent number (from 1) a merge commit is compared against (-m)
+	Continue bool // Commit the resolved conflict and go on with the remaining commits
+	Abort    bool // Give up and return to the commit HEAD was at before starting
+}
+
+// Actions the sequencer performs, as written in its todo list.
+const (
+	actionPick   = "pick"
+	actionRevert = "revert"
+)
+
+// sequencer applies a list of commits one by one, keeping its state in
+// `.git/sequencer` (in the layout Git uses) so that it can stop at a
+// conflict and be continued or aborted later.
+type sequencer struct {
+	repo     *repository.Repository
+	dir      string // The `.git/sequencer` directory
+	action   string // actionPick or actionRevert
+	mainline int    // Parent to compare merge commits against, or 0
+}
+
+// CherryPick applies the changes introduced by each of the given commits on
+// top of HEAD, committing each with its original author and message.
+// Revisions may be ranges such as "A..B", which pick the commits reachable
+// from B but not from A, oldest first.
+func CherryPick(repoPath string, revisions []string, opts SequencerOptions) error {
+	return sequence(repoPath, actionPick, revisions, opts)
+}
+
+// Revert commits the inverse of the changes introduced by each of the given
+// commits. Ranges are reverted newest first.
+func Revert(repoPath string, revisions []string, opts SequencerOptions) error {
+	return sequence(repoPath, actionRevert, revisions, opts)
+}
+
+// sequence starts, continues or aborts a cherry-pick or revert.
+func sequence(repoPath, action string, revisions []string, opts SequencerOptions) error {
+	repo, err := repository.NewRepository(repoPath, false)
+	if err != nil {
+		return err
+	}
+	s := &sequencer{repo: repo, dir: filepath.Join(repo.Gitdir, "sequencer"), action: action, mainline: opts.Mainline}
+
+	if opts.Abort || opts.Continue {
+		if err := s.load(); err != nil {
+			return err
+		}
+		if opts.Abort {
+			return s.abort()
+		}
+		return s.resume()
+	}
+
+	if _, err := os.Stat(s.dir); err == nil {
+		return fmt.Errorf("a cherry-pick or revert is already in progress; use '%s --continue' or '%s --abort'", s.command(), s.command())
+	}
+	if _, err := os.Stat(filepath.Join(repo.Gitdir, "MERGE_HEAD")); err == nil {
+		return fmt.Errorf("you have not concluded your merge (MERGE_HEAD exists); commit or abort it first")
+	}
+	if len(revisions) == 0 {
+		return fmt.Errorf("no commits given to %s", s.command())
+	}
+
+	commits, err := s.expand(revisions)
+	if err != nil {
+		return err
+	}
+	head, err := refs.Resolve(repoPath, "HEAD")
+	if err != nil {
+		return fmt.Errorf("cannot %s onto a branch with no commits", s.command())
+	}
+	if err := checkIndexMatchesHead(repoPath, s.doing()); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create sequencer directory: %w", err)
+	}
+	opt := "[options]\n\taction = " + action + "\n"
+	if s.mainline > 0 {
+		opt += "\tmainline = " + strconv.Itoa(s.mainline) + "\n"
+	}
+	files := map[string]string{"head": head + "\n", "opts": opt}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(s.dir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write sequencer state: %w", err)
+		}
+	}
+	return s.run(commits, true)
+}
+
+// run applies commits in order. When one stops with a conflict the rest are
+// saved as the todo list; when the first one cannot even be attempted
+// nothing is left in progress.
+func (s *sequencer) run(commits []string, starting bool) error {
+	for i, sha := range commits {
+		conflicted, err := s.apply(sha)
+		if err == nil {
+			continue
+		}
+		if !conflicted && i == 0 && starting {
+			os.RemoveAll(s.dir)
+			return err
+		}
+		remaining := commits[i:]
+		if conflicted {
+			remaining = commits[i+1:]
+		}
+		if saveErr := s.saveTodo(remaining); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("failed to remove sequencer state: %w", err)
+	}
+	return nil
+}
+
+// apply cherry-picks or reverts one commit with a three-way merge and
+// commits the result. On a conflict the merge is left in the worktree and
+// index, the pick head and message are written for `--continue`, and
+// conflicted is true.
+func (s *sequencer) apply(sha string) (conflicted bool, err error) {
+	repoPath := s.repo.Worktree
+	commit, err := readCommit(repoPath, sha)
+	if err != nil {
+		return false, err
+	}
+
+	// Pick the parent the change is measured against
+	var parent string
+	switch {
+	case len(commit.Parents) > 1 && s.mainline == 0:
+		return false, fmt.Errorf("commit %s is a merge but no -m option was given", sha)
+	case len(commit.Parents) > 1 && s.mainline > len(commit.Parents):
+		return false, fmt.Errorf("commit %s does not have parent %d", sha, s.mainline)
+	case len(commit.Parents) > 1:
+		parent = commit.Parents[s.mainline-1]
+	case s.mainline > 0:
+		return false, fmt.Errorf("mainline was specified but commit %s is not a merge", sha)
+	case len(commit.Parents) == 1:
+		parent = commit.Parents[0]
+	}
+	var parentTree string
+	if parent != "" {
+		parentCommit, err := readCommit(repoPath, parent)
+		if err != nil {
+			return false, err
+		}
+		parentTree = parentCommit.Tree
+	}
+
+	head, headTreeHash, err := headTree(repoPath)
+	if err != nil {
+		return false, err
+	}
+
+	subject := firstLine(commit.Message)
+	label := fmt.Sprintf("%s (%s)", sha[:7], subject)
+	baseLabel := "parent of " + label
+	base, theirs := parentTree, commit.Tree
+	message := commit.Message
+	author := commit.Author
+	if s.action == actionRevert {
+		base, theirs = commit.Tree, parentTree
+		label, baseLabel = baseLabel, label
+		message = fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.\n", subject, sha)
+		if len(commit.Parents) > 1 {
+			message = fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s, reversing\nchanges made to %s.\n", subject, sha, parent)
+		}
+		author = objects.Signature{}
+	}
+
+	result, err := merge.Trees(repoPath, base, headTreeHash, theirs, merge.ContentOptions{
+		OursLabel:   "HEAD",
+		BaseLabel:   baseLabel,
+		TheirsLabel: label,
+	})
+	if err != nil {
+		return false, err
+	}
+	mergedTree, err := writeMergedTree(repoPath, result)
+	if err != nil {
+		return false, err
+	}
+	if err := checkConflictPaths(s.repo, result.Conflicts); err != nil {
+		return false, err
+	}
+	if err := checkout.Tree(s.repo, headTreeHash, mergedTree, checkout.Options{Operation: s.command()}); err != nil {
+		return false, err
+	}
+
+	if len(result.Conflicts) > 0 {
+		if err := recordConflicts(s.repo, result.Conflicts); err != nil {
+			return false, err
+		}
+		var msg strings.Builder
+		msg.WriteString(cleanupMessage(message) + "\n# Conflicts:\n")
+		for _, c := range result.Conflicts {
+			fmt.Fprintf(&msg, "#\t%s\n", c.Path)
+		}
+		files := map[string]string{s.pickHead(): sha + "\n", "MERGE_MSG": msg.String()}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(s.repo.Gitdir, name), []byte(content), 0644); err != nil {
+				return false, fmt.Errorf("failed to write %s: %w", name, err)
+			}
+		}
+		printConflicts(result.Conflicts, "HEAD", label)
+		return true, fmt.Errorf("could not %s %s... %s; fix conflicts, add the resolved files and run '%s --continue'",
+			s.verb(), sha[:7], subject, s.command())
+	}
+
+	if mergedTree == headTreeHash {
+		fmt.Printf("The %s of %s is empty; skipping it\n", s.command(), sha[:7])
+		return false, nil
+	}
+	return false, s.commit(mergedTree, head, message, author)
+}
+
+// commit records a picked or reverted tree on top of head.
+func (s *sequencer) commit(treeHash, head, message string, author objects.Signature) error {
+	commitHash, err := storeCommit(s.repo.Worktree, &objects.Commit{Tree: treeHash, Parents: []string{head}, Author: author, Message: message}, false)
+	if err != nil {
+		return err
+	}
+	if err := refs.Update(s.repo.Worktree, "HEAD", commitHash, head, s.command()+": "+firstLine(message)); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+
+	branch := "detached HEAD"
+	if current, onBranch, err := refs.CurrentBranch(s.repo.Worktree); err == nil && onBranch {
+		branch = refs.ShortName(current)
+	}
+	fmt.Printf("[%s %s] %s\n", branch, commitHash[:7], firstLine(message))
+	return nil
+}
+
+// resume commits the resolved conflict, unless it has been committed
+// already, and applies the rest of the todo list.
+func (s *sequencer) resume() error {
+	repoPath := s.repo.Worktree
+	pickHead := filepath.Join(s.repo.Gitdir, s.pickHead())
+	if data, err := os.ReadFile(pickHead); err == nil {
+		sha := strings.TrimSpace(string(data))
+		index, err := staging.ReadIndex(repoPath)
+		if err != nil {
+			return fmt.Errorf("failed to read index: %w", err)
+		}
+		for _, entry := range index.Entries {
+			if entry.Stage != 0 {
+				return fmt.Errorf("you need to resolve your current index first: %s", entry.FilePath)
+			}
+		}
+		treeHash, err := staging.WriteTree(repoPath, index)
+		if err != nil {
+			return fmt.Errorf("failed to write tree object: %w", err)
+		}
+		head, err := refs.Resolve(repoPath, "HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to resolve HEAD: %w", err)
+		}
+
+		commit, err := readCommit(repoPath, sha)
+		if err != nil {
+			return err
+		}
+		message := readMergeMessage(s.repo.Gitdir)
+		if message == "" {
+			message = commit.Message
+		}
+		author := commit.Author
+		if s.action == actionRevert {
+			author = objects.Signature{}
+		}
+		if err := s.commit(treeHash, head, message, author); err != nil {
+			return err
+		}
+		for _, name := range []string{s.pickHead(), "MERGE_MSG"} {
+			os.Remove(filepath.Join(s.repo.Gitdir, name))
+		}
+	}
+
+	todo, err := s.loadTodo()
+	if err != nil {
+		return err
+	}
+	if err := checkIndexMatchesHead(repoPath, s.doing()); err != nil {
+		return err
+	}
+	return s.run(todo, false)
+}
+
+// abort returns HEAD, the index and the worktree to where they were before
+// the cherry-pick or revert started.
+func (s *sequencer) abort() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, "head"))
+	if err != nil {
+		return fmt.Errorf("failed to read sequencer state: %w", err)
+	}
+	if err := Reset(s.repo.Worktree, ResetOptions{Mode: ResetHard, Revision: strings.TrimSpace(string(data))}); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("failed to remove sequencer state: %w", err)
+	}
+	return nil
+}
+
+// load reads the options of the cherry-pick or revert in progress, which
+// must be the same kind of operation as the one requested.
+func (s *sequencer) load() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, "opts"))
+	if os.IsNotExist(err) {
+		return errors.New("no cherry-pick or revert in progress")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read sequencer state: %w", err)
+	}
+
+	action := actionPick
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "action":
+			action = strings.TrimSpace(value)
+		case "mainline":
+			s.mainline, _ = strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	if action != s.action {
+		inProgress := (&sequencer{action: action}).command()
+		return fmt.Errorf("a %s is in progress; use '%s --continue' or '%s --abort'", inProgress, inProgress, inProgress)
+	}
+	return nil
+}
+
+// saveTodo writes the commits still to be applied, one "<action> <sha>
+// <subject>" line each.
+func (s *sequencer) saveTodo(commits []string) error {
+	var b strings.Builder
+	for _, sha := range commits {
+		commit, err := readCommit(s.repo.Worktree, sha)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", s.action, sha, firstLine(commit.Message))
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, "todo"), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write sequencer state: %w", err)
+	}
+	return nil
+}
+
+// loadTodo reads the commits still to be applied.
+func (s *sequencer) loadTodo() ([]string, error) {
+	f, err := os.Open(filepath.Join(s.dir, "todo"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sequencer state: %w", err)
+	}
+	defer f.Close()
+
+	var commits []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			commits = append(commits, fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sequencer state: %w", err)
+	}
+	return commits, nil
+}
+
+// expand resolves the revisions to commits, expanding "A..B" ranges to the
+// commits reachable from B but not from A in the order they are applied:
+// oldest first when picking and newest first when reverting.
+func (s *sequencer) expand(revisions []string) ([]string, error) {
+	repoPath := s.repo.Worktree
+	var commits []string
+	for _, rev := range revisions {
+		from, to, isRange := strings.Cut(rev, "..")
+		if !isRange {
+			sha, err := resolveCommit(repoPath, rev)
+			if err != nil {
+				return nil, err
+			}
+			commits = append(commits, sha)
+			continue
+		}
+
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		fromSha, err := resolveCommit(repoPath, from)
+		if err != nil {
+			return nil, err
+		}
+		toSha, err := resolveCommit(repoPath, to)
+		if err != nil {
+			return nil, err
+		}
+		excluded, err := merge.Ancestors(repoPath, fromSha)
+		if err != nil {
+			return nil, err
+		}
+
+		// A depth-first walk that emits parents before children
+		var ordered []string
+		var visit func(sha string) error
+		visit = func(sha string) error {
+			if excluded[sha] {
+				return nil
+			}
+			excluded[sha] = true
+			commit, err := readCommit(repoPath, sha)
+			if err != nil {
+				return err
+			}
+			for _, parent := range commit.Parents {
+				if err := visit(parent); err != nil {
+					return err
+				}
+			}
+			ordered = append(ordered, sha)
+			return nil
+		}
+		if err := visit(toSha); err != nil {
+			return nil, err
+		}
+		if len(ordered) == 0 {
+			return nil, fmt.Errorf("empty commit set passed: %s", rev)
+		}
+		if s.action == actionRevert {
+			for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+				ordered[i], ordered[j] = ordered[j], ordered[i]
+			}
+		}
+		commits = append(commits, ordered...)
+	}
+	return commits, nil
+}
+
+// resolveCommit resolves a revision that must name a commit.
+func resolveCommit(repoPath, rev string) (string, error) {
+	sha, err := resolveRevision(repoPath, rev)
+	if err != nil {
+		return "", fmt.Errorf("bad revision '%s'", rev)
+	}
+	return peelToCommit(repoPath, sha)
+}
+
+// command returns the name of the command being run.
+func (s *sequencer) command() string {
+	if s.action == actionRevert {
+		return "revert"
+	}
+	return "cherry-pick"
+}
+
+// verb returns how errors describe applying a single commit.
+func (s *sequencer) verb() string {
+	if s.action == actionRevert {
+		return "revert"
+	}
+	return "apply"
+}
+
+// doing returns the operation as named in errors about local changes.
+func (s *sequencer) doing() string {
+	if s.action == actionRevert {
+		return "reverting"
+	}
+	return "cherry-picking"
+}
+
+// pickHead returns the file recording the commit being applied.
+func (s *sequencer) pickHead() string {
+	if s.action == actionRevert {
+		return "REVERT_HEAD"
+	}
+	return "CHERRY_PICK_HEAD"
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"gopract/config"
+	"gopract/objects"
+	"gopract/sshsig"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadSigningKey reads the SSH key configured as `user.signingkey`.
+func loadSigningKey(repoPath string) (ed25519.PrivateKey, error) {
+	path, ok := config.ReadValue(repoPath, "user", "signingkey")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("no signing key configured; set one with `set-config --key user.signingkey --value <path to ed25519 key>`")
+	}
+	if strings.HasPrefix(path, "key::") || strings.HasPrefix(path, "ssh-") {
+		return nil, fmt.Errorf("user.signingkey must be the path of a private key file")
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand %s: %w", path, err)
+		}
+		path = filepath.Join(home, rest)
+	}
+	return sshsig.LoadPrivateKey(path)
+}
+
+// signCommit signs a commit with the configured SSH key, storing the
+// signature in its "gpgsig" header.
+func signCommit(repoPath string, commit *objects.Commit) error {
+	key, err := loadSigningKey(repoPath)
+	if err != nil {
+		return err
+	}
+	payload, _, err := commit.SignedPayload()
+	if err != nil {
+		return fmt.Errorf("failed to serialize commit: %w", err)
+	}
+	signature := sshsig.Sign(key, sshsig.Namespace, payload)
+	commit.ExtraHeaders = append(commit.ExtraHeaders, objects.Header{
+		Key:   "gpgsig",
+		Value: strings.TrimSuffix(signature, "\n"),
+	})
+	return nil
+}
+
+// signTag signs a tag with the configured SSH key, appending the signature
+// to its message.
+func signTag(repoPath string, tag *objects.Tag) error {
+	key, err := loadSigningKey(repoPath)
+	if err != nil {
+		return err
+	}
+	payload, err := tag.Serialize()
+	if err != nil {
+		return fmt.Errorf("failed to serialize tag: %w", err)
+	}
+	tag.Message += sshsig.Sign(key, sshsig.Namespace, payload)
+	return nil
+}
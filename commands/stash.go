@@ -0,0 +1,394 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/checkout"
+	"gopract/diff"
+	"gopract/merge"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/repository"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stashRef holds the newest stash; its reflog is the stack of entries.
+const stashRef = "refs/stash"
+
+// StashPush records the staged and unstaged changes to tracked files and
+// resets the index and worktree to HEAD. As in Git the stash is a commit
+// whose tree is the worktree, with HEAD as its first parent and a commit of
+// the index as its second.
+func StashPush(repoPath, message string) error {
+	repo, err := repository.NewRepository(repoPath, false)
+	if err != nil {
+		return err
+	}
+
+	head, headTreeHash, err := headTree(repoPath)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return errors.New("you do not have the initial commit yet")
+	}
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			return fmt.Errorf("you need to resolve your current index first: %s", entry.FilePath)
+		}
+	}
+
+	indexTree, err := staging.WriteTree(repoPath, index)
+	if err != nil {
+		return fmt.Errorf("failed to write index tree: %w", err)
+	}
+	worktreeTree, err := writeWorktreeTree(repo, index)
+	if err != nil {
+		return err
+	}
+	if indexTree == headTreeHash && worktreeTree == headTreeHash {
+		fmt.Println("No local changes to save")
+		return nil
+	}
+
+	headCommit, err := readCommit(repoPath, head)
+	if err != nil {
+		return err
+	}
+	branch := "(no branch)"
+	if current, onBranch, err := refs.CurrentBranch(repoPath); err == nil && onBranch {
+		branch = refs.ShortName(current)
+	}
+	subject := fmt.Sprintf("%s: %s %s", branch, head[:7], firstLine(headCommit.Message))
+
+	indexCommit, err := storeCommit(repoPath, &objects.Commit{Tree: indexTree, Parents: []string{head}, Message: "index on " + subject}, false)
+	if err != nil {
+		return err
+	}
+	description := "WIP on " + subject
+	if message != "" {
+		description = "On " + branch + ": " + message
+	}
+	stashCommit, err := storeCommit(repoPath, &objects.Commit{Tree: worktreeTree, Parents: []string{head, indexCommit}, Message: description}, false)
+	if err != nil {
+		return err
+	}
+
+	// The reflog is the stack, so it must exist whatever the configuration
+	if err := refs.CreateReflog(repoPath, stashRef); err != nil {
+		return err
+	}
+	if err := refs.Update(repoPath, stashRef, stashCommit, "", description); err != nil {
+		return fmt.Errorf("failed to update %s: %w", stashRef, err)
+	}
+
+	if err := checkout.Tree(repo, headTreeHash, headTreeHash, checkout.Options{Force: true}); err != nil {
+		return err
+	}
+	fmt.Printf("Saved working directory and index state %s\n", description)
+	return nil
+}
+
+// StashList prints the stash entries, newest first.
+func StashList(repoPath string) error {
+	entries, err := refs.ReadReflog(repoPath, stashRef)
+	if err != nil {
+		return err
+	}
+	for n := 0; n < len(entries); n++ {
+		fmt.Printf("stash@{%d}: %s\n", n, entries[len(entries)-1-n].Message)
+	}
+	return nil
+}
+
+// StashShow prints the changes recorded in a stash entry (the newest by
+// default) as a diffstat, or as a patch when patch is set.
+func StashShow(repoPath, name string, patch bool) error {
+	_, stashCommit, err := lookupStash(repoPath, name)
+	if err != nil {
+		return err
+	}
+	commit, err := readStashCommit(repoPath, stashCommit)
+	if err != nil {
+		return err
+	}
+
+	opts := DiffOptions{
+		Revisions: []string{commit.Parents[0], stashCommit},
+		Format:    DiffStat,
+		Context:   diff.DefaultContext,
+		Algorithm: diff.Myers,
+	}
+	if patch {
+		opts.Format = DiffPatch
+	}
+	return Diff(repoPath, opts)
+}
+
+// StashApply re-applies a stash entry (the newest by default) on top of the
+// current worktree with a three-way merge against the commit it was made
+// on. The changes are left unstaged, apart from new files, unless
+// restoreIndex asks for the staged changes to be restored as well.
+func StashApply(repoPath, name string, restoreIndex bool) error {
+	_, stashCommit, err := lookupStash(repoPath, name)
+	if err != nil {
+		return err
+	}
+	return applyStash(repoPath, stashCommit, restoreIndex)
+}
+
+// StashPop applies a stash entry and drops it from the stack. The entry is
+// kept when applying it leaves conflicts.
+func StashPop(repoPath, name string, restoreIndex bool) error {
+	n, stashCommit, err := lookupStash(repoPath, name)
+	if err != nil {
+		return err
+	}
+	if err := applyStash(repoPath, stashCommit, restoreIndex); err != nil {
+		return err
+	}
+	return dropStash(repoPath, n)
+}
+
+// StashDrop removes a stash entry (the newest by default) from the stack.
+func StashDrop(repoPath, name string) error {
+	n, _, err := lookupStash(repoPath, name)
+	if err != nil {
+		return err
+	}
+	return dropStash(repoPath, n)
+}
+
+// lookupStash finds the stash entry named "stash@{n}" or just "n", or the
+// newest one when name is empty, returning its position and commit.
+func lookupStash(repoPath, name string) (int, string, error) {
+	n := 0
+	if name != "" {
+		spec := strings.TrimSuffix(strings.TrimPrefix(name, "stash@{"), "}")
+		var err error
+		if n, err = strconv.Atoi(spec); err != nil || n < 0 {
+			return 0, "", fmt.Errorf("'%s' is not a stash reference", name)
+		}
+	}
+
+	entries, err := refs.ReadReflog(repoPath, stashRef)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(entries) == 0 {
+		return 0, "", errors.New("no stash entries found")
+	}
+	if n >= len(entries) {
+		return 0, "", fmt.Errorf("stash@{%d} does not exist", n)
+	}
+	return n, entries[len(entries)-1-n].New, nil
+}
+
+// dropStash removes entry n from the stack, moving refs/stash to the next
+// entry when the newest one goes and deleting it with the last one.
+func dropStash(repoPath string, n int) error {
+	entries, err := refs.ReadReflog(repoPath, stashRef)
+	if err != nil {
+		return err
+	}
+	i := len(entries) - 1 - n
+	dropped := entries[i]
+	kept := append(entries[:i:i], entries[i+1:]...)
+
+	if len(kept) == 0 {
+		if err := refs.Delete(repoPath, stashRef); err != nil {
+			return err
+		}
+		fmt.Printf("Dropped stash@{%d} (%s)\n", n, dropped.New)
+		return nil
+	}
+
+	// Keep the chain of old and new values intact across the gap
+	if i < len(kept) {
+		kept[i].Old = refs.ZeroHash
+		if i > 0 {
+			kept[i].Old = kept[i-1].New
+		}
+	}
+	if n == 0 {
+		if err := refs.UpdateNoDeref(repoPath, stashRef, kept[len(kept)-1].New, ""); err != nil {
+			return fmt.Errorf("failed to update %s: %w", stashRef, err)
+		}
+	}
+	if err := refs.WriteReflog(repoPath, stashRef, kept); err != nil {
+		return err
+	}
+	fmt.Printf("Dropped stash@{%d} (%s)\n", n, dropped.New)
+	return nil
+}
+
+// applyStash merges the worktree state of a stash commit into the worktree,
+// and with restoreIndex its index state into the index.
+func applyStash(repoPath, stashCommit string, restoreIndex bool) error {
+	repo, err := repository.NewRepository(repoPath, false)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(repo.Gitdir, "MERGE_HEAD")); err == nil {
+		return errors.New("cannot apply a stash in the middle of a merge")
+	}
+
+	commit, err := readStashCommit(repoPath, stashCommit)
+	if err != nil {
+		return err
+	}
+	baseCommit, err := readCommit(repoPath, commit.Parents[0])
+	if err != nil {
+		return err
+	}
+	indexCommit, err := readCommit(repoPath, commit.Parents[1])
+	if err != nil {
+		return err
+	}
+
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			return fmt.Errorf("you need to resolve your current index first: %s", entry.FilePath)
+		}
+	}
+	currentTree, err := staging.WriteTree(repoPath, index)
+	if err != nil {
+		return fmt.Errorf("failed to write index tree: %w", err)
+	}
+	currentFiles, err := flattenTree(repoPath, currentTree)
+	if err != nil {
+		return err
+	}
+
+	labels := merge.ContentOptions{OursLabel: "Updated upstream", BaseLabel: "Stash base", TheirsLabel: "Stashed changes"}
+
+	// Work out the restored index before touching the worktree
+	indexFiles := currentFiles
+	if restoreIndex && indexCommit.Tree != baseCommit.Tree {
+		result, err := merge.Trees(repoPath, baseCommit.Tree, currentTree, indexCommit.Tree, labels)
+		if err != nil {
+			return err
+		}
+		if len(result.Conflicts) > 0 {
+			return errors.New("conflicts in index; try without --index")
+		}
+		indexFiles = result.Files
+	}
+
+	result, err := merge.Trees(repoPath, baseCommit.Tree, currentTree, commit.Tree, labels)
+	if err != nil {
+		return err
+	}
+	mergedTree, err := writeMergedTree(repoPath, result)
+	if err != nil {
+		return err
+	}
+
+	if err := checkConflictPaths(repo, result.Conflicts); err != nil {
+		return err
+	}
+	if err := checkout.Tree(repo, currentTree, mergedTree, checkout.Options{Operation: "merge"}); err != nil {
+		return err
+	}
+	if len(result.Conflicts) > 0 {
+		if err := recordConflicts(repo, result.Conflicts); err != nil {
+			return err
+		}
+		printConflicts(result.Conflicts, labels.OursLabel, labels.TheirsLabel)
+		return errors.New("conflicts in stashed changes; the stash entry is kept in case you need it again")
+	}
+
+	// Unstage what the stash changed, except that new files stay added
+	// (or, with restoreIndex, put back what was staged)
+	index, err = staging.ReadIndex(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	if restoreIndex {
+		var unstaged []string
+		for _, entry := range index.Entries {
+			if _, ok := indexFiles[entry.FilePath]; !ok {
+				unstaged = append(unstaged, entry.FilePath)
+			}
+		}
+		for _, p := range unstaged {
+			index.Remove(p)
+		}
+	}
+	for p, treeEntry := range indexFiles {
+		entry, err := resetEntry(index, p, treeEntry)
+		if err != nil {
+			return err
+		}
+		if err := index.Add(entry); err != nil {
+			return err
+		}
+	}
+	if err := staging.WriteIndex(repoPath, index); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return Status(repoPath, false)
+}
+
+// readStashCommit reads a commit and makes sure it has the shape of a
+// stash: HEAD and index commits as its parents.
+func readStashCommit(repoPath, sha string) (*objects.Commit, error) {
+	commit, err := readCommit(repoPath, sha)
+	if err != nil {
+		return nil, err
+	}
+	if len(commit.Parents) < 2 {
+		return nil, fmt.Errorf("%s is not a stash-like commit", sha)
+	}
+	return commit, nil
+}
+
+// writeWorktreeTree writes the tree of the tracked files as they are in
+// the worktree: modified files are stored as new blobs and deleted ones are
+// left out.
+func writeWorktreeTree(repo *repository.Repository, index *staging.Index) (string, error) {
+	trustFileMode := staging.TrustFileMode(repo.Worktree)
+	worktree := &staging.Index{Version: index.Version}
+	for _, entry := range index.Entries {
+		if fmt.Sprintf("%o", entry.Mode) == objects.ModeGitlink {
+			worktree.Entries = append(worktree.Entries, entry)
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(repo.Worktree, filepath.FromSlash(entry.FilePath)))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		clean, err := staging.FileMatches(repo.Worktree, entry, info, trustFileMode)
+		if err != nil {
+			return "", err
+		}
+		if !clean {
+			data, err := staging.ReadFile(repo.Worktree, entry.FilePath)
+			if err != nil {
+				return "", err
+			}
+			if entry.BlobHash, err = objects.WriteRawObject(repo.Worktree, "blob", data); err != nil {
+				return "", fmt.Errorf("failed to write blob object for %s: %w", entry.FilePath, err)
+			}
+			entry.Mode = staging.ModeFor(info, entry.Mode, trustFileMode)
+		}
+		worktree.Entries = append(worktree.Entries, entry)
+	}
+	treeHash, err := staging.WriteTree(repo.Worktree, worktree)
+	if err != nil {
+		return "", fmt.Errorf("failed to write worktree tree: %w", err)
+	}
+	return treeHash, nil
+}
@@ -0,0 +1,389 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/ignore"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/staging"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// statusEntry describes how one path differs between HEAD, the index and
+// the worktree, using the two-letter codes of `git status --short`.
+type statusEntry struct {
+	Path     string
+	Staged   byte // HEAD vs index: 'A', 'M', 'D', or ' '
+	Unstaged byte // Index vs worktree: 'M', 'D', or ' '
+	Unmerged string
+}
+
+// repoStatus is the full result of comparing HEAD, index and worktree.
+type repoStatus struct {
+	Entries   []statusEntry
+	Untracked []string
+}
+
+// Status prints the staged, unstaged, deleted and untracked files. In short
+// mode each path is printed on one line prefixed by its two status letters.
+func Status(repoPath string, short bool) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	status, err := collectStatus(repoPath)
+	if err != nil {
+		return err
+	}
+
+	if short {
+		for _, e := range status.Entries {
+			if e.Unmerged != "" {
+				fmt.Printf("%s %s\n", e.Unmerged, e.Path)
+				continue
+			}
+			fmt.Printf("%c%c %s\n", e.Staged, e.Unstaged, e.Path)
+		}
+		for _, p := range status.Untracked {
+			fmt.Printf("?? %s\n", p)
+		}
+		return nil
+	}
+
+	fmt.Println(describeHead(repoPath))
+
+	var staged, unstaged, unmerged []string
+	for _, e := range status.Entries {
+		if e.Unmerged != "" {
+			unmerged = append(unmerged, fmt.Sprintf("\t%-17s%s", unmergedLabel(e.Unmerged)+":", e.Path))
+			continue
+		}
+		if e.Staged != ' ' {
+			staged = append(staged, fmt.Sprintf("\t%-12s%s", statusLabel(e.Staged)+":", e.Path))
+		}
+		if e.Unstaged != ' ' {
+			unstaged = append(unstaged, fmt.Sprintf("\t%-12s%s", statusLabel(e.Unstaged)+":", e.Path))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(gitDir, "MERGE_HEAD")); err == nil {
+		if len(unmerged) > 0 {
+			fmt.Println("You have unmerged paths.")
+		} else {
+			fmt.Println("All conflicts fixed but you are still merging.")
+		}
+	}
+
+	printSection := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Printf("\n%s\n", title)
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+	printSection("Changes to be committed:", staged)
+	printSection("Unmerged paths:", unmerged)
+	printSection("Changes not staged for commit:", unstaged)
+	untracked := make([]string, len(status.Untracked))
+	for i, p := range status.Untracked {
+		untracked[i] = "\t" + p
+	}
+	printSection("Untracked files:", untracked)
+
+	if len(staged)+len(unstaged)+len(unmerged)+len(untracked) == 0 {
+		fmt.Println("nothing to commit, working tree clean")
+	}
+	return nil
+}
+
+// collectStatus diffs the HEAD tree against the index and the index against
+// the worktree.
+func collectStatus(repoPath string) (*repoStatus, error) {
+	headEntries, err := headTreeEntries(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	byPath := make(map[string]*statusEntry)
+	entryFor := func(p string) *statusEntry {
+		if e, ok := byPath[p]; ok {
+			return e
+		}
+		e := &statusEntry{Path: p, Staged: ' ', Unstaged: ' '}
+		byPath[p] = e
+		return e
+	}
+
+	// Group unmerged stages by path
+	stages := make(map[string][]int)
+	tracked := make(map[string]bool)
+	for _, entry := range index.Entries {
+		tracked[entry.FilePath] = true
+		if entry.Stage != 0 {
+			stages[entry.FilePath] = append(stages[entry.FilePath], entry.Stage)
+		}
+	}
+	for p, s := range stages {
+		entryFor(p).Unmerged = unmergedCode(s)
+	}
+
+	// HEAD vs index
+	trustFileMode := staging.TrustFileMode(repoPath)
+	refreshed := false
+	for i := range index.Entries {
+		entry := &index.Entries[i]
+		if entry.Stage != 0 {
+			continue
+		}
+		mode := fmt.Sprintf("%o", entry.Mode)
+		headEntry, inHead := headEntries[entry.FilePath]
+		switch {
+		case entry.IntentToAdd:
+			// Nothing is staged yet; the file shows as an unstaged addition
+		case !inHead:
+			entryFor(entry.FilePath).Staged = 'A'
+		case headEntry.Hash != entry.BlobHash || headEntry.Mode != mode:
+			entryFor(entry.FilePath).Staged = 'M'
+		}
+		if entry.SkipWorktree {
+			// The file is not expected in the worktree
+			continue
+		}
+
+		// Index vs worktree
+		info, err := os.Lstat(filepath.Join(repoPath, filepath.FromSlash(entry.FilePath)))
+		if err != nil || info.IsDir() {
+			entryFor(entry.FilePath).Unstaged = 'D'
+			continue
+		}
+		if entry.IntentToAdd {
+			entryFor(entry.FilePath).Unstaged = 'A'
+			continue
+		}
+		matches, err := staging.FileMatches(repoPath, *entry, info, trustFileMode)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			entryFor(entry.FilePath).Unstaged = 'M'
+		} else if !entry.StatMatches(info) {
+			// Contents are unchanged; remember the new stat data so the
+			// file is not hashed again next time.
+			fresh := staging.NewEntry(entry.FilePath, entry.BlobHash, info)
+			fresh.Mode = entry.Mode
+			*entry = fresh
+			refreshed = true
+		}
+	}
+	for p := range headEntries {
+		if !tracked[p] {
+			entryFor(p).Staged = 'D'
+		}
+	}
+
+	if refreshed {
+		// Best effort: another process may hold the index lock
+		staging.WriteIndex(repoPath, index)
+	}
+
+	status := &repoStatus{}
+	for _, e := range byPath {
+		status.Entries = append(status.Entries, *e)
+	}
+	sort.Slice(status.Entries, func(i, j int) bool { return status.Entries[i].Path < status.Entries[j].Path })
+
+	ignored, err := ignore.New(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	status.Untracked, err = untrackedFiles(repoPath, tracked, ignored)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// untrackedFiles lists worktree files missing from the index, leaving out
+// ignored ones. Directories that contain no tracked files are reported once,
+// with a trailing slash.
+func untrackedFiles(repoPath string, tracked map[string]bool, ignored *ignore.Matcher) ([]string, error) {
+	trackedDirs := make(map[string]bool)
+	for p := range tracked {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	var untracked []string
+	err := filepath.WalkDir(repoPath, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(repoPath, fullPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !trackedDirs[rel] && !tracked[rel] {
+				if !ignored.Ignored(rel, true) && hasUnignoredFiles(fullPath, rel, ignored) {
+					untracked = append(untracked, rel+"/")
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !tracked[rel] && !ignored.Ignored(rel, false) {
+			untracked = append(untracked, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan worktree: %w", err)
+	}
+
+	sort.Strings(untracked)
+	return untracked, nil
+}
+
+// hasUnignoredFiles reports whether a directory, found at rel in the
+// worktree, contains at least one file that is not ignored.
+func hasUnignoredFiles(dir, rel string, ignored *ignore.Matcher) bool {
+	found := false
+	filepath.WalkDir(dir, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		sub, err := filepath.Rel(dir, fullPath)
+		if err != nil || sub == "." {
+			return nil
+		}
+		p := path.Join(rel, filepath.ToSlash(sub))
+		if ignored.Ignored(p, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// headTreeEntries returns the flattened tree of the HEAD commit, or an empty
+// map when nothing has been committed yet.
+func headTreeEntries(repoPath string) (map[string]objects.TreeEntry, error) {
+	headHash, err := refs.Resolve(repoPath, "HEAD")
+	if errors.Is(err, refs.ErrNotFound) {
+		return map[string]objects.TreeEntry{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	return commitTreeEntries(repoPath, headHash)
+}
+
+// commitTreeEntries returns the flattened tree of a commit.
+func commitTreeEntries(repoPath, commitHash string) (map[string]objects.TreeEntry, error) {
+	commit, err := readCommit(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+	return objects.FlattenTree(repoPath, commit.Tree)
+}
+
+// describeHead returns the "On branch" line for the long status format.
+func describeHead(repoPath string) string {
+	branch, onBranch, err := refs.CurrentBranch(repoPath)
+	if err != nil {
+		return "Not currently on any branch."
+	}
+	if onBranch {
+		return "On branch " + refs.ShortName(branch)
+	}
+	head, err := refs.Resolve(repoPath, "HEAD")
+	if err != nil {
+		return "Not currently on any branch."
+	}
+	return "HEAD detached at " + head[:7]
+}
+
+// statusLabel names a status letter for the long format.
+func statusLabel(code byte) string {
+	switch code {
+	case 'A':
+		return "new file"
+	case 'D':
+		return "deleted"
+	default:
+		return "modified"
+	}
+}
+
+// unmergedCode maps the stages present for a conflicted path to the
+// two-letter code Git prints for it.
+func unmergedCode(stages []int) string {
+	var has [4]bool
+	for _, s := range stages {
+		has[s] = true
+	}
+	switch {
+	case has[1] && has[2] && has[3]:
+		return "UU"
+	case has[2] && has[3]:
+		return "AA"
+	case has[1] && has[3]:
+		return "DU"
+	case has[1] && has[2]:
+		return "UD"
+	case has[2]:
+		return "AU"
+	case has[3]:
+		return "UA"
+	default:
+		return "DD"
+	}
+}
+
+// unmergedLabel describes an unmerged code for the long format.
+func unmergedLabel(code string) string {
+	switch code {
+	case "UU":
+		return "both modified"
+	case "AA":
+		return "both added"
+	case "DU":
+		return "deleted by us"
+	case "UD":
+		return "deleted by them"
+	case "AU":
+		return "added by us"
+	case "UA":
+		return "added by them"
+	default:
+		return "both deleted"
+	}
+}
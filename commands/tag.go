@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gopract/config"
+	"gopract/objects"
+	"gopract/refs"
+	"os"
+	"path/filepath"
+)
+
+// CreateTag creates a tag under `refs/tags` pointing at target. A lightweight
+// tag is a plain ref; an annotated tag stores a tag object with a message.
+// Signed tags are annotated tags carrying an SSH signature; they are made
+// when sign is set or, for annotated tags, when `tag.gpgsign` is enabled.
+func CreateTag(repoPath, name, target, message string, annotated, sign, force bool) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	refName := "refs/tags/" + name
+	if !refs.ValidName(refName) {
+		return fmt.Errorf("'%s' is not a valid tag name", name)
+	}
+
+	if _, err := refs.Resolve(repoPath, refName); err == nil && !force {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	sha, err := resolveRevision(repoPath, target)
+	if err != nil {
+		return err
+	}
+
+	if annotated {
+		objType, _, err := objects.ReadRawObject(repoPath, sha)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %w", sha, err)
+		}
+
+		// Git records the committer identity as the tagger
+		tagger, err := newSignature(repoPath, roleCommitter)
+		if err != nil {
+			return err
+		}
+
+		tag := &objects.Tag{
+			Object:     sha,
+			ObjectType: objType,
+			Name:       name,
+			Tagger:     &tagger,
+			Message:    cleanupMessage(message),
+		}
+		if sign || config.ReadBool(repoPath, "tag", "gpgsign") {
+			if err := signTag(repoPath, tag); err != nil {
+				return fmt.Errorf("failed to sign tag: %w", err)
+			}
+		}
+		sha, err = objects.WriteObject(tag, repoPath)
+		if err != nil {
+			return fmt.Errorf("failed to write tag object: %w", err)
+		}
+	}
+
+	if err := refs.Update(repoPath, refName, sha, "", ""); err != nil {
+		return fmt.Errorf("failed to write tag ref: %w", err)
+	}
+
+	fmt.Printf("Created tag %s at %s\n", name, sha)
+	return nil
+}
+
+// ListTags prints the name of every tag in the repository, loose or packed.
+func ListTags(repoPath string) error {
+	tags, err := refs.List(repoPath, "refs/tags/")
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		fmt.Println(refs.ShortName(tag.Name))
+	}
+	return nil
+}
+
+// DeleteTag removes the ref of the named tag.
+func DeleteTag(repoPath, name string) error {
+	refName := "refs/tags/" + name
+
+	sha, err := refs.Resolve(repoPath, refName)
+	if err != nil {
+		if errors.Is(err, refs.ErrNotFound) {
+			return fmt.Errorf("tag '%s' not found", name)
+		}
+		return fmt.Errorf("failed to read tag ref: %w", err)
+	}
+
+	if err := refs.Delete(repoPath, refName); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	fmt.Printf("Deleted tag '%s' (was %s)\n", name, sha[:7])
+	return nil
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"gopract/config"
+	"gopract/objects"
+	"gopract/sshsig"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// VerifyCommit checks the SSH signature of a commit against the allowed
+// signers file. An empty allowedSigners uses `gpg.ssh.allowedSignersFile`.
+func VerifyCommit(repoPath, revision, allowedSigners string) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	sha, err := resolveRevision(repoPath, revision)
+	if err != nil {
+		return err
+	}
+	commitHash, err := peelToCommit(repoPath, sha)
+	if err != nil {
+		return err
+	}
+	commit, err := readCommit(repoPath, commitHash)
+	if err != nil {
+		return err
+	}
+
+	payload, signature, err := commit.SignedPayload()
+	if err != nil {
+		return fmt.Errorf("failed to serialize commit: %w", err)
+	}
+	return verifySignature(repoPath, payload, signature, commit.Committer.When, allowedSigners)
+}
+
+// VerifyTag checks the SSH signature of an annotated tag against the allowed
+// signers file. An empty allowedSigners uses `gpg.ssh.allowedSignersFile`.
+func VerifyTag(repoPath, name, allowedSigners string) error {
+	// Ensure the repository exists
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		return fmt.Errorf("not a Git repository: %s", repoPath)
+	}
+
+	sha, err := resolveRevision(repoPath, name)
+	if err != nil {
+		return err
+	}
+	objType, data, err := objects.ReadRawObject(repoPath, sha)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", sha, err)
+	}
+	if objType != "tag" {
+		return fmt.Errorf("%s: cannot verify a non-tag object of type %s", name, objType)
+	}
+	tag := &objects.Tag{}
+	if err := tag.Deserialize(data); err != nil {
+		return fmt.Errorf("failed to parse tag %s: %w", sha, err)
+	}
+
+	payload, signature, err := tag.SignedPayload()
+	if err != nil {
+		return fmt.Errorf("failed to serialize tag: %w", err)
+	}
+	var when time.Time
+	if tag.Tagger != nil {
+		when = tag.Tagger.When
+	}
+	return verifySignature(repoPath, payload, signature, when, allowedSigners)
+}
+
+// verifySignature checks an armored SSH signature over payload and reports
+// which allowed signer made it. Keys are matched against the allowed signers
+// at the time the object claims to have been signed.
+func verifySignature(repoPath string, payload []byte, signature string, when time.Time, allowedSigners string) error {
+	if signature == "" {
+		return fmt.Errorf("no signature found")
+	}
+	if !sshsig.IsArmored(signature) {
+		return fmt.Errorf("unsupported signature format; only SSH signatures can be verified")
+	}
+
+	if allowedSigners == "" {
+		allowedSigners, _ = config.ReadValue(repoPath, "gpg", "ssh.allowedSignersFile")
+	}
+	if allowedSigners == "" {
+		return fmt.Errorf("gpg.ssh.allowedSignersFile needs to be configured for SSH signature verification")
+	}
+	signers, err := sshsig.LoadAllowedSigners(allowedSigners)
+	if err != nil {
+		return err
+	}
+
+	key, err := sshsig.Verify(signature, sshsig.Namespace, payload)
+	if err != nil {
+		return fmt.Errorf("bad signature: %w", err)
+	}
+	if when.IsZero() {
+		when = time.Now()
+	}
+	principals := sshsig.FindPrincipals(signers, key, sshsig.Namespace, when)
+	if len(principals) == 0 {
+		fmt.Printf("Good %q signature with ED25519 key %s\n", sshsig.Namespace, sshsig.Fingerprint(key))
+		return fmt.Errorf("no principal matched")
+	}
+
+	fmt.Printf("Good %q signature for %s with ED25519 key %s\n", sshsig.Namespace, principals[0], sshsig.Fingerprint(key))
+	return nil
+}
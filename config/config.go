@@ -12,8 +12,9 @@ import (
 
 type Config struct {
 	User struct {
-		Name  string `ini:"name"`
-		Email string `ini:"email"`
+		Name       string `ini:"name"`
+		Email      string `ini:"email"`
+		SigningKey string `ini:"signingkey"`
 	}
 	Core struct {
 		RepositoryFormatVersion int  `ini:"repositoryformatversion"`
@@ -55,7 +56,7 @@ func GetGlobalConfigPath() (string, error) {
 	return filepath.Join(usr.HomeDir, ".mygitconfig"), nil
 }
 
-// LoadConfig loads a configuration file.
+// LoadConfig loads a configuration file, reporting what it found.
 func LoadConfig(path string) (*Config, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -63,18 +64,11 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	fmt.Println("Loading config from:", absPath)
 
-	iniFile, err := ini.Load(absPath)
+	cfg, err := ReadConfig(absPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config file: %w", err)
+		return nil, err
 	}
 
-	cfg := new(Config)
-	cfg.User.Name = iniFile.Section("user").Key("name").String()
-	cfg.User.Email = iniFile.Section("user").Key("email").String()
-	cfg.Core.RepositoryFormatVersion = iniFile.Section("core").Key("repositoryformatversion").MustInt(0)
-	cfg.Core.FileMode = iniFile.Section("core").Key("filemode").MustBool(false)
-	cfg.Core.Bare = iniFile.Section("core").Key("bare").MustBool(false)
-
 	// Debugging Output
 	fmt.Println("Loaded Configuration:")
 	fmt.Printf("User Name: %s\n", cfg.User.Name)
@@ -86,6 +80,92 @@ func LoadConfig(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// ReadConfig loads a configuration file without printing anything.
+func ReadConfig(path string) (*Config, error) {
+	iniFile, err := ini.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+
+	cfg := new(Config)
+	cfg.User.Name = iniFile.Section("user").Key("name").String()
+	cfg.User.Email = iniFile.Section("user").Key("email").String()
+	cfg.User.SigningKey = iniFile.Section("user").Key("signingkey").String()
+	cfg.Core.RepositoryFormatVersion = iniFile.Section("core").Key("repositoryformatversion").MustInt(0)
+	cfg.Core.FileMode = iniFile.Section("core").Key("filemode").MustBool(false)
+	cfg.Core.Bare = iniFile.Section("core").Key("bare").MustBool(false)
+	return cfg, nil
+}
+
+// ReadUser returns the user name and email for a repository, preferring the
+// repository's `.git/config` and falling back to the global config.
+func ReadUser(repoPath string) (string, string, error) {
+	var name, email string
+	if local, err := ReadConfig(filepath.Join(repoPath, ".git", "config")); err == nil {
+		name, email = local.User.Name, local.User.Email
+	}
+	if name == "" || email == "" {
+		globalPath, err := GetGlobalConfigPath()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to locate global config: %w", err)
+		}
+		if global, err := ReadConfig(globalPath); err == nil {
+			if name == "" {
+				name = global.User.Name
+			}
+			if email == "" {
+				email = global.User.Email
+			}
+		}
+	}
+	return name, email, nil
+}
+
+// ReadValue returns the value of `section.key` for a repository, preferring
+// the repository's `.git/config` over the global config. Section and key
+// names are matched case-insensitively, as in Git. Subsections may be given
+// either as written by set-config ("gpg", "ssh.allowedSignersFile") or in
+// Git's own syntax (`gpg "ssh"`, "allowedSignersFile").
+func ReadValue(repoPath, section, key string) (string, bool) {
+	paths := []string{filepath.Join(repoPath, ".git", "config")}
+	if globalPath, err := GetGlobalConfigPath(); err == nil {
+		paths = append(paths, globalPath)
+	}
+
+	var subsection, subkey string
+	if sub, name, ok := cutLast(key, "."); ok {
+		subsection, subkey = fmt.Sprintf("%s \"%s\"", section, sub), name
+	}
+	for _, path := range paths {
+		iniFile, err := ini.LoadSources(ini.LoadOptions{Insensitive: true}, path)
+		if err != nil {
+			continue
+		}
+		if k, err := iniFile.Section(section).GetKey(key); err == nil {
+			return k.String(), true
+		}
+		if subsection != "" {
+			if k, err := iniFile.Section(subsection).GetKey(subkey); err == nil {
+				return k.String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// ReadBool returns a boolean setting, or false when it is unset or invalid.
+func ReadBool(repoPath, section, key string) bool {
+	value, ok := ReadValue(repoPath, section, key)
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1", "":
+		return true
+	}
+	return false
+}
+
 // SetConfigValue sets a key-value pair in the configuration file.
 func SetConfigValue(path, key, value string) error {
 	iniFile, err := ini.Load(path)
@@ -100,6 +180,12 @@ func SetConfigValue(path, key, value string) error {
 	}
 
 	section, field := parts[0], parts[1]
+
+	// Keys such as "gpg.ssh.allowedSignersFile" name a subsection, which Git
+	// writes as [gpg "ssh"]
+	if sub, name, ok := cutLast(field, "."); ok {
+		section, field = fmt.Sprintf("%s \"%s\"", section, sub), name
+	}
 	iniFile.Section(section).Key(field).SetValue(value)
 
 	err = iniFile.SaveTo(path)
@@ -109,3 +195,11 @@ func SetConfigValue(path, key, value string) error {
 
 	return nil
 }
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,170 @@
+package diff
+
+// compact normalizes an edit script the way Git's xdl_change_compact does,
+// so every algorithm places its hunks where Git would: each run of changed
+// lines slides as far down as equal lines around it allow, unless it can
+// line up with a change on the other side, and deletions come before
+// insertions. A run only slides over lines equal to its own, so the script
+// still turns x into y.
+func compact(x, y []int, edits []Edit) []Edit {
+	oldSide, newSide := newChangeSide(x), newChangeSide(y)
+	for _, e := range edits {
+		switch e.Op {
+		case Delete:
+			oldSide.set(e.OldLine, true)
+		case Insert:
+			newSide.set(e.NewLine, true)
+		}
+	}
+	oldSide.slide(newSide)
+	newSide.slide(oldSide)
+
+	result := make([]Edit, 0, len(edits))
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		for ; i < len(x) && oldSide.at(i); i++ {
+			result = append(result, Edit{Op: Delete, OldLine: i, NewLine: -1})
+		}
+		for ; j < len(y) && newSide.at(j); j++ {
+			result = append(result, Edit{Op: Insert, OldLine: -1, NewLine: j})
+		}
+		if i < len(x) && j < len(y) {
+			result = append(result, Edit{Op: Equal, OldLine: i, NewLine: j})
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// changeSide records which lines of one side of a diff are changed.
+type changeSide struct {
+	lines   []int
+	changed []bool // changed[i+1] is line i; the ends are always false
+}
+
+func newChangeSide(lines []int) *changeSide {
+	return &changeSide{lines: lines, changed: make([]bool, len(lines)+2)}
+}
+
+func (s *changeSide) at(i int) bool       { return s.changed[i+1] }
+func (s *changeSide) set(i int, set bool) { s.changed[i+1] = set }
+
+// group is a run of changed lines [start, end), possibly empty. Unchanged
+// lines separate groups, so the groups of both sides pair up in order.
+type group struct {
+	start, end int
+}
+
+func (s *changeSide) first() group {
+	g := group{}
+	for s.at(g.end) {
+		g.end++
+	}
+	return g
+}
+
+// next moves g to the following group, reporting false at the last one.
+func (s *changeSide) next(g *group) bool {
+	if g.end == len(s.lines) {
+		return false
+	}
+	g.start = g.end + 1
+	g.end = g.start
+	for s.at(g.end) {
+		g.end++
+	}
+	return true
+}
+
+// previous moves g to the preceding group, reporting false at the first one.
+func (s *changeSide) previous(g *group) bool {
+	if g.start == 0 {
+		return false
+	}
+	g.end = g.start - 1
+	g.start = g.end
+	for s.at(g.start - 1) {
+		g.start--
+	}
+	return true
+}
+
+// slideUp moves g up by a line if the line above equals its last line,
+// merging with any group it runs into.
+func (s *changeSide) slideUp(g *group) bool {
+	if g.start == 0 || s.lines[g.start-1] != s.lines[g.end-1] {
+		return false
+	}
+	g.start--
+	s.set(g.start, true)
+	g.end--
+	s.set(g.end, false)
+	for s.at(g.start - 1) {
+		g.start--
+	}
+	return true
+}
+
+// slideDown moves g down by a line if the line below equals its first line,
+// merging with any group it runs into.
+func (s *changeSide) slideDown(g *group) bool {
+	if g.end == len(s.lines) || s.lines[g.start] != s.lines[g.end] {
+		return false
+	}
+	s.set(g.start, false)
+	g.start++
+	s.set(g.end, true)
+	g.end++
+	for s.at(g.end) {
+		g.end++
+	}
+	return true
+}
+
+// slide moves every group of s to its final place, keeping track of the
+// matching group on the other side.
+func (s *changeSide) slide(other *changeSide) {
+	g, og := s.first(), other.first()
+	for {
+		if g.end != g.start {
+			// Slide up and down as far as possible, again if the group grew
+			// by merging, remembering the lowest end lined up with a change
+			// on the other side
+			var size, earliestEnd int
+			endMatchingOther := -1
+			for {
+				size = g.end - g.start
+				endMatchingOther = -1
+				for s.slideUp(&g) {
+					other.previous(&og)
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+				for s.slideDown(&g) {
+					other.next(&og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+				if size == g.end-g.start {
+					break
+				}
+			}
+
+			if g.end != earliestEnd && endMatchingOther != -1 {
+				for og.end == og.start {
+					s.slideUp(&g)
+					other.previous(&og)
+				}
+			}
+		}
+
+		if !s.next(&g) {
+			return
+		}
+		other.next(&og)
+	}
+}
@@ -0,0 +1,124 @@
+// Package diff computes line-level differences between files and trees and
+// renders them as unified diffs or summaries.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal  Op = iota // Line is present on both sides
+	Delete           // Line is only present in the old version
+	Insert           // Line is only present in the new version
+)
+
+// Algorithm selects how the longest common subsequence is found.
+type Algorithm string
+
+const (
+	Myers     Algorithm = "myers"
+	Patience  Algorithm = "patience"
+	Histogram Algorithm = "histogram"
+)
+
+// Edit is one step of the script turning the old lines into the new ones.
+// OldLine and NewLine are zero-based indexes, or -1 when the line does not
+// exist on that side.
+type Edit struct {
+	Op      Op
+	OldLine int
+	NewLine int
+}
+
+// binaryCheckSize is how much of a file is inspected for NUL bytes, the
+// same heuristic Git uses to decide that a file is binary.
+const binaryCheckSize = 8000
+
+// IsBinary reports whether data looks like binary content.
+func IsBinary(data []byte) bool {
+	if len(data) > binaryCheckSize {
+		data = data[:binaryCheckSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// SplitLines splits data into lines, keeping each line's terminating
+// newline so that a missing newline at end of file counts as a change.
+func SplitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// ParseAlgorithm validates an algorithm name.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch Algorithm(name) {
+	case "", "default", Myers:
+		return Myers, nil
+	case Patience:
+		return Patience, nil
+	case Histogram:
+		return Histogram, nil
+	default:
+		return "", fmt.Errorf("unknown diff algorithm: %s", name)
+	}
+}
+
+// Lines computes the edit script between two slices of lines.
+func Lines(a, b []string, algo Algorithm) []Edit {
+	// Map every distinct line to a small integer so comparisons are cheap
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	x, y := intern(a), intern(b)
+
+	// Each algorithm matches the common prefix and suffix itself, since
+	// they count towards how rare or unique a line is
+	return compact(x, y, diffRange(x, y, 0, len(x), 0, len(y), algo))
+}
+
+// diffRange dispatches to the chosen algorithm for x[alo:ahi] and y[blo:bhi].
+func diffRange(x, y []int, alo, ahi, blo, bhi int, algo Algorithm) []Edit {
+	switch algo {
+	case Patience:
+		return patience(x, y, alo, ahi, blo, bhi)
+	case Histogram:
+		return histogram(x, y, alo, ahi, blo, bhi)
+	default:
+		return myers(x, y, alo, ahi, blo, bhi)
+	}
+}
+
+// replaceAll is the trivial script: delete every old line, insert every new one.
+func replaceAll(alo, ahi, blo, bhi int) []Edit {
+	edits := make([]Edit, 0, ahi-alo+bhi-blo)
+	for i := alo; i < ahi; i++ {
+		edits = append(edits, Edit{Op: Delete, OldLine: i, NewLine: -1})
+	}
+	for j := blo; j < bhi; j++ {
+		edits = append(edits, Edit{Op: Insert, OldLine: -1, NewLine: j})
+	}
+	return edits
+}
@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// allAlgorithms maps every algorithm to the same expected hunks.
+func allAlgorithms(want string) map[Algorithm]string {
+	return map[Algorithm]string{Myers: want, Patience: want, Histogram: want}
+}
+
+func TestLines(t *testing.T) {
+	numbers := func(n int, line func(i int) string) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			b.WriteString(line(i))
+		}
+		return b.String()
+	}
+
+	// Expected hunks are what `git diff --no-indent-heuristic` prints
+	tests := []struct {
+		name     string
+		old, new string
+		want     map[Algorithm]string
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: allAlgorithms(""),
+		},
+		{
+			name: "change",
+			old:  "a\nb\nc\n",
+			new:  "a\nx\nc\n",
+			want: allAlgorithms("@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"),
+		},
+		{
+			name: "insert and delete",
+			old:  "b\nc\nd\n",
+			new:  "a\nb\nc\n",
+			want: allAlgorithms("@@ -1,3 +1,3 @@\n+a\n b\n c\n-d\n"),
+		},
+		{
+			name: "added file",
+			old:  "",
+			new:  "a\nb\n",
+			want: allAlgorithms("@@ -0,0 +1,2 @@\n+a\n+b\n"),
+		},
+		{
+			name: "removed file",
+			old:  "a\nb\n",
+			new:  "",
+			want: allAlgorithms("@@ -1,2 +0,0 @@\n-a\n-b\n"),
+		},
+		{
+			name: "newline added at end of file",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: allAlgorithms("@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"),
+		},
+		{
+			name: "separate hunks",
+			old:  numbers(20, func(i int) string { return fmt.Sprintf("%d\n", i) }),
+			new: numbers(20, func(i int) string {
+				switch i {
+				case 2:
+					return "x\n"
+				case 18:
+					return ""
+				}
+				return fmt.Sprintf("%d\n", i)
+			}),
+			want: allAlgorithms("@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,5 @@\n 15\n 16\n 17\n-18\n 19\n 20\n"),
+		},
+		{
+			name: "moved function",
+			old:  "void f() {\n  a();\n}\n\nvoid g() {\n  b();\n}\n",
+			new:  "void g() {\n  b();\n}\n\nvoid f() {\n  a();\n}\n",
+			want: allAlgorithms("@@ -1,7 +1,7 @@\n-void f() {\n-  a();\n-}\n-\n void g() {\n   b();\n }\n+\n+void f() {\n+  a();\n+}\n"),
+		},
+		{
+			name: "frequent lines",
+			old:  "{\nfoo\n}\n{\nbar\n}\n",
+			new:  "{\nbar\n}\n{\nbaz\n}\n{\nfoo\n}\n",
+			want: map[Algorithm]string{
+				Myers:     "@@ -1,6 +1,9 @@\n {\n-foo\n+bar\n }\n {\n-bar\n+baz\n+}\n+{\n+foo\n }\n",
+				Patience:  "@@ -1,6 +1,9 @@\n {\n-foo\n-}\n-{\n bar\n }\n+{\n+baz\n+}\n+{\n+foo\n+}\n",
+				Histogram: "@@ -1,6 +1,9 @@\n {\n-foo\n-}\n-{\n bar\n }\n+{\n+baz\n+}\n+{\n+foo\n+}\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for algo, want := range tt.want {
+			t.Run(tt.name+"/"+string(algo), func(t *testing.T) {
+				a, b := SplitLines([]byte(tt.old)), SplitLines([]byte(tt.new))
+				checkScript(t, a, b, Lines(a, b, algo))
+
+				var out bytes.Buffer
+				if err := WriteHunks(&out, []byte(tt.old), []byte(tt.new), Options{Context: DefaultContext, Algorithm: algo}); err != nil {
+					t.Fatal(err)
+				}
+				if got := out.String(); got != want {
+					t.Errorf("hunks differ\ngot:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestLinesLargeDistance guards the memory used by myers: with thousands of
+// differing lines on each side, a search that kept every step's paths would
+// need hundreds of megabytes.
+func TestLinesLargeDistance(t *testing.T) {
+	// Lines repeat often enough to take part in the search, but in a
+	// different order on each side
+	const n = 5000
+	a := make([]string, n)
+	b := make([]string, n)
+	for i := range a {
+		a[i] = fmt.Sprintf("%d\n", i%97)
+		b[i] = fmt.Sprintf("%d\n", i*31%97)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := Lines(a, b, Myers)
+	runtime.ReadMemStats(&after)
+
+	checkScript(t, a, b, edits)
+	if insertions, _ := Count(edits); insertions == n {
+		t.Errorf("no lines matched")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 32<<20 {
+		t.Errorf("diff allocated %d bytes, want at most %d", allocated, 32<<20)
+	}
+}
+
+// checkScript fails the test unless edits turns a into b, visiting every
+// line of each side once and in order.
+func checkScript(t *testing.T, a, b []string, edits []Edit) {
+	t.Helper()
+	i, j := 0, 0
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			if e.OldLine != i || e.NewLine != j || a[i] != b[j] {
+				t.Fatalf("bad equal edit %+v at old %d, new %d", e, i, j)
+			}
+			i++
+			j++
+		case Delete:
+			if e.OldLine != i {
+				t.Fatalf("bad delete edit %+v at old %d", e, i)
+			}
+			i++
+		case Insert:
+			if e.NewLine != j {
+				t.Fatalf("bad insert edit %+v at new %d", e, j)
+			}
+			j++
+		}
+	}
+	if i != len(a) || j != len(b) {
+		t.Fatalf("script covers %d old and %d new lines, want %d and %d", i, j, len(a), len(b))
+	}
+}
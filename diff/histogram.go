@@ -0,0 +1,131 @@
+package diff
+
+// maxChainLength is the occurrence count above which a line is considered
+// too common to anchor on, as in Git's histogram implementation.
+const maxChainLength = 64
+
+// histogram diffs x[alo:ahi] against y[blo:bhi] by splitting around the
+// longest common region that contains the rarest lines, recursing on both
+// sides. Ranges where every common line is too frequent fall back to Myers.
+func histogram(x, y []int, alo, ahi, blo, bhi int) []Edit {
+	ids := 0
+	for _, id := range x[alo:ahi] {
+		ids = max(ids, id+1)
+	}
+	h := &histogramState{
+		x:     x,
+		y:     y,
+		count: make([]int, ids),
+		first: make([]int, ids),
+		next:  make([]int, len(x)),
+		edits: make([]Edit, 0, ahi-alo+bhi-blo),
+	}
+	h.diff(alo, ahi, blo, bhi)
+	return h.edits
+}
+
+// histogramState holds what the recursion shares. The index of the old
+// lines in the range being split lives in slices reused at every level,
+// with each line's occurrences chained in order.
+type histogramState struct {
+	x, y  []int
+	count []int // Occurrences of each line id in the range
+	first []int // First occurrence of each line id in the range
+	next  []int // Next occurrence of the line at each position, or -1
+	edits []Edit
+}
+
+// diff appends the edit script for x[alo:ahi] -> y[blo:bhi].
+func (h *histogramState) diff(alo, ahi, blo, bhi int) {
+	if alo == ahi || blo == bhi {
+		h.edits = append(h.edits, replaceAll(alo, ahi, blo, bhi)...)
+		return
+	}
+
+	for i := ahi - 1; i >= alo; i-- {
+		id := h.x[i]
+		h.next[i] = -1
+		if h.count[id] > 0 {
+			h.next[i] = h.first[id]
+		}
+		h.first[id] = i
+		h.count[id]++
+	}
+	bestA, bestB, bestLen, common := h.longestRegion(alo, ahi, blo, bhi)
+	for i := alo; i < ahi; i++ {
+		h.count[h.x[i]] = 0
+	}
+
+	switch {
+	case bestLen > 0:
+		h.diff(alo, bestA, blo, bestB)
+		for k := 0; k < bestLen; k++ {
+			h.edits = append(h.edits, Edit{Op: Equal, OldLine: bestA + k, NewLine: bestB + k})
+		}
+		h.diff(bestA+bestLen, ahi, bestB+bestLen, bhi)
+	case common:
+		h.edits = append(h.edits, myers(h.x, h.y, alo, ahi, blo, bhi)...)
+	default:
+		h.edits = append(h.edits, replaceAll(alo, ahi, blo, bhi)...)
+	}
+}
+
+// longestRegion finds the common region to split on, from the index of
+// x[alo:ahi]. Its length is zero when there is none, in which case common
+// reports whether the ranges share any line at all.
+func (h *histogramState) longestRegion(alo, ahi, blo, bhi int) (bestA, bestB, bestLen int, common bool) {
+	x, y := h.x, h.y
+	occurrences := func(id int) int {
+		if id < len(h.count) {
+			return h.count[id]
+		}
+		return 0
+	}
+
+	// As in Git, a region replaces the best one so far when it is longer or
+	// rarer, and the scan of y skips past every region it matched
+	bestCount := maxChainLength + 1
+	bestLen = 1
+	for j := blo; j < bhi; {
+		next := j + 1
+		n := occurrences(y[j])
+		if n > 0 {
+			common = true
+		}
+		if n == 0 || n > bestCount {
+			j = next
+			continue
+		}
+		for i := h.first[y[j]]; i != -1; {
+			// Grow the matching region around (i, j); it is as rare as
+			// its rarest line
+			count := n
+			sa, sb := i, j
+			for sa > alo && sb > blo && x[sa-1] == y[sb-1] {
+				sa--
+				sb--
+				count = min(count, h.count[x[sa]])
+			}
+			ea, eb := i+1, j+1
+			for ea < ahi && eb < bhi && x[ea] == y[eb] {
+				count = min(count, h.count[x[ea]])
+				ea++
+				eb++
+			}
+
+			next = max(next, eb)
+			if ea-sa > bestLen || count < bestCount {
+				bestCount, bestLen, bestA, bestB = count, ea-sa, sa, sb
+			}
+			for i != -1 && i < ea {
+				i = h.next[i]
+			}
+		}
+		j = next
+	}
+
+	if bestCount > maxChainLength {
+		return 0, 0, 0, common
+	}
+	return bestA, bestB, bestLen, common
+}
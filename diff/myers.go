@@ -0,0 +1,385 @@
+package diff
+
+// Tuning of the Myers search, with the values Git's xdiff uses.
+const (
+	maxEqualLimit = 1024 // Cap on how often a line may match before it is set aside
+	simScanWindow = 100  // How far around a frequent line to look for unmatched lines
+	maxCostMin    = 256  // Least edit cost at which the search gives up on optimality
+	heurMinCost   = 256  // Edit cost above which long snakes are taken early
+	snakeCount    = 20   // Length of a snake worth splitting on
+	heurFactor    = 4    // How far ahead of the cost a snake must reach to be taken
+)
+
+// myers diffs x[alo:ahi] against y[blo:bhi] the way Git's xdiff does. Past
+// the common prefix and suffix, lines without a match on the other side,
+// and frequent lines among them, are set aside as changes first. The rest
+// are split recursively around the middle of a shortest path, found by
+// searching from both ends at once, so memory stays proportional to the
+// input. Once the search grows costly it settles for a good path instead
+// of a shortest one.
+func myers(x, y []int, alo, ahi, blo, bhi int) []Edit {
+	// The common prefix and suffix are left out of the search, but lines
+	// are still counted over the whole range
+	a, b := x[alo:ahi], y[blo:bhi]
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	s := &myersState{}
+	s.old, s.changedOld = discardLines(a, b, prefix, len(a)-suffix)
+	s.new, s.changedNew = discardLines(b, a, prefix, len(b)-suffix)
+
+	n1, n2 := len(s.old.lines), len(s.new.lines)
+	diagonals := n1 + n2 + 3
+	s.maxCost = max(bogoSqrt(diagonals), maxCostMin)
+	s.forward = make([]int, diagonals)
+	s.backward = make([]int, diagonals)
+	s.offset = n2 + 1
+	s.compare(0, n1, 0, n2, false)
+
+	edits := make([]Edit, 0, ahi-alo+bhi-blo)
+	i, j := 0, 0
+	for i < ahi-alo || j < bhi-blo {
+		for ; i < ahi-alo && s.changedOld[i]; i++ {
+			edits = append(edits, Edit{Op: Delete, OldLine: alo + i, NewLine: -1})
+		}
+		for ; j < bhi-blo && s.changedNew[j]; j++ {
+			edits = append(edits, Edit{Op: Insert, OldLine: -1, NewLine: blo + j})
+		}
+		if i < ahi-alo && j < bhi-blo {
+			edits = append(edits, Edit{Op: Equal, OldLine: alo + i, NewLine: blo + j})
+			i++
+			j++
+		}
+	}
+	return edits
+}
+
+// keptLines are the lines of one side that take part in the search.
+type keptLines struct {
+	lines []int // Line ids
+	index []int // Position of each line in the range being diffed
+}
+
+// discardLines sets aside the lines of a[start:end] that cannot be matched
+// in b, and those matching too often that sit among unmatched lines. It
+// returns the lines that are kept and marks the others changed.
+func discardLines(a, b []int, start, end int) (keptLines, []bool) {
+	inB := make(map[int]int)
+	for _, id := range b {
+		inB[id]++
+	}
+	limit := min(bogoSqrt(len(a)), maxEqualLimit)
+
+	// 0: no match, 1: some matches, 2: too many
+	matches := make([]byte, end-start)
+	for i := range matches {
+		switch count := inB[a[start+i]]; {
+		case count == 0:
+		case count >= limit:
+			matches[i] = 2
+		default:
+			matches[i] = 1
+		}
+	}
+
+	var kept keptLines
+	changed := make([]bool, len(a))
+	for i := range matches {
+		if matches[i] == 1 || matches[i] == 2 && !amongUnmatched(matches, i) {
+			kept.lines = append(kept.lines, a[start+i])
+			kept.index = append(kept.index, start+i)
+		} else {
+			changed[start+i] = true
+		}
+	}
+	return kept, changed
+}
+
+// amongUnmatched reports whether the frequent line i is surrounded by runs
+// of unmatched and frequent lines, mostly unmatched ones, so that it is
+// better treated as changed than used to line up the two sides.
+func amongUnmatched(matches []byte, i int) bool {
+	start, end := max(i-simScanWindow, 0), min(i+simScanWindow, len(matches)-1)
+
+	unmatchedBefore, frequentBefore := 0, 1
+	for r := 1; i-r >= start; r++ {
+		if matches[i-r] == 0 {
+			unmatchedBefore++
+		} else if matches[i-r] == 2 {
+			frequentBefore++
+		} else {
+			break
+		}
+	}
+	if unmatchedBefore == 0 {
+		return false
+	}
+	unmatchedAfter, frequentAfter := 0, 1
+	for r := 1; i+r <= end; r++ {
+		if matches[i+r] == 0 {
+			unmatchedAfter++
+		} else if matches[i+r] == 2 {
+			frequentAfter++
+		} else {
+			break
+		}
+	}
+	if unmatchedAfter == 0 {
+		return false
+	}
+	unmatched := unmatchedBefore + unmatchedAfter
+	frequent := frequentBefore + frequentAfter
+	return frequent*4 < frequent+unmatched
+}
+
+// bogoSqrt roughly approximates the square root of n, as Git does, to size
+// limits that grow with the input.
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+// myersState holds what the recursion shares: the kept lines of each side,
+// which of them are changed, and the furthest-reaching paths, which every
+// level reuses.
+type myersState struct {
+	old, new               keptLines
+	changedOld, changedNew []bool
+	maxCost                int   // Edit cost at which the search settles
+	forward, backward      []int // Furthest old line reached on each diagonal from either end
+	offset                 int   // Index of diagonal 0 in forward and backward
+}
+
+// compare marks the changed lines of old[off1:lim1] against new[off2:lim2].
+// minimal asks for a shortest script whatever the cost.
+func (s *myersState) compare(off1, lim1, off2, lim2 int, minimal bool) {
+	a, b := s.old.lines, s.new.lines
+	for off1 < lim1 && off2 < lim2 && a[off1] == b[off2] {
+		off1++
+		off2++
+	}
+	for off1 < lim1 && off2 < lim2 && a[lim1-1] == b[lim2-1] {
+		lim1--
+		lim2--
+	}
+
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			s.changedNew[s.new.index[off2]] = true
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			s.changedOld[s.old.index[off1]] = true
+		}
+	default:
+		split := s.split(off1, lim1, off2, lim2, minimal)
+		s.compare(off1, split.i1, off2, split.i2, split.minLow)
+		s.compare(split.i1, lim1, split.i2, lim2, split.minHigh)
+	}
+}
+
+// splitPoint is where split divides a problem, and whether each half must
+// still be diffed minimally.
+type splitPoint struct {
+	i1, i2          int
+	minLow, minHigh bool
+}
+
+// split searches old[off1:lim1] -> new[off2:lim2] from both ends, with
+// diagonal d holding paths where old line - new line = d, until the paths
+// overlap; the overlap is a point on a shortest path. Unless minimal is set,
+// a long enough snake or a large enough cost ends the search early at a
+// point that is merely good. The ranges must be non-empty and differ in
+// their first and last lines.
+func (s *myersState) split(off1, lim1, off2, lim2 int, minimal bool) splitPoint {
+	a, b := s.old.lines, s.new.lines
+	kf := func(d int) *int { return &s.forward[s.offset+d] }
+	kb := func(d int) *int { return &s.backward[s.offset+d] }
+
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	*kf(fmid) = off1
+	*kb(bmid) = lim1
+
+	for cost := 1; ; cost++ {
+		gotSnake := false
+
+		// Forward: extend every diagonal by one edit, then follow equal lines
+		if fmin > dmin {
+			fmin--
+			*kf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			*kf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if *kf(d - 1) >= *kf(d + 1) {
+				i1 = *kf(d - 1) + 1
+			} else {
+				i1 = *kf(d + 1)
+			}
+			start := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && a[i1] == b[i2] {
+				i1++
+				i2++
+			}
+			if i1-start > snakeCount {
+				gotSnake = true
+			}
+			*kf(d) = i1
+			if odd && bmin <= d && d <= bmax && *kb(d) <= i1 {
+				return splitPoint{i1, i2, true, true}
+			}
+		}
+
+		// Backward: the same from the end of both ranges
+		if bmin > dmin {
+			bmin--
+			*kb(bmin - 1) = int(^uint(0) >> 1)
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			*kb(bmax + 1) = int(^uint(0) >> 1)
+		} else {
+			bmax--
+		}
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if *kb(d - 1) < *kb(d + 1) {
+				i1 = *kb(d - 1)
+			} else {
+				i1 = *kb(d + 1) - 1
+			}
+			start := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && a[i1-1] == b[i2-1] {
+				i1--
+				i2--
+			}
+			if start-i1 > snakeCount {
+				gotSnake = true
+			}
+			*kb(d) = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= *kf(d) {
+				return splitPoint{i1, i2, true, true}
+			}
+		}
+
+		if minimal {
+			continue
+		}
+
+		// Past a certain cost, split on a path that has got far ahead of the
+		// cost spent and ends in a long snake
+		if gotSnake && cost > heurMinCost {
+			best, found := 0, splitPoint{minLow: true}
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := *kf(d)
+				i2 := i1 - d
+				v := i1 - off1 + i2 - off2 - abs(d-fmid)
+				if v > heurFactor*cost && v > best &&
+					off1+snakeCount <= i1 && i1 < lim1 &&
+					off2+snakeCount <= i2 && i2 < lim2 && snakeBefore(a, b, i1, i2) {
+					best, found.i1, found.i2 = v, i1, i2
+				}
+			}
+			if best > 0 {
+				return found
+			}
+
+			best, found = 0, splitPoint{minHigh: true}
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := *kb(d)
+				i2 := i1 - d
+				v := lim1 - i1 + lim2 - i2 - abs(d-bmid)
+				if v > heurFactor*cost && v > best &&
+					off1 < i1 && i1 <= lim1-snakeCount &&
+					off2 < i2 && i2 <= lim2-snakeCount && snakeAfter(a, b, i1, i2) {
+					best, found.i1, found.i2 = v, i1, i2
+				}
+			}
+			if best > 0 {
+				return found
+			}
+		}
+
+		// Enough is enough: take whichever end's path has got furthest
+		if cost >= s.maxCost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := min(*kf(d), lim1)
+				i2 := i1 - d
+				if lim2 < i2 {
+					i1, i2 = lim2+d, lim2
+				}
+				if fbest < i1+i2 {
+					fbest, fbest1 = i1+i2, i1
+				}
+			}
+			bbest, bbest1 := int(^uint(0)>>1), 0
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := max(off1, *kb(d))
+				i2 := i1 - d
+				if i2 < off2 {
+					i1, i2 = off2+d, off2
+				}
+				if i1+i2 < bbest {
+					bbest, bbest1 = i1+i2, i1
+				}
+			}
+			if lim1+lim2-bbest < fbest-(off1+off2) {
+				return splitPoint{fbest1, fbest - fbest1, true, false}
+			}
+			return splitPoint{bbest1, bbest - bbest1, false, true}
+		}
+	}
+}
+
+// snakeBefore reports whether the snakeCount lines before (i1, i2) match.
+func snakeBefore(a, b []int, i1, i2 int) bool {
+	for k := 1; k <= snakeCount; k++ {
+		if a[i1-k] != b[i2-k] {
+			return false
+		}
+	}
+	return true
+}
+
+// snakeAfter reports whether the snakeCount lines from (i1, i2) match.
+func snakeAfter(a, b []int, i1, i2 int) bool {
+	for k := 0; k < snakeCount; k++ {
+		if a[i1+k] != b[i2+k] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
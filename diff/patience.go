@@ -0,0 +1,115 @@
+package diff
+
+import "sort"
+
+// patience diffs x[alo:ahi] against y[blo:bhi] by anchoring on lines that
+// occur exactly once on each side, then recursing between the anchors.
+// Ranges without unique common lines fall back to Myers.
+func patience(x, y []int, alo, ahi, blo, bhi int) []Edit {
+	if alo == ahi || blo == bhi {
+		return replaceAll(alo, ahi, blo, bhi)
+	}
+	anchors, common := uniqueAnchors(x, y, alo, ahi, blo, bhi)
+	if !common {
+		return replaceAll(alo, ahi, blo, bhi)
+	}
+	if len(anchors) == 0 {
+		return myers(x, y, alo, ahi, blo, bhi)
+	}
+
+	// Between anchors, lines matching on either edge of the gap are kept
+	// before recursing on what is left, as Git does
+	var edits []Edit
+	i, j := alo, blo
+	for k := 0; ; k++ {
+		nextI, nextJ := ahi, bhi
+		if k < len(anchors) {
+			nextI, nextJ = anchors[k][0], anchors[k][1]
+			for nextI > i && nextJ > j && x[nextI-1] == y[nextJ-1] {
+				nextI--
+				nextJ--
+			}
+		}
+		for i < nextI && j < nextJ && x[i] == y[j] {
+			edits = append(edits, Edit{Op: Equal, OldLine: i, NewLine: j})
+			i++
+			j++
+		}
+		if i < nextI || j < nextJ {
+			edits = append(edits, patience(x, y, i, nextI, j, nextJ)...)
+		}
+		if k == len(anchors) {
+			return edits
+		}
+
+		// Run on to the anchor and any anchors directly following it
+		for k+1 < len(anchors) && anchors[k+1][0] == anchors[k][0]+1 && anchors[k+1][1] == anchors[k][1]+1 {
+			k++
+		}
+		for i, j = nextI, nextJ; i <= anchors[k][0]; i, j = i+1, j+1 {
+			edits = append(edits, Edit{Op: Equal, OldLine: i, NewLine: j})
+		}
+	}
+}
+
+// uniqueAnchors returns the longest increasing sequence of (old, new) line
+// pairs among lines that are unique on both sides, and whether the ranges
+// have any line in common.
+func uniqueAnchors(x, y []int, alo, ahi, blo, bhi int) ([][2]int, bool) {
+	type occurrence struct {
+		countA, countB int
+		posB           int
+	}
+	seen := make(map[int]*occurrence)
+	for i := alo; i < ahi; i++ {
+		o, ok := seen[x[i]]
+		if !ok {
+			o = &occurrence{}
+			seen[x[i]] = o
+		}
+		o.countA++
+	}
+	common := false
+	for j := blo; j < bhi; j++ {
+		if o, ok := seen[y[j]]; ok {
+			o.countB++
+			o.posB = j
+			common = true
+		}
+	}
+
+	// Pairs are taken in old order, as Git does, which decides between
+	// sequences of the same length
+	var pairs [][2]int
+	for i := alo; i < ahi; i++ {
+		if o := seen[x[i]]; o.countA == 1 && o.countB == 1 {
+			pairs = append(pairs, [2]int{i, o.posB})
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, common
+	}
+
+	// Patience sorting: piles hold the index of the pair on top, and each
+	// pair remembers the top of the previous pile when it was placed.
+	var piles []int
+	back := make([]int, len(pairs))
+	for p, pair := range pairs {
+		n := sort.Search(len(piles), func(i int) bool { return pairs[piles[i]][1] > pair[1] })
+		back[p] = -1
+		if n > 0 {
+			back[p] = piles[n-1]
+		}
+		if n == len(piles) {
+			piles = append(piles, p)
+		} else {
+			piles[n] = p
+		}
+	}
+
+	anchors := make([][2]int, len(piles))
+	for p, n := piles[len(piles)-1], len(piles)-1; p >= 0; p, n = back[p], n-1 {
+		anchors[n] = pairs[p]
+	}
+	return anchors, true
+}
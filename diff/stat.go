@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxStatBar is the widest +/- bar drawn by WriteStat.
+const maxStatBar = 40
+
+// FileStat summarises the size of the change to one file.
+type FileStat struct {
+	Path       string
+	Insertions int
+	Deletions  int
+	Binary     bool
+	OldSize    int
+	NewSize    int
+}
+
+// StatFor counts the lines added and removed between two versions of a file.
+func StatFor(path string, oldData, newData []byte, opts Options) FileStat {
+	stat := FileStat{Path: path, OldSize: len(oldData), NewSize: len(newData)}
+	if IsBinary(oldData) || IsBinary(newData) {
+		stat.Binary = true
+		return stat
+	}
+	a, b := SplitLines(oldData), SplitLines(newData)
+	stat.Insertions, stat.Deletions = Count(Lines(a, b, opts.Algorithm))
+	return stat
+}
+
+// WriteStat prints a diffstat in the style of `git diff --stat`.
+func WriteStat(w io.Writer, stats []FileStat) {
+	nameWidth, countWidth, maxChange := 0, 1, 0
+	for _, s := range stats {
+		if len(s.Path) > nameWidth {
+			nameWidth = len(s.Path)
+		}
+		if s.Binary && countWidth < len("Bin") {
+			countWidth = len("Bin")
+		}
+		total := s.Insertions + s.Deletions
+		if n := len(fmt.Sprint(total)); n > countWidth {
+			countWidth = n
+		}
+		if total > maxChange {
+			maxChange = total
+		}
+	}
+
+	insertions, deletions := 0, 0
+	for _, s := range stats {
+		if s.Binary {
+			fmt.Fprintf(w, " %-*s | Bin %d -> %d bytes\n", nameWidth, s.Path, s.OldSize, s.NewSize)
+			continue
+		}
+		insertions += s.Insertions
+		deletions += s.Deletions
+
+		plus, minus := s.Insertions, s.Deletions
+		if maxChange > maxStatBar {
+			plus = scaleStat(plus, maxChange)
+			minus = scaleStat(minus, maxChange)
+		}
+		bar := strings.Repeat("+", plus) + strings.Repeat("-", minus)
+		line := fmt.Sprintf(" %-*s | %*d %s", nameWidth, s.Path, countWidth, s.Insertions+s.Deletions, bar)
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	summary := fmt.Sprintf(" %d file%s changed", len(stats), plural(len(stats)))
+	if insertions > 0 || len(stats) == 0 {
+		summary += fmt.Sprintf(", %d insertion%s(+)", insertions, plural(insertions))
+	}
+	if deletions > 0 {
+		summary += fmt.Sprintf(", %d deletion%s(-)", deletions, plural(deletions))
+	}
+	fmt.Fprintln(w, summary)
+}
+
+// scaleStat shrinks a count so the widest bar fits in maxStatBar columns,
+// keeping at least one symbol for any non-zero count.
+func scaleStat(n, max int) int {
+	if n == 0 {
+		return 0
+	}
+	scaled := n * maxStatBar / max
+	if scaled == 0 {
+		scaled = 1
+	}
+	return scaled
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"fmt"
+	"gopract/objects"
+	"io"
+	"sort"
+	"strings"
+)
+
+// nullHash is printed in place of the hash of a missing file.
+const nullHash = "0000000000000000000000000000000000000000"
+
+// File is one side of a file-level comparison.
+type File struct {
+	Path string
+	Mode string // Octal mode as stored in trees (e.g., "100644")
+	Hash string // Blob hash of the contents
+}
+
+// Change is a path whose contents or mode differ between two snapshots.
+// The side where the path is absent has an empty Hash.
+type Change struct {
+	Path   string
+	Status byte // 'A' (added), 'D' (deleted) or 'M' (modified)
+	Old    File
+	New    File
+}
+
+// TreeFiles flattens a tree into a snapshot keyed by path.
+func TreeFiles(repoPath, treeHash string) (map[string]File, error) {
+	entries, err := objects.FlattenTree(repoPath, treeHash)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]File, len(entries))
+	for p, entry := range entries {
+		files[p] = File{Path: p, Mode: entry.Mode, Hash: entry.Hash}
+	}
+	return files, nil
+}
+
+// Compare returns the changes between two snapshots, sorted by path.
+func Compare(old, new map[string]File) []Change {
+	var changes []Change
+	for p, o := range old {
+		n, ok := new[p]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: p, Status: 'D', Old: o})
+		case o.Hash != n.Hash || o.Mode != n.Mode:
+			changes = append(changes, Change{Path: p, Status: 'M', Old: o, New: n})
+		}
+	}
+	for p, n := range new {
+		if _, ok := old[p]; !ok {
+			changes = append(changes, Change{Path: p, Status: 'A', New: n})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// FilterPaths keeps the changes at or below any of the given paths.
+func FilterPaths(changes []Change, paths []string) []Change {
+	if len(paths) == 0 {
+		return changes
+	}
+	var kept []Change
+	for _, c := range changes {
+		for _, p := range paths {
+			p = strings.TrimSuffix(p, "/")
+			if p == "" || p == "." || c.Path == p || strings.HasPrefix(c.Path, p+"/") {
+				kept = append(kept, c)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// WritePatch writes the Git-style header and unified diff for one change.
+func WritePatch(w io.Writer, c Change, oldData, newData []byte, opts Options) error {
+	var header strings.Builder
+	fmt.Fprintf(&header, "diff --git a/%s b/%s\n", c.Path, c.Path)
+
+	oldHash, newHash := c.Old.Hash, c.New.Hash
+	switch c.Status {
+	case 'A':
+		oldHash = nullHash
+		fmt.Fprintf(&header, "new file mode %s\n", c.New.Mode)
+	case 'D':
+		newHash = nullHash
+		fmt.Fprintf(&header, "deleted file mode %s\n", c.Old.Mode)
+	default:
+		if c.Old.Mode != c.New.Mode {
+			fmt.Fprintf(&header, "old mode %s\nnew mode %s\n", c.Old.Mode, c.New.Mode)
+		}
+	}
+
+	// A pure mode change has no content section
+	if oldHash == newHash {
+		_, err := io.WriteString(w, header.String())
+		return err
+	}
+
+	if c.Status == 'M' && c.Old.Mode == c.New.Mode {
+		fmt.Fprintf(&header, "index %s..%s %s\n", oldHash[:7], newHash[:7], c.Old.Mode)
+	} else {
+		fmt.Fprintf(&header, "index %s..%s\n", oldHash[:7], newHash[:7])
+	}
+
+	oldName, newName := "a/"+c.Path, "b/"+c.Path
+	if c.Status == 'A' {
+		oldName = "/dev/null"
+	}
+	if c.Status == 'D' {
+		newName = "/dev/null"
+	}
+
+	if IsBinary(oldData) || IsBinary(newData) {
+		fmt.Fprintf(&header, "Binary files %s and %s differ\n", oldName, newName)
+		_, err := io.WriteString(w, header.String())
+		return err
+	}
+
+	fmt.Fprintf(&header, "--- %s\n+++ %s\n", oldName, newName)
+	if _, err := io.WriteString(w, header.String()); err != nil {
+		return err
+	}
+	return WriteHunks(w, oldData, newData, opts)
+}
+
+// WriteNameStatus prints "<status>\t<path>" for each change.
+func WriteNameStatus(w io.Writer, changes []Change) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "%c\t%s\n", c.Status, c.Path)
+	}
+}
+
+// WriteNameOnly prints the path of each change.
+func WriteNameOnly(w io.Writer, changes []Change) {
+	for _, c := range changes {
+		fmt.Fprintln(w, c.Path)
+	}
+}
@@ -0,0 +1,154 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change.
+const DefaultContext = 3
+
+// Options controls how differences are computed and printed.
+type Options struct {
+	Context   int       // Lines of context around each change
+	Algorithm Algorithm // Algorithm used to match lines
+}
+
+// Hunk is a group of nearby changes with surrounding context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []string // Lines prefixed with ' ', '-' or '+', each ending in a newline
+}
+
+// Hunks groups an edit script over a and b into hunks.
+func Hunks(a, b []string, edits []Edit, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	// oldBefore[i] and newBefore[i] count the lines preceding edits[i]
+	oldBefore := make([]int, len(edits)+1)
+	newBefore := make([]int, len(edits)+1)
+	for i, e := range edits {
+		oldBefore[i+1], newBefore[i+1] = oldBefore[i], newBefore[i]
+		if e.Op != Insert {
+			oldBefore[i+1]++
+		}
+		if e.Op != Delete {
+			newBefore[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].Op == Equal {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend over changes separated by at most 2*context unchanged lines
+		end := i
+		for {
+			for end < len(edits) && edits[end].Op != Equal {
+				end++
+			}
+			next := end
+			for next < len(edits) && edits[next].Op == Equal {
+				next++
+			}
+			if next < len(edits) && next-end <= 2*context {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		hunk := Hunk{
+			OldStart: oldBefore[start],
+			OldLines: oldBefore[stop] - oldBefore[start],
+			NewStart: newBefore[start],
+			NewLines: newBefore[stop] - newBefore[start],
+		}
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		for _, e := range edits[start:stop] {
+			switch e.Op {
+			case Equal:
+				hunk.Lines = append(hunk.Lines, hunkLine(' ', a[e.OldLine]))
+			case Delete:
+				hunk.Lines = append(hunk.Lines, hunkLine('-', a[e.OldLine]))
+			case Insert:
+				hunk.Lines = append(hunk.Lines, hunkLine('+', b[e.NewLine]))
+			}
+		}
+		hunks = append(hunks, hunk)
+		i = stop
+	}
+	return hunks
+}
+
+// hunkLine prefixes a line and marks a missing final newline the way Git does.
+func hunkLine(prefix byte, line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return string(prefix) + line
+	}
+	return string(prefix) + line + "\n\\ No newline at end of file\n"
+}
+
+// Header renders the "@@ -a,b +c,d @@" line of a hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// WriteHunks writes the unified diff body for two versions of a file.
+func WriteHunks(w io.Writer, oldData, newData []byte, opts Options) error {
+	a, b := SplitLines(oldData), SplitLines(newData)
+	for _, hunk := range Hunks(a, b, Lines(a, b, opts.Algorithm), opts.Context) {
+		if _, err := fmt.Fprintln(w, hunk.Header()); err != nil {
+			return err
+		}
+		for _, line := range hunk.Lines {
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Count returns the number of inserted and deleted lines in an edit script.
+func Count(edits []Edit) (insertions, deletions int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			insertions++
+		case Delete:
+			deletions++
+		}
+	}
+	return insertions, deletions
+}
@@ -0,0 +1,261 @@
+// Package ignore decides which untracked files Git should leave alone, using
+// the rules of gitignore(5): `.gitignore` files in the worktree,
+// `.git/info/exclude` and the file named by `core.excludesFile`.
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopract/config"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Rule is one pattern line of an ignore file.
+type Rule struct {
+	Source  string // File the rule was read from, e.g. ".gitignore" or "sub/.gitignore"
+	Line    int    // Line number within Source
+	Pattern string // The pattern as written, including any leading "!"
+	Negate  bool   // The rule re-includes paths excluded by an earlier rule
+
+	base     string // Directory containing the ignore file, "" for the root
+	glob     string // Pattern without the "!", leading "/" and trailing "/"
+	dirOnly  bool   // Only matches directories (trailing "/")
+	anchored bool   // Matched against the path relative to base rather than the file name
+}
+
+// Matcher answers whether worktree paths are ignored. `.gitignore` files
+// are read on demand as directories are visited.
+type Matcher struct {
+	root   string
+	global []Rule            // core.excludesFile, then .git/info/exclude
+	dirs   map[string][]Rule // .gitignore rules by directory, "" for the root
+}
+
+// New loads the repository-wide exclude files of a repository.
+func New(repoPath string) (*Matcher, error) {
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	m := &Matcher{root: root, dirs: make(map[string][]Rule)}
+
+	// Later rules take precedence, so the personal exclude file comes first
+	if excludesFile := globalExcludesFile(root); excludesFile != "" {
+		rules, err := readRules(excludesFile, excludesFile, "")
+		if err != nil {
+			return nil, err
+		}
+		m.global = append(m.global, rules...)
+	}
+	rules, err := readRules(filepath.Join(root, ".git", "info", "exclude"), ".git/info/exclude", "")
+	if err != nil {
+		return nil, err
+	}
+	m.global = append(m.global, rules...)
+	return m, nil
+}
+
+// globalExcludesFile returns core.excludesFile, defaulting to
+// $XDG_CONFIG_HOME/git/ignore as Git does.
+func globalExcludesFile(repoPath string) string {
+	if value, ok := config.ReadValue(repoPath, "core", "excludesFile"); ok {
+		if rest, ok := strings.CutPrefix(value, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				return filepath.Join(home, rest)
+			}
+		}
+		return value
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
+
+// Ignored reports whether a path, relative to the worktree root with
+// forward slashes, is ignored.
+func (m *Matcher) Ignored(p string, isDir bool) bool {
+	rule := m.Match(p, isDir)
+	return rule != nil && !rule.Negate
+}
+
+// Match returns the rule that decides whether a path is ignored, or nil if
+// no rule matches. The rule may be a negated one that re-includes the path.
+// A path inside an ignored directory is ignored by the directory's rule,
+// since Git does not look inside excluded directories.
+func (m *Matcher) Match(p string, isDir bool) *Rule {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	for i := strings.IndexByte(p, '/'); i >= 0; i = nextSlash(p, i) {
+		if rule := m.matchPath(p[:i], true); rule != nil && !rule.Negate {
+			return rule
+		}
+	}
+	return m.matchPath(p, isDir)
+}
+
+// nextSlash returns the index of the next "/" in p after i, or -1.
+func nextSlash(p string, i int) int {
+	if j := strings.IndexByte(p[i+1:], '/'); j >= 0 {
+		return i + 1 + j
+	}
+	return -1
+}
+
+// matchPath finds the last rule matching the path itself, looking at the
+// `.gitignore` files from the path's own directory up to the root and then
+// at the repository-wide files.
+func (m *Matcher) matchPath(p string, isDir bool) *Rule {
+	dir := path.Dir(p)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		if rule := lastMatch(m.dirRules(dir), p, isDir); rule != nil {
+			return rule
+		}
+		if dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	return lastMatch(m.global, p, isDir)
+}
+
+// dirRules returns the rules of the `.gitignore` file in a directory.
+func (m *Matcher) dirRules(dir string) []Rule {
+	if rules, ok := m.dirs[dir]; ok {
+		return rules
+	}
+	source := path.Join(dir, ".gitignore")
+	// An unreadable .gitignore is treated as empty, as in Git
+	rules, _ := readRules(filepath.Join(m.root, filepath.FromSlash(source)), source, dir)
+	m.dirs[dir] = rules
+	return rules
+}
+
+// lastMatch returns the last of the rules that matches the path.
+func lastMatch(rules []Rule, p string, isDir bool) *Rule {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(p, isDir) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule's pattern matches a path.
+func (r *Rule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel := p
+	if r.base != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(p, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if !r.anchored {
+		return matchSegment(r.glob, path.Base(rel))
+	}
+	return matchSegments(strings.Split(r.glob, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path components against pattern components, where
+// a "**" component matches any number of directories.
+func matchSegments(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			if len(patterns) == 1 {
+				return len(names) > 0 // "dir/**" matches everything inside dir
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 || !matchSegment(patterns[0], names[0]) {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// matchSegment matches a single path component against a glob.
+func matchSegment(pattern, name string) bool {
+	// path.Match spells negated classes "[^...]" only
+	pattern = strings.ReplaceAll(pattern, "[!", "[^")
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// readRules parses an ignore file. A missing file has no rules.
+func readRules(filePath, source, base string) ([]Rule, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	return parseRules(data, source, base), nil
+}
+
+// parseRules parses the contents of an ignore file located in directory
+// base (relative to the worktree root, "" for the root).
+func parseRules(data []byte, source, base string) []Rule {
+	var rules []Rule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := trimTrailingSpaces(strings.TrimSuffix(scanner.Text(), "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := Rule{Source: source, Line: lineNo, Pattern: line, base: base}
+		glob := line
+		if rest, ok := strings.CutPrefix(glob, "!"); ok {
+			rule.Negate, glob = true, rest
+		} else if strings.HasPrefix(glob, `\!`) || strings.HasPrefix(glob, `\#`) {
+			glob = glob[1:]
+		}
+		if rest, ok := strings.CutSuffix(glob, "/"); ok {
+			rule.dirOnly, glob = true, rest
+		}
+		// A slash anywhere but at the end ties the pattern to base
+		if strings.Contains(glob, "/") {
+			rule.anchored = true
+			glob = strings.TrimPrefix(glob, "/")
+		}
+		if glob == "" {
+			continue
+		}
+		rule.glob = glob
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// trimTrailingSpaces removes trailing spaces unless they are escaped with a
+// backslash.
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
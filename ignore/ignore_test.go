@@ -0,0 +1,195 @@
+package ignore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	data := "# comment\n" +
+		"\n" +
+		"*.o\n" +
+		"!keep.o\n" +
+		"\\!important\n" +
+		"\\#hash\n" +
+		"trailing   \n" +
+		"escaped\\ \n" +
+		"build/\n" +
+		"/root-only\n" +
+		"doc/*.txt\n" +
+		"/\n" +
+		"crlf\r\n"
+
+	type want struct {
+		glob            string
+		negate, dirOnly bool
+		anchored        bool
+		line            int
+	}
+	wants := []want{
+		{glob: "*.o", line: 3},
+		{glob: "keep.o", negate: true, line: 4},
+		{glob: "!important", line: 5},
+		{glob: "#hash", line: 6},
+		{glob: "trailing", line: 7},
+		{glob: `escaped\ `, line: 8},
+		{glob: "build", dirOnly: true, line: 9},
+		{glob: "root-only", anchored: true, line: 10},
+		{glob: "doc/*.txt", anchored: true, line: 11},
+		{glob: "crlf", line: 13},
+	}
+
+	rules := parseRules([]byte(data), ".gitignore", "")
+	if len(rules) != len(wants) {
+		t.Fatalf("got %d rules, want %d: %+v", len(rules), len(wants), rules)
+	}
+	for i, w := range wants {
+		r := rules[i]
+		if r.glob != w.glob || r.Negate != w.negate || r.dirOnly != w.dirOnly || r.anchored != w.anchored || r.Line != w.line {
+			t.Errorf("rule %d = {glob %q negate %v dirOnly %v anchored %v line %d}, want %+v",
+				i, r.glob, r.Negate, r.dirOnly, r.anchored, r.Line, w)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		base    string // Directory of the ignore file
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{pattern: "*.o", path: "a.o", want: true},
+		{pattern: "*.o", path: "dir/sub/a.o", want: true},
+		{pattern: "*.o", path: "a.c", want: false},
+		{pattern: "/build", path: "build", want: true},
+		{pattern: "/build", path: "sub/build", want: false},
+		{pattern: "doc/*.txt", path: "doc/a.txt", want: true},
+		{pattern: "doc/*.txt", path: "doc/sub/a.txt", want: false},
+		{pattern: "doc/*.txt", path: "x/doc/a.txt", want: false},
+		{pattern: "**/foo", path: "foo", want: true},
+		{pattern: "**/foo", path: "a/b/foo", want: true},
+		{pattern: "a/**/b", path: "a/b", want: true},
+		{pattern: "a/**/b", path: "a/x/y/b", want: true},
+		{pattern: "a/**/b", path: "x/a/b", want: false},
+		{pattern: "abc/**", path: "abc/x/y", want: true},
+		{pattern: "abc/**", path: "abc", isDir: true, want: false},
+		{pattern: "logs/", path: "logs", isDir: true, want: true},
+		{pattern: "logs/", path: "logs", want: false},
+		{pattern: "logs/", path: "a/logs", isDir: true, want: true},
+		{pattern: "[!a]bc", path: "xbc", want: true},
+		{pattern: "[!a]bc", path: "abc", want: false},
+		{pattern: "?.txt", path: "a.txt", want: true},
+		{pattern: "\\!important", path: "!important", want: true},
+		{pattern: "\\#hash", path: "#hash", want: true},
+		{pattern: "escaped\\ ", path: "escaped ", want: true},
+		{pattern: "trailing  ", path: "trailing", want: true},
+		{pattern: "*.log", base: "sub", path: "sub/a/x.log", want: true},
+		{pattern: "*.log", base: "sub", path: "x.log", want: false},
+		{pattern: "/only", base: "sub", path: "sub/only", want: true},
+		{pattern: "/only", base: "sub", path: "sub/a/only", want: false},
+		{pattern: "/only", base: "sub", path: "subway/only", want: false},
+	}
+
+	for _, tt := range tests {
+		rules := parseRules([]byte(tt.pattern+"\n"), ".gitignore", tt.base)
+		if len(rules) != 1 {
+			t.Fatalf("%q parsed to %d rules", tt.pattern, len(rules))
+		}
+		if got := rules[0].matches(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q (in %q) matching %q (dir %v) = %v, want %v", tt.pattern, tt.base, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherPrecedence(t *testing.T) {
+	// Every directory the paths below visit has its rules given here, so
+	// no ignore file is read from disk
+	newMatcher := func(global string, dirs map[string]string) *Matcher {
+		m := &Matcher{
+			root:   filepath.Join(t.TempDir(), "missing"),
+			global: parseRules([]byte(global), ".git/info/exclude", ""),
+			dirs:   make(map[string][]Rule),
+		}
+		for _, dir := range []string{"", "sub", "sub/deep", "build", "out"} {
+			m.dirs[dir] = parseRules([]byte(dirs[dir]), filepath.ToSlash(filepath.Join(dir, ".gitignore")), dir)
+		}
+		return m
+	}
+
+	tests := []struct {
+		name   string
+		global string
+		dirs   map[string]string
+		path   string
+		isDir  bool
+		want   bool
+	}{
+		{
+			name: "nested file re-includes",
+			dirs: map[string]string{"": "*.log\n", "sub": "!keep.log\n"},
+			path: "sub/keep.log", want: false,
+		},
+		{
+			name: "nested negation only applies below it",
+			dirs: map[string]string{"": "*.log\n", "sub": "!keep.log\n"},
+			path: "keep.log", want: true,
+		},
+		{
+			name: "deeper file wins",
+			dirs: map[string]string{"": "!*.log\n", "sub": "*.log\n", "sub/deep": "!x.log\n"},
+			path: "sub/deep/x.log", want: false,
+		},
+		{
+			name: "last rule in a file wins",
+			dirs: map[string]string{"": "*.log\n!x.log\n*.log\n"},
+			path: "x.log", want: true,
+		},
+		{
+			name:   ".gitignore beats exclude files",
+			global: "*.tmp\n",
+			dirs:   map[string]string{"": "!important.tmp\n"},
+			path:   "important.tmp", want: false,
+		},
+		{
+			name:   "exclude files apply without .gitignore",
+			global: "*.tmp\n",
+			path:   "sub/x.tmp", want: true,
+		},
+		{
+			name: "negation under an excluded directory",
+			dirs: map[string]string{"": "build/\n!build/keep.txt\n"},
+			path: "build/keep.txt", want: true,
+		},
+		{
+			name: "negation under excluded contents",
+			dirs: map[string]string{"": "out/*\n!out/keep.txt\n"},
+			path: "out/keep.txt", want: false,
+		},
+		{
+			name: "nested .gitignore cannot re-include inside an excluded directory",
+			dirs: map[string]string{"": "build/\n", "build": "!keep.txt\n"},
+			path: "build/keep.txt", want: true,
+		},
+		{
+			name: "directory-only rule ignores files inside",
+			dirs: map[string]string{"": "sub/\n"},
+			path: "sub/deep/file", want: true,
+		},
+		{
+			name: "directory-only rule skips a file of that name",
+			dirs: map[string]string{"": "sub/\n"},
+			path: "sub", want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMatcher(tt.global, tt.dirs)
+			if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Ignored(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"gopract/commands"
 	"gopract/config"
+	"gopract/diff"
+	"gopract/merge"
+	"gopract/refs"
 	"gopract/repository"
+	"gopract/revparse"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -40,6 +46,54 @@ func main() {
 		handleAdd(os.Args[2:])
 	case "commit":
 		handleCommit(os.Args[2:])
+	case "repack":
+		handleRepack(os.Args[2:])
+	case "tag":
+		handleTag(os.Args[2:])
+	case "status":
+		handleStatus(os.Args[2:])
+	case "log":
+		handleLog(os.Args[2:])
+	case "diff":
+		handleDiff(os.Args[2:])
+	case "branch":
+		handleBranch(os.Args[2:])
+	case "checkout":
+		handleCheckout(os.Args[2:])
+	case "switch":
+		handleSwitch(os.Args[2:])
+	case "merge":
+		handleMerge(os.Args[2:])
+	case "reset":
+		handleReset(os.Args[2:])
+	case "cherry-pick":
+		handleSequencer("cherry-pick", os.Args[2:], commands.CherryPick)
+	case "revert":
+		handleSequencer("revert", os.Args[2:], commands.Revert)
+	case "stash":
+		handleStash(os.Args[2:])
+	case "rm":
+		handleRm(os.Args[2:])
+	case "mv":
+		handleMv(os.Args[2:])
+	case "rev-parse":
+		handleRevParse(os.Args[2:])
+	case "gc":
+		handleGC(os.Args[2:])
+	case "prune":
+		handlePrune(os.Args[2:])
+	case "fsck":
+		handleFsck(os.Args[2:])
+	case "check-ignore":
+		handleCheckIgnore(os.Args[2:])
+	case "clean":
+		handleClean(os.Args[2:])
+	case "reflog":
+		handleReflog(os.Args[2:])
+	case "verify-commit":
+		handleVerify("verify-commit", os.Args[2:], commands.VerifyCommit)
+	case "verify-tag":
+		handleVerify("verify-tag", os.Args[2:], commands.VerifyTag)
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -56,7 +110,31 @@ func printUsage() {
 	fmt.Println("  hash-object   Compute hash of a file and optionally write it")
 	fmt.Println("  cat-file      Show content of a repository object")
 	fmt.Println("  add           Add files to the staging area")
+	fmt.Println("  rm            Remove files from the worktree and the staging area")
+	fmt.Println("  mv            Move or rename files and directories")
 	fmt.Println("  commit        Commit staged changes to the repository")
+	fmt.Println("  repack        Pack loose objects into a single packfile")
+	fmt.Println("  tag           Create, list or delete tags")
+	fmt.Println("  status        Show staged, unstaged and untracked files")
+	fmt.Println("  log           Show commit history")
+	fmt.Println("  diff          Show changes between commits, the index and the worktree")
+	fmt.Println("  branch        List, create, rename or delete branches")
+	fmt.Println("  checkout      Switch branches or check out a commit into the worktree")
+	fmt.Println("  switch        Switch branches")
+	fmt.Println("  merge         Join another branch's history into the current branch")
+	fmt.Println("  reset         Move the current branch and reset the index or worktree")
+	fmt.Println("  cherry-pick   Apply the changes introduced by existing commits")
+	fmt.Println("  revert        Commit the inverse of existing commits")
+	fmt.Println("  stash         Shelve local changes and restore them later")
+	fmt.Println("  rev-parse     Resolve revisions to object names")
+	fmt.Println("  gc            Pack refs and objects and delete old unreachable objects")
+	fmt.Println("  prune         Delete unreachable loose objects")
+	fmt.Println("  fsck          Verify the connectivity and validity of the object database")
+	fmt.Println("  check-ignore  Show which rule, if any, ignores each path")
+	fmt.Println("  clean         Remove untracked files from the worktree")
+	fmt.Println("  reflog        Show or expire the history of ref updates")
+	fmt.Println("  verify-commit Check the SSH signatures of commits")
+	fmt.Println("  verify-tag    Check the SSH signatures of tags")
 }
 
 // handleConfig processes the `config` command to display configuration details.
@@ -216,9 +294,12 @@ func handleHashObject(args []string) {
 
 func handleCatFile(args []string) {
 	catFlags := flag.NewFlagSet("cat-file", flag.ExitOnError)
-	sha := catFlags.String("sha", "", "SHA of the object to read")
+	sha := catFlags.String("sha", "", "Object to read: a SHA (possibly abbreviated) or any other revision")
 	catFlags.Parse(args)
 
+	if *sha == "" && catFlags.NArg() > 0 {
+		*sha = catFlags.Arg(0)
+	}
 	if *sha == "" {
 		fmt.Println("SHA is required")
 		return
@@ -232,32 +313,744 @@ func handleCatFile(args []string) {
 
 func handleAdd(args []string) {
 	addFlags := flag.NewFlagSet("add", flag.ExitOnError)
-	filePath := addFlags.String("file", "", "File to add to the staging area")
+	filePath := addFlags.String("file", "", "File to add to the staging area (same as a pathspec argument)")
+	all := addFlags.Bool("A", false, "Stage new, modified and deleted files (the whole tree without pathspecs)")
+	update := addFlags.Bool("u", false, "Stage modified and deleted tracked files only")
+	force := addFlags.Bool("f", false, "Also add ignored files")
 	addFlags.Parse(args)
 
-	if *filePath == "" {
-		fmt.Println("File path is required")
+	pathspecs := addFlags.Args()
+	if *filePath != "" {
+		pathspecs = append([]string{*filePath}, pathspecs...)
+	}
+
+	// Stage relative to the root of the repository the current directory is in
+	repo, err := repository.Find(".", true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	err := commands.Add(".", *filePath)
+	err = commands.Add(repo.Worktree, pathspecs, commands.AddOptions{All: *all, Update: *update, Force: *force})
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 	}
 }
 
+// handleSequencer runs cherry-pick or revert, which share their options.
+func handleSequencer(name string, args []string, run func(repoPath string, revisions []string, opts commands.SequencerOptions) error) {
+	sequencerFlags := flag.NewFlagSet(name, flag.ExitOnError)
+	mainline := sequencerFlags.Int("m", 0, "Parent number (starting from 1) to compare merge commits against")
+	cont := sequencerFlags.Bool("continue", false, "Continue after resolving a conflict")
+	abort := sequencerFlags.Bool("abort", false, "Cancel the operation and return to the original commit")
+	sequencerFlags.Parse(args)
+
+	if !*cont && !*abort && sequencerFlags.NArg() == 0 {
+		fmt.Printf("Usage: %s [-m <parent>] <commit>... | --continue | --abort\n", name)
+		return
+	}
+
+	opts := commands.SequencerOptions{Mainline: *mainline, Continue: *cont, Abort: *abort}
+	if err := run(".", sequencerFlags.Args(), opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// handleStash dispatches the stash subcommands, defaulting to push.
+func handleStash(args []string) {
+	subcommand := "push"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand, args = args[0], args[1:]
+	}
+
+	stashFlags := flag.NewFlagSet("stash "+subcommand, flag.ExitOnError)
+	message := stashFlags.String("m", "", "Describe the stash entry (push)")
+	index := stashFlags.Bool("index", false, "Also restore the staged changes (apply, pop)")
+	patch := stashFlags.Bool("p", false, "Show the changes as a patch (show)")
+	stashFlags.Parse(args)
+
+	var err error
+	switch subcommand {
+	case "push":
+		err = commands.StashPush(".", *message)
+	case "list":
+		err = commands.StashList(".")
+	case "show":
+		err = commands.StashShow(".", stashFlags.Arg(0), *patch)
+	case "apply":
+		err = commands.StashApply(".", stashFlags.Arg(0), *index)
+	case "pop":
+		err = commands.StashPop(".", stashFlags.Arg(0), *index)
+	case "drop":
+		err = commands.StashDrop(".", stashFlags.Arg(0))
+	default:
+		fmt.Println("Usage: stash [push [-m <message>] | list | show [-p] [<stash>] | apply [--index] [<stash>] | pop [--index] [<stash>] | drop [<stash>]]")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleRm(args []string) {
+	rmFlags := flag.NewFlagSet("rm", flag.ExitOnError)
+	cached := rmFlags.Bool("cached", false, "Only remove from the staging area, keeping the files")
+	recursive := rmFlags.Bool("r", false, "Allow recursive removal when a directory is given")
+	force := rmFlags.Bool("f", false, "Remove files even if they have local or staged changes")
+	dryRun := rmFlags.Bool("n", false, "Only show which files would be removed")
+	rmFlags.Parse(args)
+
+	repo, err := repository.Find(".", true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	opts := commands.RmOptions{Cached: *cached, Recursive: *recursive, Force: *force, DryRun: *dryRun}
+	if err := commands.Rm(repo.Worktree, rmFlags.Args(), opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleMv(args []string) {
+	mvFlags := flag.NewFlagSet("mv", flag.ExitOnError)
+	force := mvFlags.Bool("f", false, "Overwrite an existing destination file")
+	dryRun := mvFlags.Bool("n", false, "Only show what would be moved")
+	verbose := mvFlags.Bool("v", false, "Report the names of files as they are moved")
+	mvFlags.Parse(args)
+
+	if mvFlags.NArg() < 2 {
+		fmt.Println("Usage: mv [-f] [-n] [-v] <source>... <destination>")
+		return
+	}
+
+	repo, err := repository.Find(".", true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	sources, destination := mvFlags.Args()[:mvFlags.NArg()-1], mvFlags.Arg(mvFlags.NArg()-1)
+	opts := commands.MvOptions{Force: *force, DryRun: *dryRun, Verbose: *verbose}
+	if err := commands.Mv(repo.Worktree, sources, destination, opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
 func handleCommit(args []string) {
 	commitFlags := flag.NewFlagSet("commit", flag.ExitOnError)
-	message := commitFlags.String("m", "", "Commit message")
+	message := commitFlags.String("m", "", "Commit message (defaults to the prepared merge message)")
+	sign := commitFlags.Bool("S", false, "Sign the commit with the SSH key in user.signingkey")
 	commitFlags.Parse(args)
 
-	if *message == "" {
-		fmt.Println("Commit message is required")
+	err := commands.Commit(".", *message, *sign)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleRepack(args []string) {
+	repackFlags := flag.NewFlagSet("repack", flag.ExitOnError)
+	deleteRedundant := repackFlags.Bool("d", false, "Delete loose objects and old packs made redundant by the new pack")
+	repackFlags.Parse(args)
+
+	err := commands.Repack(".", *deleteRedundant)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleTag(args []string) {
+	tagFlags := flag.NewFlagSet("tag", flag.ExitOnError)
+	annotate := tagFlags.Bool("a", false, "Create an annotated tag object")
+	message := tagFlags.String("m", "", "Tag message (implies -a)")
+	sign := tagFlags.Bool("s", false, "Make an SSH-signed annotated tag with the key in user.signingkey")
+	force := tagFlags.Bool("f", false, "Replace an existing tag")
+	list := tagFlags.Bool("l", false, "List tags")
+	del := tagFlags.Bool("d", false, "Delete the named tag")
+	tagFlags.Parse(args)
+
+	var err error
+	switch {
+	case *list || tagFlags.NArg() == 0:
+		err = commands.ListTags(".")
+	case *del:
+		for _, name := range tagFlags.Args() {
+			if err = commands.DeleteTag(".", name); err != nil {
+				break
+			}
+		}
+	default:
+		target := "HEAD"
+		if tagFlags.NArg() > 1 {
+			target = tagFlags.Arg(1)
+		}
+		annotated := *annotate || *sign || *message != ""
+		if annotated && *message == "" {
+			fmt.Println("Annotated tags require a message (-m)")
+			return
+		}
+		err = commands.CreateTag(".", tagFlags.Arg(0), target, *message, annotated, *sign, *force)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleStatus(args []string) {
+	statusFlags := flag.NewFlagSet("status", flag.ExitOnError)
+	var short bool
+	statusFlags.BoolVar(&short, "s", false, "Give the output in the short format")
+	statusFlags.BoolVar(&short, "short", false, "Give the output in the short format")
+	statusFlags.BoolVar(&short, "porcelain", false, "Give the output in a stable, machine-readable format")
+	statusFlags.Parse(args)
+
+	err := commands.Status(".", short)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleLog(args []string) {
+	logFlags := flag.NewFlagSet("log", flag.ExitOnError)
+	maxCount := logFlags.Int("n", 0, "Limit the number of commits shown")
+	oneline := logFlags.Bool("oneline", false, "Show each commit on a single line")
+	graph := logFlags.Bool("graph", false, "Draw the commit graph")
+	author := logFlags.String("author", "", "Only show commits by authors matching the pattern")
+	since := logFlags.String("since", "", "Only show commits more recent than the date")
+	until := logFlags.String("until", "", "Only show commits older than the date")
+	topoOrder := logFlags.Bool("topo-order", false, "Show no parents before all of their children, grouping lines of history")
+	dateOrder := logFlags.Bool("date-order", false, "Show no parents before all of their children, otherwise by date")
+
+	// Everything after "--" is a path, even if it looks like a revision
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, paths = args[:i], args[i+1:]
+			break
+		}
+	}
+	logFlags.Parse(args)
+
+	opts := commands.LogOptions{
+		MaxCount: *maxCount,
+		Oneline:  *oneline,
+		Graph:    *graph,
+		Author:   *author,
+	}
+	if *topoOrder {
+		opts.Order = commands.OrderTopo
+	} else if *dateOrder {
+		opts.Order = commands.OrderDate
+	}
+
+	var err error
+	if *since != "" {
+		if opts.Since, err = commands.ParseDate(*since); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = commands.ParseDate(*until); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	// Arguments are [<revision>] [<path>...] [-- <path>...]
+	rest := logFlags.Args()
+	if len(rest) > 0 {
+		opts.Revision = rest[0]
+		rest = rest[1:]
+	}
+	opts.Paths = append(rest, paths...)
+
+	err = commands.Log(".", opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleDiff(args []string) {
+	diffFlags := flag.NewFlagSet("diff", flag.ExitOnError)
+	cached := diffFlags.Bool("cached", false, "Compare the index with HEAD (or the given commit)")
+	staged := diffFlags.Bool("staged", false, "Synonym for --cached")
+	stat := diffFlags.Bool("stat", false, "Show a diffstat instead of a patch")
+	nameOnly := diffFlags.Bool("name-only", false, "Show only the names of changed files")
+	nameStatus := diffFlags.Bool("name-status", false, "Show the names and status of changed files")
+	context := diffFlags.Int("U", diff.DefaultContext, "Number of context lines")
+	algorithm := diffFlags.String("diff-algorithm", "myers", "Diff algorithm: myers, patience or histogram")
+	patience := diffFlags.Bool("patience", false, "Use the patience diff algorithm")
+	histogram := diffFlags.Bool("histogram", false, "Use the histogram diff algorithm")
+
+	// Everything after "--" is a path, even if it looks like a revision
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, paths = args[:i], args[i+1:]
+			break
+		}
+	}
+	diffFlags.Parse(args)
+
+	if *patience {
+		*algorithm = string(diff.Patience)
+	} else if *histogram {
+		*algorithm = string(diff.Histogram)
+	}
+	algo, err := diff.ParseAlgorithm(*algorithm)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	opts := commands.DiffOptions{
+		Cached:    *cached || *staged,
+		Revisions: diffFlags.Args(),
+		Paths:     paths,
+		Format:    commands.DiffPatch,
+		Context:   *context,
+		Algorithm: algo,
+	}
+	switch {
+	case *stat:
+		opts.Format = commands.DiffStat
+	case *nameOnly:
+		opts.Format = commands.DiffNameOnly
+	case *nameStatus:
+		opts.Format = commands.DiffNameStatus
+	}
+	if len(opts.Revisions) > 2 {
+		fmt.Println("At most two revisions can be compared")
+		return
+	}
+
+	err = commands.Diff(".", opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleBranch(args []string) {
+	branchFlags := flag.NewFlagSet("branch", flag.ExitOnError)
+	list := branchFlags.Bool("l", false, "List branches")
+	del := branchFlags.Bool("d", false, "Delete a fully merged branch")
+	forceDel := branchFlags.Bool("D", false, "Delete a branch even if it is not merged")
+	move := branchFlags.Bool("m", false, "Rename a branch")
+	forceMove := branchFlags.Bool("M", false, "Rename a branch even if the new name exists")
+	force := branchFlags.Bool("f", false, "Reset an existing branch to the start point")
+	branchFlags.Parse(args)
+
+	var err error
+	switch {
+	case *del || *forceDel:
+		if branchFlags.NArg() == 0 {
+			fmt.Println("Branch name required")
+			return
+		}
+		for _, name := range branchFlags.Args() {
+			if err = commands.DeleteBranch(".", name, *forceDel || *force); err != nil {
+				break
+			}
+		}
+	case *move || *forceMove:
+		var oldName, newName string
+		switch branchFlags.NArg() {
+		case 1:
+			current, onBranch, herr := refs.CurrentBranch(".")
+			if herr != nil || !onBranch {
+				fmt.Println("Not on a branch; specify the branch to rename")
+				return
+			}
+			oldName, newName = refs.ShortName(current), branchFlags.Arg(0)
+		case 2:
+			oldName, newName = branchFlags.Arg(0), branchFlags.Arg(1)
+		default:
+			fmt.Println("Usage: branch -m [<old>] <new>")
+			return
+		}
+		err = commands.RenameBranch(".", oldName, newName, *forceMove || *force)
+	case *list || branchFlags.NArg() == 0:
+		err = commands.ListBranches(".")
+	default:
+		startPoint := "HEAD"
+		if branchFlags.NArg() > 1 {
+			startPoint = branchFlags.Arg(1)
+		}
+		err = commands.CreateBranch(".", branchFlags.Arg(0), startPoint, *force)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleCheckout(args []string) {
+	checkoutFlags := flag.NewFlagSet("checkout", flag.ExitOnError)
+	newBranch := checkoutFlags.String("b", "", "Create a new branch and switch to it")
+	resetBranch := checkoutFlags.String("B", "", "Create or reset a branch and switch to it")
+	detach := checkoutFlags.Bool("detach", false, "Detach HEAD at the commit")
+	var force bool
+	checkoutFlags.BoolVar(&force, "f", false, "Throw away local changes")
+	checkoutFlags.BoolVar(&force, "force", false, "Throw away local changes")
+	checkoutFlags.Parse(args)
+
+	opts := commands.CheckoutOptions{NewBranch: *newBranch, Detach: *detach, Force: force}
+	if *resetBranch != "" {
+		opts.NewBranch, opts.ResetBranch = *resetBranch, true
+	}
+	if opts.NewBranch == "" && checkoutFlags.NArg() == 0 {
+		fmt.Println("Branch or commit is required")
+		return
+	}
+
+	err := commands.Checkout(".", checkoutFlags.Arg(0), opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleSwitch(args []string) {
+	switchFlags := flag.NewFlagSet("switch", flag.ExitOnError)
+	create := switchFlags.String("c", "", "Create a new branch and switch to it")
+	forceCreate := switchFlags.String("C", "", "Create or reset a branch and switch to it")
+	var detach, force bool
+	switchFlags.BoolVar(&detach, "d", false, "Detach HEAD at the commit")
+	switchFlags.BoolVar(&detach, "detach", false, "Detach HEAD at the commit")
+	switchFlags.BoolVar(&force, "f", false, "Throw away local changes")
+	switchFlags.BoolVar(&force, "force", false, "Throw away local changes")
+	switchFlags.BoolVar(&force, "discard-changes", false, "Throw away local changes")
+	switchFlags.Parse(args)
+
+	opts := commands.CheckoutOptions{NewBranch: *create, Detach: detach, Force: force}
+	if *forceCreate != "" {
+		opts.NewBranch, opts.ResetBranch = *forceCreate, true
+	}
+	if opts.NewBranch == "" && switchFlags.NArg() == 0 {
+		fmt.Println("Branch name is required")
 		return
 	}
 
-	err := commands.Commit(".", *message)
+	err := commands.Switch(".", switchFlags.Arg(0), opts)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 	}
 }
+
+func handleMerge(args []string) {
+	mergeFlags := flag.NewFlagSet("merge", flag.ExitOnError)
+	noFF := mergeFlags.Bool("no-ff", false, "Create a merge commit even when the merge is a fast-forward")
+	message := mergeFlags.String("m", "", "Message for the merge commit")
+	conflict := mergeFlags.String("conflict", "merge", "Conflict marker style: merge or diff3")
+	abort := mergeFlags.Bool("abort", false, "Abort the current conflicted merge")
+	mergeFlags.Parse(args)
+
+	style, err := merge.ParseConflictStyle(*conflict)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if !*abort && mergeFlags.NArg() != 1 {
+		fmt.Println("Exactly one commit to merge is required")
+		return
+	}
+
+	err = commands.Merge(".", commands.MergeOptions{
+		Revision: mergeFlags.Arg(0),
+		NoFF:     *noFF,
+		Message:  *message,
+		Style:    style,
+		Abort:    *abort,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// handleReset moves HEAD with `reset`, or restores index entries when paths
+// are given. Without "--", a first argument that does not resolve is a path.
+func handleReset(args []string) {
+	resetFlags := flag.NewFlagSet("reset", flag.ExitOnError)
+	soft := resetFlags.Bool("soft", false, "Only move the current branch")
+	mixed := resetFlags.Bool("mixed", false, "Move the current branch and reset the index (the default)")
+	hard := resetFlags.Bool("hard", false, "Move the current branch and reset the index and worktree, discarding local changes")
+
+	// Everything after "--" is a path, even if it looks like a revision
+	var paths []string
+	separated := false
+	for i, arg := range args {
+		if arg == "--" {
+			args, paths, separated = args[:i], args[i+1:], true
+			break
+		}
+	}
+	resetFlags.Parse(args)
+
+	opts := commands.ResetOptions{Mode: commands.ResetMixed}
+	switch {
+	case *soft && !*mixed && !*hard:
+		opts.Mode = commands.ResetSoft
+	case *hard && !*soft && !*mixed:
+		opts.Mode = commands.ResetHard
+	case *soft || *hard:
+		fmt.Println("Only one of --soft, --mixed and --hard can be given")
+		return
+	}
+
+	// Without "--" the first argument is a revision only if it resolves
+	rest := resetFlags.Args()
+	if len(rest) > 0 {
+		if _, err := revparse.Resolve(".", rest[0]); separated || err == nil {
+			opts.Revision, rest = rest[0], rest[1:]
+		}
+	}
+	if separated && len(rest) > 0 {
+		fmt.Println("Only one revision can be given before \"--\"")
+		return
+	}
+	opts.Paths = append(rest, paths...)
+
+	repo, err := repository.Find(".", true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	err = commands.Reset(repo.Worktree, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// handleRevParse prints the objects revisions resolve to. It exits with a
+// non-zero status when one cannot be resolved, so scripts can test names.
+func handleRevParse(args []string) {
+	revParseFlags := flag.NewFlagSet("rev-parse", flag.ExitOnError)
+	verify := revParseFlags.Bool("verify", false, "Check that exactly one revision is given and names an existing object")
+	short := revParseFlags.Bool("short", false, "Abbreviate SHAs to a unique prefix of at least 7 characters")
+	abbrevRef := revParseFlags.Bool("abbrev-ref", false, "Print the short ref name instead of the SHA")
+	revParseFlags.Parse(args)
+
+	opts := commands.RevParseOptions{Verify: *verify, AbbrevRef: *abbrevRef}
+	if *short {
+		opts.Short = 7
+	}
+	if err := commands.RevParse(".", revParseFlags.Args(), opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// defaultPruneExpire is how long `gc` keeps unreachable objects, so that
+// objects written by a command that is still running are not deleted.
+const defaultPruneExpire = "2.weeks.ago"
+
+func handleGC(args []string) {
+	gcFlags := flag.NewFlagSet("gc", flag.ExitOnError)
+	prune := gcFlags.String("prune", "", "Delete unreachable objects older than this date, \"now\" or \"never\" (default gc.pruneExpire or "+defaultPruneExpire+")")
+	noPrune := gcFlags.Bool("no-prune", false, "Keep all unreachable objects")
+	dryRun := gcFlags.Bool("dry-run", false, "List the objects that would be removed without changing anything")
+	gcFlags.Parse(args)
+
+	expiry := *prune
+	if expiry == "" {
+		expiry, _ = config.ReadValue(".", "gc", "pruneExpire")
+	}
+	if expiry == "" {
+		expiry = defaultPruneExpire
+	}
+	opts := commands.GCOptions{NoPrune: *noPrune || expiry == "never", DryRun: *dryRun}
+	if !opts.NoPrune {
+		expire, err := commands.ParseDate(expiry)
+		if err != nil {
+			fmt.Printf("Error: invalid prune expiry: %v\n", err)
+			return
+		}
+		opts.Expire = expire
+	}
+
+	err := commands.GC(".", opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handlePrune(args []string) {
+	pruneFlags := flag.NewFlagSet("prune", flag.ExitOnError)
+	var dryRun bool
+	pruneFlags.BoolVar(&dryRun, "n", false, "List the objects that would be removed without removing them")
+	pruneFlags.BoolVar(&dryRun, "dry-run", false, "List the objects that would be removed without removing them")
+	expireValue := pruneFlags.String("expire", "", "Only remove objects older than this date")
+	pruneFlags.Parse(args)
+
+	opts := commands.PruneOptions{DryRun: dryRun}
+	if *expireValue != "" {
+		expire, err := commands.ParseDate(*expireValue)
+		if err != nil {
+			fmt.Printf("Error: invalid expiry: %v\n", err)
+			return
+		}
+		opts.Expire = expire
+	}
+
+	err := commands.Prune(".", opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// handleCheckIgnore exits with status 1 when none of the paths is ignored
+// and 128 on errors, as Git does, so that scripts can test the result.
+func handleCheckIgnore(args []string) {
+	checkIgnoreFlags := flag.NewFlagSet("check-ignore", flag.ExitOnError)
+	verbose := checkIgnoreFlags.Bool("v", false, "Show the source, line and pattern of the matching rule")
+	nonMatching := checkIgnoreFlags.Bool("n", false, "With -v, also show paths that match no rule")
+	checkIgnoreFlags.Parse(args)
+
+	repo, err := repository.Find(".", true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(128)
+	}
+
+	opts := commands.CheckIgnoreOptions{Verbose: *verbose, NonMatching: *nonMatching}
+	ignored, err := commands.CheckIgnore(repo.Worktree, checkIgnoreFlags.Args(), opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(128)
+	}
+	if !ignored {
+		os.Exit(1)
+	}
+}
+
+func handleClean(args []string) {
+	cleanFlags := flag.NewFlagSet("clean", flag.ExitOnError)
+	dryRun := cleanFlags.Bool("n", false, "Only show what would be removed")
+	force := cleanFlags.Bool("f", false, "Remove the files (required unless clean.requireForce is false)")
+	dirs := cleanFlags.Bool("d", false, "Also remove untracked directories")
+	ignored := cleanFlags.Bool("x", false, "Also remove ignored files")
+	onlyIgnored := cleanFlags.Bool("X", false, "Remove only ignored files")
+	cleanFlags.Parse(args)
+
+	repo, err := repository.Find(".", true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	opts := commands.CleanOptions{DryRun: *dryRun, Force: *force, Directories: *dirs, Ignored: *ignored, OnlyIgnored: *onlyIgnored}
+	if err := commands.Clean(repo.Worktree, cleanFlags.Args(), opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// Default reflog expiry times, overridden by gc.reflogExpire and
+// gc.reflogExpireUnreachable.
+const (
+	defaultReflogExpire            = "90.days.ago"
+	defaultReflogExpireUnreachable = "30.days.ago"
+)
+
+func handleReflog(args []string) {
+	if len(args) > 0 && args[0] == "expire" {
+		handleReflogExpire(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+
+	reflogFlags := flag.NewFlagSet("reflog", flag.ExitOnError)
+	maxCount := reflogFlags.Int("n", 0, "Limit the number of entries shown")
+	reflogFlags.Parse(args)
+
+	err := commands.ReflogShow(".", reflogFlags.Arg(0), *maxCount)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func handleReflogExpire(args []string) {
+	expireFlags := flag.NewFlagSet("reflog expire", flag.ExitOnError)
+	expireValue := expireFlags.String("expire", "", "Drop entries older than this date, \"now\" or \"never\" (default gc.reflogExpire or "+defaultReflogExpire+")")
+	unreachableValue := expireFlags.String("expire-unreachable", "", "Drop entries for unreachable commits older than this date (default gc.reflogExpireUnreachable or "+defaultReflogExpireUnreachable+")")
+	all := expireFlags.Bool("all", false, "Expire the reflogs of all refs")
+	var dryRun bool
+	expireFlags.BoolVar(&dryRun, "n", false, "List the entries that would be dropped without changing anything")
+	expireFlags.BoolVar(&dryRun, "dry-run", false, "List the entries that would be dropped without changing anything")
+	expireFlags.Parse(args)
+
+	opts := commands.ReflogExpireOptions{All: *all, DryRun: dryRun}
+	for _, limit := range []struct {
+		value, key, fallback string
+		into                 *time.Time
+	}{
+		{*expireValue, "reflogExpire", defaultReflogExpire, &opts.Expire},
+		{*unreachableValue, "reflogExpireUnreachable", defaultReflogExpireUnreachable, &opts.ExpireUnreachable},
+	} {
+		expiry := limit.value
+		if expiry == "" {
+			expiry, _ = config.ReadValue(".", "gc", limit.key)
+		}
+		if expiry == "" {
+			expiry = limit.fallback
+		}
+		if expiry == "never" || expiry == "false" {
+			continue
+		}
+		expire, err := commands.ParseDate(expiry)
+		if err != nil {
+			fmt.Printf("Error: invalid reflog expiry: %v\n", err)
+			return
+		}
+		*limit.into = expire
+	}
+
+	err := commands.ReflogExpire(".", expireFlags.Args(), opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// handleFsck checks the repository and exits with a non-zero status when it
+// finds corruption, so that it can run unattended.
+func handleFsck(args []string) {
+	fsckFlags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	unreachable := fsckFlags.Bool("unreachable", false, "Show all unreachable objects, not only dangling ones")
+	noDangling := fsckFlags.Bool("no-dangling", false, "Do not show dangling objects")
+	fsckFlags.Parse(args)
+
+	err := commands.Fsck(".", commands.FsckOptions{Unreachable: *unreachable, NoDangling: *noDangling})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleVerify runs verify-commit or verify-tag on each argument. Unlike
+// most commands it exits with a non-zero status when a signature does not
+// check out, so that scripts can rely on it.
+func handleVerify(name string, args []string, verify func(repoPath, name, allowedSigners string) error) {
+	verifyFlags := flag.NewFlagSet(name, flag.ExitOnError)
+	allowedSigners := verifyFlags.String("allowed-signers", "", "Allowed signers file (defaults to gpg.ssh.allowedSignersFile)")
+	verifyFlags.Parse(args)
+
+	if verifyFlags.NArg() == 0 {
+		fmt.Printf("Usage: %s [-allowed-signers <file>] <object>...\n", name)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, object := range verifyFlags.Args() {
+		if err := verify(".", object, *allowedSigners); err != nil {
+			fmt.Printf("Error: %s: %v\n", object, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,121 @@
+// Package merge combines divergent histories: it finds merge bases and
+// performs three-way merges of trees and file contents.
+package merge
+
+import (
+	"fmt"
+	"gopract/objects"
+	"sort"
+)
+
+// parents returns the parent hashes of a commit.
+func parents(repoPath, sha string) ([]string, error) {
+	obj, err := objects.ReadObject(repoPath, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", sha, err)
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", sha, obj.Type())
+	}
+	return commit.Parents, nil
+}
+
+// Ancestors returns every commit reachable from sha, including sha itself.
+func Ancestors(repoPath, sha string) (map[string]bool, error) {
+	seen := map[string]bool{sha: true}
+	queue := []string{sha}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		ps, err := parents(repoPath, current)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return seen, nil
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant by
+// following parent links. A commit is its own ancestor.
+func IsAncestor(repoPath, ancestor, descendant string) (bool, error) {
+	reachable, err := Ancestors(repoPath, descendant)
+	if err != nil {
+		return false, err
+	}
+	return reachable[ancestor], nil
+}
+
+// Bases returns the best common ancestors of two commits: the common
+// ancestors that are not themselves ancestors of another common ancestor.
+// The result is empty when the histories are unrelated.
+func Bases(repoPath, a, b string) ([]string, error) {
+	fromA, err := Ancestors(repoPath, a)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back from b, stopping at the first commits also reachable from a
+	var candidates []string
+	seen := map[string]bool{b: true}
+	queue := []string{b}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if fromA[current] {
+			candidates = append(candidates, current)
+			continue
+		}
+		ps, err := parents(repoPath, current)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	// Drop candidates that another candidate already descends from
+	var bases []string
+	for _, c := range candidates {
+		redundant := false
+		for _, other := range candidates {
+			if other == c {
+				continue
+			}
+			if reachable, err := IsAncestor(repoPath, c, other); err != nil {
+				return nil, err
+			} else if reachable {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			bases = append(bases, c)
+		}
+	}
+	sort.Strings(bases)
+	return bases, nil
+}
+
+// Base returns a single merge base of two commits, or an empty string when
+// they share no history. When there are several equally good bases, as
+// after criss-cross merges, the first in hash order is used. Unlike Git's
+// recursive strategy, the bases are not merged into a virtual one, so
+// changes already reconciled on both sides may conflict again.
+func Base(repoPath, a, b string) (string, error) {
+	bases, err := Bases(repoPath, a, b)
+	if err != nil || len(bases) == 0 {
+		return "", err
+	}
+	return bases[0], nil
+}
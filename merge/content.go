@@ -0,0 +1,190 @@
+package merge
+
+import (
+	"fmt"
+	"gopract/diff"
+	"strings"
+)
+
+// ConflictStyle selects how conflicting hunks are written out.
+type ConflictStyle string
+
+const (
+	StyleMerge ConflictStyle = "merge" // Ours and theirs only
+	StyleDiff3 ConflictStyle = "diff3" // Ours, the common base and theirs
+)
+
+// markerSize is the length of the <<<<<<<, |||||||, ======= and >>>>>>> markers.
+const markerSize = 7
+
+// ContentOptions configures a three-way content merge.
+type ContentOptions struct {
+	Style       ConflictStyle
+	Algorithm   diff.Algorithm
+	OursLabel   string // Shown after <<<<<<<
+	BaseLabel   string // Shown after ||||||| in diff3 style
+	TheirsLabel string // Shown after >>>>>>>
+}
+
+// ParseConflictStyle validates a conflict style name.
+func ParseConflictStyle(name string) (ConflictStyle, error) {
+	switch ConflictStyle(name) {
+	case "", StyleMerge:
+		return StyleMerge, nil
+	case StyleDiff3:
+		return StyleDiff3, nil
+	default:
+		return "", fmt.Errorf("unknown conflict style: %s", name)
+	}
+}
+
+// chunk is a region of the base together with what each side made of it.
+type chunk struct {
+	base, ours, theirs []string
+	stable             bool // Identical on all three sides
+}
+
+// Content merges the changes from base to ours and from base to theirs.
+// It returns the merged data and the number of conflicting hunks, which are
+// marked up in the result according to opts.Style.
+func Content(base, ours, theirs []byte, opts ContentOptions) ([]byte, int) {
+	baseLines := diff.SplitLines(base)
+	oursLines := diff.SplitLines(ours)
+	theirsLines := diff.SplitLines(theirs)
+
+	var out strings.Builder
+	conflicts := 0
+	for _, c := range chunks(baseLines, oursLines, theirsLines, opts.Algorithm) {
+		switch {
+		case c.stable:
+			writeLines(&out, c.base)
+		case equalLines(c.ours, c.base):
+			writeLines(&out, c.theirs)
+		case equalLines(c.theirs, c.base), equalLines(c.ours, c.theirs):
+			writeLines(&out, c.ours)
+		default:
+			conflicts++
+			writeConflict(&out, c, opts)
+		}
+	}
+	return []byte(out.String()), conflicts
+}
+
+// chunks splits the three versions into alternating stable regions, where
+// a base line is matched on both sides, and unstable regions between them.
+func chunks(base, ours, theirs []string, algo diff.Algorithm) []chunk {
+	toOurs := matches(base, ours, algo)
+	toTheirs := matches(base, theirs, algo)
+
+	var result []chunk
+	i, j, k := 0, 0, 0
+	for i < len(base) || j < len(ours) || k < len(theirs) {
+		// Collect a run of lines that line up on all three sides
+		start := i
+		for i < len(base) && toOurs[i] == j && toTheirs[i] == k {
+			i, j, k = i+1, j+1, k+1
+		}
+		if i > start {
+			result = append(result, chunk{base: base[start:i], stable: true})
+			continue
+		}
+
+		// Find the next base line matched on both sides
+		next := i
+		for next < len(base) && (toOurs[next] < j || toTheirs[next] < k) {
+			next++
+		}
+		endOurs, endTheirs := len(ours), len(theirs)
+		if next < len(base) {
+			endOurs, endTheirs = toOurs[next], toTheirs[next]
+		}
+		result = append(result, chunk{base: base[i:next], ours: ours[j:endOurs], theirs: theirs[k:endTheirs]})
+		i, j, k = next, endOurs, endTheirs
+	}
+	return result
+}
+
+// matches maps each line of a to the line of b it is paired with by the
+// diff, or -1 when it was deleted.
+func matches(a, b []string, algo diff.Algorithm) []int {
+	mapping := make([]int, len(a))
+	for i := range mapping {
+		mapping[i] = -1
+	}
+	for _, e := range diff.Lines(a, b, algo) {
+		if e.Op == diff.Equal {
+			mapping[e.OldLine] = e.NewLine
+		}
+	}
+	return mapping
+}
+
+// writeConflict writes one conflicting hunk between markers. In merge style
+// lines common to the start or end of both sides are kept outside the
+// markers, as Git does.
+func writeConflict(out *strings.Builder, c chunk, opts ContentOptions) {
+	ours, theirs := c.ours, c.theirs
+	var suffix []string
+	if opts.Style != StyleDiff3 {
+		prefix := 0
+		for prefix < len(ours) && prefix < len(theirs) && ours[prefix] == theirs[prefix] {
+			prefix++
+		}
+		writeLines(out, ours[:prefix])
+		ours, theirs = ours[prefix:], theirs[prefix:]
+
+		n := 0
+		for n < len(ours) && n < len(theirs) && ours[len(ours)-1-n] == theirs[len(theirs)-1-n] {
+			n++
+		}
+		suffix = ours[len(ours)-n:]
+		ours, theirs = ours[:len(ours)-n], theirs[:len(theirs)-n]
+	}
+
+	writeMarker(out, '<', opts.OursLabel)
+	writeSide(out, ours)
+	if opts.Style == StyleDiff3 {
+		writeMarker(out, '|', opts.BaseLabel)
+		writeSide(out, c.base)
+	}
+	writeMarker(out, '=', "")
+	writeSide(out, theirs)
+	writeMarker(out, '>', opts.TheirsLabel)
+	writeLines(out, suffix)
+}
+
+// writeMarker writes a conflict marker line with an optional label.
+func writeMarker(out *strings.Builder, marker byte, label string) {
+	out.WriteString(strings.Repeat(string(marker), markerSize))
+	if label != "" {
+		out.WriteString(" " + label)
+	}
+	out.WriteString("\n")
+}
+
+// writeSide writes the lines of one side of a conflict, making sure the
+// marker that follows starts on its own line.
+func writeSide(out *strings.Builder, lines []string) {
+	writeLines(out, lines)
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package merge
+
+import (
+	"gopract/diff"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	// Expected results are what `git merge-file -p -L ours -L base -L theirs`
+	// prints, with and without --diff3
+	tests := []struct {
+		name                 string
+		base, ours, theirs   string
+		wantMerge, wantDiff3 string
+		conflicts            int
+	}{
+		{
+			name:      "changes apart",
+			base:      "1\n2\n3\n4\n5\n6\n7\n",
+			ours:      "1\nA\n3\n4\n5\n6\n7\n",
+			theirs:    "1\n2\n3\n4\n5\n6\nB\n",
+			wantMerge: "1\nA\n3\n4\n5\n6\nB\n",
+			wantDiff3: "1\nA\n3\n4\n5\n6\nB\n",
+		},
+		{
+			name:      "same change on both sides",
+			base:      "a\nb\nc\n",
+			ours:      "a\nx\nc\n",
+			theirs:    "a\nx\nc\n",
+			wantMerge: "a\nx\nc\n",
+			wantDiff3: "a\nx\nc\n",
+		},
+		{
+			name:      "only ours changed",
+			base:      "a\nb\nc\n",
+			ours:      "a\nb\nc\nd\n",
+			theirs:    "a\nb\nc\n",
+			wantMerge: "a\nb\nc\nd\n",
+			wantDiff3: "a\nb\nc\nd\n",
+		},
+		{
+			name:      "conflict",
+			base:      "a\nb\nc\n",
+			ours:      "a\nx\nc\n",
+			theirs:    "a\ny\nc\n",
+			wantMerge: "a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\nc\n",
+			wantDiff3: "a\n<<<<<<< ours\nx\n||||||| base\nb\n=======\ny\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "conflict with common edges",
+			base:      "a\nb\nc\n",
+			ours:      "a\nk\nx\nl\nc\n",
+			theirs:    "a\nk\ny\nl\nc\n",
+			wantMerge: "a\nk\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\nl\nc\n",
+			wantDiff3: "a\n<<<<<<< ours\nk\nx\nl\n||||||| base\nb\n=======\nk\ny\nl\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "delete against modify",
+			base:      "a\nb\nc\n",
+			ours:      "a\nc\n",
+			theirs:    "a\nB\nc\n",
+			wantMerge: "a\n<<<<<<< ours\n=======\nB\n>>>>>>> theirs\nc\n",
+			wantDiff3: "a\n<<<<<<< ours\n||||||| base\nb\n=======\nB\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "adjacent changes",
+			base:      "1\n2\n3\n4\n",
+			ours:      "1\nX\n3\n4\n",
+			theirs:    "1\n2\nY\n4\n",
+			wantMerge: "1\n<<<<<<< ours\nX\n3\n=======\n2\nY\n>>>>>>> theirs\n4\n",
+			wantDiff3: "1\n<<<<<<< ours\nX\n3\n||||||| base\n2\n3\n=======\n2\nY\n>>>>>>> theirs\n4\n",
+			conflicts: 1,
+		},
+		{
+			name:      "both append",
+			base:      "a\n",
+			ours:      "a\nb\n",
+			theirs:    "a\nc\n",
+			wantMerge: "a\n<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\n",
+			wantDiff3: "a\n<<<<<<< ours\nb\n||||||| base\n=======\nc\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, style := range []ConflictStyle{StyleMerge, StyleDiff3} {
+			t.Run(tt.name+"/"+string(style), func(t *testing.T) {
+				want := tt.wantMerge
+				if style == StyleDiff3 {
+					want = tt.wantDiff3
+				}
+				opts := ContentOptions{Style: style, Algorithm: diff.Myers, OursLabel: "ours", BaseLabel: "base", TheirsLabel: "theirs"}
+				got, conflicts := Content([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs), opts)
+				if string(got) != want {
+					t.Errorf("merged content differs\ngot:\n%s\nwant:\n%s", got, want)
+				}
+				if conflicts != tt.conflicts {
+					t.Errorf("got %d conflicts, want %d", conflicts, tt.conflicts)
+				}
+			})
+		}
+	}
+}
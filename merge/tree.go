@@ -0,0 +1,211 @@
+package merge
+
+import (
+	"fmt"
+	"gopract/diff"
+	"gopract/objects"
+	"sort"
+)
+
+// Conflict describes a path the tree merge could not resolve. A nil side
+// means the path does not exist in that version.
+type Conflict struct {
+	Path   string
+	Base   *objects.TreeEntry
+	Ours   *objects.TreeEntry
+	Theirs *objects.TreeEntry
+	Reason string // "content", "add/add", "modify/delete", "delete/modify" or "mode"
+	Data   []byte // Contents to leave in the worktree, with conflict markers
+	Mode   string // Mode to give the worktree file
+}
+
+// Result is the outcome of a three-way tree merge.
+type Result struct {
+	Files     map[string]objects.TreeEntry // Cleanly merged entries by path
+	Conflicts []Conflict                   // Unresolved paths, sorted by path
+}
+
+// Trees merges the changes from baseTree to oursTree and from baseTree to
+// theirsTree. An empty baseTree stands for an empty tree. Merged blobs are
+// written to the object database.
+func Trees(repoPath, baseTree, oursTree, theirsTree string, opts ContentOptions) (*Result, error) {
+	base, err := flatten(repoPath, baseTree)
+	if err != nil {
+		return nil, err
+	}
+	ours, err := flatten(repoPath, oursTree)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := flatten(repoPath, theirsTree)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]objects.TreeEntry{base, ours, theirs} {
+		for p := range files {
+			paths[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	result := &Result{Files: make(map[string]objects.TreeEntry)}
+	for _, p := range sorted {
+		b, o, t := lookup(base, p), lookup(ours, p), lookup(theirs, p)
+
+		switch {
+		case sameEntry(o, t):
+			if o != nil {
+				result.Files[p] = *o
+			}
+			continue
+		case sameEntry(b, o):
+			if t != nil {
+				result.Files[p] = *t
+			}
+			continue
+		case sameEntry(b, t):
+			if o != nil {
+				result.Files[p] = *o
+			}
+			continue
+		}
+
+		conflict := Conflict{Path: p, Base: b, Ours: o, Theirs: t}
+		if o == nil || t == nil {
+			// One side deleted the file, the other changed it: keep the
+			// surviving version in the worktree
+			survivor := o
+			conflict.Reason = "delete/modify"
+			if t != nil {
+				survivor = t
+			} else {
+				conflict.Reason = "modify/delete"
+			}
+			data, err := readBlob(repoPath, survivor.Hash)
+			if err != nil {
+				return nil, err
+			}
+			conflict.Data, conflict.Mode = data, survivor.Mode
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+
+		if o.Mode == objects.ModeGitlink || t.Mode == objects.ModeGitlink {
+			conflict.Reason = "content"
+			conflict.Mode = o.Mode
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+
+		// Both sides have the file: merge modes and contents separately
+		mode, modeClean := mergeMode(b, o, t)
+		var baseData []byte
+		if b != nil {
+			if baseData, err = readBlob(repoPath, b.Hash); err != nil {
+				return nil, err
+			}
+		}
+		oursData, err := readBlob(repoPath, o.Hash)
+		if err != nil {
+			return nil, err
+		}
+		theirsData, err := readBlob(repoPath, t.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		var merged []byte
+		conflicts := 0
+		if o.Mode == objects.ModeSymlink || t.Mode == objects.ModeSymlink ||
+			diff.IsBinary(baseData) || diff.IsBinary(oursData) || diff.IsBinary(theirsData) {
+			// Symlinks and binary files cannot be merged line by line; keep ours
+			if o.Hash != t.Hash {
+				conflicts = 1
+				if o.Mode == objects.ModeSymlink || t.Mode == objects.ModeSymlink {
+					// Our contents only make sense with our type
+					mode = o.Mode
+				}
+			}
+			merged = oursData
+		} else {
+			merged, conflicts = Content(baseData, oursData, theirsData, opts)
+		}
+
+		if conflicts == 0 && modeClean {
+			hash, err := objects.WriteRawObject(repoPath, "blob", merged)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write merged blob for %s: %w", p, err)
+			}
+			result.Files[p] = objects.TreeEntry{Mode: mode, Hash: hash, Name: p}
+			continue
+		}
+
+		switch {
+		case conflicts > 0 && b == nil:
+			conflict.Reason = "add/add"
+		case conflicts > 0:
+			conflict.Reason = "content"
+		default:
+			conflict.Reason = "mode"
+		}
+		conflict.Data, conflict.Mode = merged, mode
+		result.Conflicts = append(result.Conflicts, conflict)
+	}
+	return result, nil
+}
+
+// flatten lists the files of a tree, or nothing for an empty tree hash.
+func flatten(repoPath, treeHash string) (map[string]objects.TreeEntry, error) {
+	if treeHash == "" {
+		return map[string]objects.TreeEntry{}, nil
+	}
+	return objects.FlattenTree(repoPath, treeHash)
+}
+
+func lookup(files map[string]objects.TreeEntry, p string) *objects.TreeEntry {
+	if entry, ok := files[p]; ok {
+		return &entry
+	}
+	return nil
+}
+
+func sameEntry(a, b *objects.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// mergeMode picks the file mode for a path both sides kept. The boolean is
+// false when both sides changed the mode differently.
+func mergeMode(b, o, t *objects.TreeEntry) (string, bool) {
+	switch {
+	case o.Mode == t.Mode:
+		return o.Mode, true
+	case b != nil && b.Mode == o.Mode:
+		return t.Mode, true
+	case b != nil && b.Mode == t.Mode:
+		return o.Mode, true
+	default:
+		return o.Mode, false
+	}
+}
+
+// readBlob loads the contents of a blob.
+func readBlob(repoPath, hash string) ([]byte, error) {
+	obj, err := objects.ReadObject(repoPath, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	blob, ok := obj.(*objects.Blob)
+	if !ok {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, obj.Type())
+	}
+	return blob.Data, nil
+}
@@ -11,8 +11,9 @@ func (b *Blob) Serialize() ([]byte, error) {
 }
 
 // Deserialize populates the blob with data from bytes.
-func (b *Blob) Deserialize(data []byte) {
+func (b *Blob) Deserialize(data []byte) error {
 	b.Data = data
+	return nil
 }
 
 // Type returns the type of the object ("blob").
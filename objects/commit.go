@@ -2,76 +2,176 @@ package objects
 
 import (
 	"bytes"
-	"fmt"
 	"strings"
 )
 
 // Commit represents a Git commit object.
 type Commit struct {
-	Tree    string   // SHA-1 hash of the tree object
-	Parents []string // SHA-1 hashes of parent commits (if any)
-	Author  string   // Author of the commit
-	Message string   // Commit message
+	Tree         string    // SHA-1 hash of the tree object
+	Parents      []string  // SHA-1 hashes of parent commits (if any)
+	Author       Signature // Who wrote the change, and when
+	Committer    Signature // Who recorded the commit, and when
+	ExtraHeaders []Header  // Other headers (encoding, mergetag, gpgsig, ...) in their original order
+	Message      string    // Commit message
+
+	headersOnly bool // Parsed from an object that ends after its headers, with no blank line
+}
+
+// Header is a commit or tag header that has no dedicated field. Multi-line values
+// are stored with plain "\n" separators; the space that starts each
+// continuation line in the object is added back when serializing.
+type Header struct {
+	Key   string
+	Value string
+
+	placed bool // Read from an object, so written back where it was found
+	after  int  // Number of headers with dedicated fields that came before it
+	bare   bool // The line had no space after the key
 }
 
 // Serialize converts the commit object into bytes for storage.
 func (c *Commit) Serialize() ([]byte, error) {
 	var buf bytes.Buffer
 
-	// Write tree hash
-	buf.WriteString(fmt.Sprintf("tree %s\n", c.Tree))
-
-	// Write parent hashes
+	// Tree and parent hashes, then author and committer information
+	known := []Header{{Key: "tree", Value: c.Tree}}
 	for _, parent := range c.Parents {
-		buf.WriteString(fmt.Sprintf("parent %s\n", parent))
+		known = append(known, Header{Key: "parent", Value: parent})
+	}
+	if !c.Author.IsZero() {
+		known = append(known, Header{Key: "author", Value: c.Author.String()})
+	}
+	if !c.Committer.IsZero() {
+		known = append(known, Header{Key: "committer", Value: c.Committer.String()})
 	}
 
-	// Write author information
-	buf.WriteString(fmt.Sprintf("author %s\n", c.Author))
+	// Any other headers go back exactly where they were read
+	writeHeaders(&buf, known, c.ExtraHeaders)
 
 	// Write the commit message
-	buf.WriteString("\n") // Separate metadata and message with a blank line
+	if !c.headersOnly || c.Message != "" {
+		buf.WriteString("\n") // Separate metadata and message with a blank line
+	}
 	buf.WriteString(c.Message)
 
 	return buf.Bytes(), nil
 }
 
 // Deserialize populates the commit object from bytes.
-func (c *Commit) Deserialize(data []byte) {
-	// Split metadata and message
-	parts := bytes.SplitN(data, []byte("\n\n"), 2)
-	metadata := string(parts[0])
-	message := ""
-	if len(parts) > 1 {
-		message = string(parts[1])
-	}
-
-	// Parse metadata
-	lines := strings.Split(metadata, "\n")
-	for _, line := range lines {
-		// Extract key and value
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key, value := parts[0], parts[1]
+func (c *Commit) Deserialize(data []byte) error {
+	headers, message, separated := parseHeaders(data)
+	c.headersOnly = !separated
 
-		// Handle different keys
-		switch key {
+	known := 0
+	for _, h := range headers {
+		switch h.Key {
 		case "tree":
-			c.Tree = value
+			c.Tree = h.Value
 		case "parent":
-			c.Parents = append(c.Parents, value)
+			c.Parents = append(c.Parents, h.Value)
 		case "author":
-			c.Author = value
+			c.Author, _ = ParseSignature(h.Value)
+		case "committer":
+			c.Committer, _ = ParseSignature(h.Value)
+		default:
+			h.placed, h.after = true, known
+			c.ExtraHeaders = append(c.ExtraHeaders, h)
+			continue
 		}
+		known++
 	}
 
 	// Assign the message
 	c.Message = message
+	return nil
+}
+
+// Header returns the value of the first extra header with the given key.
+func (c *Commit) Header(key string) (string, bool) {
+	for _, h := range c.ExtraHeaders {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return "", false
+}
+
+// SignedPayload splits a signed commit into the bytes that were signed (the
+// commit without its "gpgsig" header) and the armored signature. The
+// signature is empty for unsigned commits.
+func (c *Commit) SignedPayload() ([]byte, string, error) {
+	unsigned := *c
+	unsigned.ExtraHeaders = nil
+	var signature string
+	for _, h := range c.ExtraHeaders {
+		if h.Key == "gpgsig" && signature == "" {
+			signature = h.Value + "\n"
+			continue
+		}
+		if h.Key != "gpgsig" && h.Key != "gpgsig-sha256" {
+			unsigned.ExtraHeaders = append(unsigned.ExtraHeaders, h)
+		}
+	}
+	payload, err := unsigned.Serialize()
+	return payload, signature, err
 }
 
 // Type returns the type of the object ("commit").
 func (c *Commit) Type() string {
 	return "commit"
 }
+
+// writeHeaders writes the headers that have dedicated fields with the
+// extra headers in between where they were read. Extra headers that were
+// not read from an object follow all the others.
+func writeHeaders(buf *bytes.Buffer, known, extras []Header) {
+	next := 0
+	for i, h := range known {
+		for next < len(extras) && extras[next].placed && extras[next].after <= i {
+			writeHeader(buf, extras[next])
+			next++
+		}
+		writeHeader(buf, h)
+	}
+	for _, h := range extras[next:] {
+		writeHeader(buf, h)
+	}
+}
+
+// writeHeader writes one header line, continuing multi-line values on lines
+// that start with a space.
+func writeHeader(buf *bytes.Buffer, h Header) {
+	buf.WriteString(h.Key)
+	if !h.bare {
+		buf.WriteString(" ")
+	}
+	buf.WriteString(strings.ReplaceAll(h.Value, "\n", "\n "))
+	buf.WriteString("\n")
+}
+
+// parseHeaders splits an object into its headers and the message that
+// follows the first empty line, reporting whether there was such a line.
+// Lines starting with a space continue the previous header.
+func parseHeaders(data []byte) ([]Header, string, bool) {
+	var headers []Header
+	rest := string(data)
+	for rest != "" {
+		line, remaining, found := strings.Cut(rest, "\n")
+		if !found {
+			remaining = ""
+		}
+		if line == "" && found {
+			return headers, remaining, true
+		}
+		rest = remaining
+
+		if strings.HasPrefix(line, " ") && len(headers) > 0 {
+			last := &headers[len(headers)-1]
+			last.Value += "\n" + line[1:]
+			continue
+		}
+		key, value, spaced := strings.Cut(line, " ")
+		headers = append(headers, Header{Key: key, Value: value, bare: !spaced})
+	}
+	return headers, "", false
+}
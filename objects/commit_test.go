@@ -0,0 +1,95 @@
+package objects
+
+import "testing"
+
+func TestCommitRoundTrip(t *testing.T) {
+	tree := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+	parent := "parent 0123456789abcdef0123456789abcdef01234567\n"
+	author := "author A U Thor <author@example.com> 1700000000 +0100\n"
+	committer := "committer C O Mitter <committer@example.com> 1700000100 -0230\n"
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"plain", tree + parent + author + committer + "\nmessage\n"},
+		{"extra header at the end", tree + author + committer + "encoding ISO-8859-1\n\nmessage\n"},
+		{"extra header between known ones", tree + "foo bar\n" + author + committer + "\nmessage\n"},
+		{"extra header first", "foo bar\n" + tree + author + committer + "\nmessage\n"},
+		{"extra headers everywhere", tree + "a 1\n" + parent + "b 2\n" + author + "c 3\n" + committer + "d 4\n\nmessage\n"},
+		{"header without a value", tree + author + committer + "novalue\n\nmessage\n"},
+		{"header with an empty value", tree + author + committer + "empty \n\nmessage\n"},
+		{"multi-line header without a first value", tree + author + committer + "multi\n line one\n line two\n\nmessage\n"},
+		{"no message", tree + author + committer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := &Commit{}
+			commit.Deserialize([]byte(tt.data))
+			got, err := commit.Serialize()
+			if err != nil {
+				t.Fatalf("Serialize: %v", err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("round trip changed the commit:\ngot:\n%s\nwant:\n%s", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestCommitNewHeadersFollowReadOnes(t *testing.T) {
+	commit := &Commit{}
+	commit.Deserialize([]byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"foo bar\n" +
+		"author A <a@example.com> 1700000000 +0000\n" +
+		"committer A <a@example.com> 1700000000 +0000\n" +
+		"\nmessage\n"))
+	commit.ExtraHeaders = append(commit.ExtraHeaders, Header{Key: "gpgsig", Value: "sig"})
+
+	got, err := commit.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	want := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"foo bar\n" +
+		"author A <a@example.com> 1700000000 +0000\n" +
+		"committer A <a@example.com> 1700000000 +0000\n" +
+		"gpgsig sig\n" +
+		"\nmessage\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTagRoundTrip(t *testing.T) {
+	object := "object 0123456789abcdef0123456789abcdef01234567\n"
+	typ := "type commit\n"
+	name := "tag v1.0\n"
+	tagger := "tagger T Agger <tagger@example.com> 1700000000 +0000\n"
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"plain", object + typ + name + tagger + "\nmessage\n"},
+		{"no tagger", object + typ + name + "\nmessage\n"},
+		{"extra header between known ones", object + "foo bar\n" + typ + name + tagger + "\nmessage\n"},
+		{"extra header at the end", object + typ + name + tagger + "foo bar\n\nmessage\n"},
+		{"header without a value", object + typ + name + "novalue\n" + tagger + "\nmessage\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := &Tag{}
+			tag.Deserialize([]byte(tt.data))
+			got, err := tag.Serialize()
+			if err != nil {
+				t.Fatalf("Serialize: %v", err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("round trip changed the tag:\ngot:\n%s\nwant:\n%s", got, tt.data)
+			}
+		})
+	}
+}
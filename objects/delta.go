@@ -0,0 +1,192 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errInvalidDelta = errors.New("invalid delta")
+
+// ApplyDelta rebuilds a target object from its base and a Git delta.
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != uint64(len(base)) {
+		return nil, fmt.Errorf("%w: base size %d does not match expected %d", errInvalidDelta, len(base), srcSize)
+	}
+
+	dstSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sizes are untrusted, so the result is only preallocated as far as
+	// the base and delta could plausibly produce
+	out := make([]byte, 0, min(dstSize, uint64(len(base)+len(delta))))
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case cmd&0x80 != 0:
+			// Copy a range from the base object
+			var offset, size uint64
+			for i := uint(0); i < 4; i++ {
+				if cmd&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errInvalidDelta
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if cmd&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errInvalidDelta
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("%w: copy out of range", errInvalidDelta)
+			}
+			out = append(out, base[offset:offset+size]...)
+		case cmd != 0:
+			// Insert literal bytes carried in the delta
+			if int(cmd) > len(delta) {
+				return nil, fmt.Errorf("%w: insert out of range", errInvalidDelta)
+			}
+			out = append(out, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, fmt.Errorf("%w: reserved opcode", errInvalidDelta)
+		}
+	}
+
+	if uint64(len(out)) != dstSize {
+		return nil, fmt.Errorf("%w: result size %d does not match expected %d", errInvalidDelta, len(out), dstSize)
+	}
+	return out, nil
+}
+
+// readDeltaSize decodes one of the little-endian varints at the start of a delta.
+func readDeltaSize(delta []byte) (uint64, []byte, error) {
+	var size uint64
+	for shift := uint(0); ; shift += 7 {
+		if len(delta) == 0 || shift > 63 {
+			return 0, nil, errInvalidDelta
+		}
+		b := delta[0]
+		delta = delta[1:]
+		size |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return size, delta, nil
+		}
+	}
+}
+
+// deltaBlockSize is the granularity at which the base is indexed when
+// searching for copyable ranges.
+const deltaBlockSize = 16
+
+// maxCopySize is the largest range emitted by a single copy instruction.
+const maxCopySize = 0x10000
+
+// CreateDelta encodes target as a Git delta against base.
+func CreateDelta(base, target []byte) []byte {
+	delta := appendDeltaSize(nil, uint64(len(base)))
+	delta = appendDeltaSize(delta, uint64(len(target)))
+
+	// Index every aligned block of the base by its contents.
+	blocks := make(map[string]int, len(base)/deltaBlockSize)
+	for i := 0; i+deltaBlockSize <= len(base); i += deltaBlockSize {
+		key := string(base[i : i+deltaBlockSize])
+		if _, ok := blocks[key]; !ok {
+			blocks[key] = i
+		}
+	}
+
+	var pending []byte
+	for i := 0; i < len(target); {
+		if i+deltaBlockSize <= len(target) {
+			if offset, ok := blocks[string(target[i:i+deltaBlockSize])]; ok {
+				// Extend the match as far as both sides agree.
+				length := deltaBlockSize
+				for offset+length < len(base) && i+length < len(target) && base[offset+length] == target[i+length] {
+					length++
+				}
+				delta = appendInsert(delta, pending)
+				pending = pending[:0]
+				delta = appendCopy(delta, offset, length)
+				i += length
+				continue
+			}
+		}
+		pending = append(pending, target[i])
+		i++
+	}
+	return appendInsert(delta, pending)
+}
+
+// appendDeltaSize encodes a size header varint.
+func appendDeltaSize(delta []byte, size uint64) []byte {
+	for size >= 0x80 {
+		delta = append(delta, byte(size)|0x80)
+		size >>= 7
+	}
+	return append(delta, byte(size))
+}
+
+// appendInsert emits literal data in chunks of at most 127 bytes.
+func appendInsert(delta, data []byte) []byte {
+	for len(data) > 0 {
+		n := len(data)
+		if n > 0x7f {
+			n = 0x7f
+		}
+		delta = append(delta, byte(n))
+		delta = append(delta, data[:n]...)
+		data = data[n:]
+	}
+	return delta
+}
+
+// appendCopy emits copy instructions for base[offset:offset+length].
+func appendCopy(delta []byte, offset, length int) []byte {
+	for length > 0 {
+		size := length
+		if size > maxCopySize {
+			size = maxCopySize
+		}
+
+		cmd := byte(0x80)
+		var args []byte
+		for i := uint(0); i < 4; i++ {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				cmd |= 1 << i
+				args = append(args, b)
+			}
+		}
+		if size != maxCopySize {
+			for i := uint(0); i < 3; i++ {
+				if b := byte(size >> (8 * i)); b != 0 {
+					cmd |= 0x10 << i
+					args = append(args, b)
+				}
+			}
+		}
+		delta = append(delta, cmd)
+		delta = append(delta, args...)
+
+		offset += size
+		length -= size
+	}
+	return delta
+}
@@ -0,0 +1,101 @@
+package objects
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	random := func(seed int64, n int) []byte {
+		data := make([]byte, n)
+		rand.New(rand.NewSource(seed)).Read(data)
+		return data
+	}
+	large := random(1, 3*maxCopySize)
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 100)
+
+	tests := []struct {
+		name         string
+		base, target []byte
+		small        bool // The target mostly copies the base, so the delta must be much smaller
+	}{
+		{"both empty", nil, nil, false},
+		{"empty base", nil, []byte("hello"), false},
+		{"empty target", []byte("hello"), nil, false},
+		{"identical", text, text, true},
+		{"appended", text, append(append([]byte{}, text...), "and then some\n"...), true},
+		{"prefixed", text, append([]byte("a new first line\n"), text...), true},
+		{"middle changed", text, bytes.Replace(text, []byte("lazy"), []byte("sleepy"), 50), false},
+		{"long insert", []byte("short"), random(2, 1000), false},
+		{"unrelated", random(3, 500), random(4, 500), false},
+		{"copy longer than one instruction", large, append(append([]byte{}, large...), 'x'), true},
+		{"reordered", large, append(append([]byte{}, large[2*maxCopySize:]...), large[:2*maxCopySize]...), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := CreateDelta(tt.base, tt.target)
+			got, err := ApplyDelta(tt.base, delta)
+			if err != nil {
+				t.Fatalf("ApplyDelta: %v", err)
+			}
+			if !bytes.Equal(got, tt.target) {
+				t.Fatalf("round trip gave %d bytes, want %d", len(got), len(tt.target))
+			}
+			if tt.small && len(delta) > len(tt.target)/10 {
+				t.Errorf("delta of %d bytes for a %d byte target that mostly copies its base", len(delta), len(tt.target))
+			}
+		})
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+
+	tests := []struct {
+		name  string
+		delta []byte
+		want  string
+		err   bool
+	}{
+		{
+			// Copy "hello", insert " there"
+			name:  "copy and insert",
+			delta: []byte{11, 11, 0x90, 5, 6, ' ', 't', 'h', 'e', 'r', 'e'},
+			want:  "hello there",
+		},
+		{
+			// Copy "world" from offset 6
+			name:  "copy with offset",
+			delta: []byte{11, 5, 0x91, 6, 5},
+			want:  "world",
+		},
+		{name: "wrong base size", delta: []byte{10, 5, 0x90, 5}, err: true},
+		{name: "wrong result size", delta: []byte{11, 6, 0x90, 5}, err: true},
+		{name: "copy out of range", delta: []byte{11, 5, 0x91, 8, 5}, err: true},
+		{name: "insert past the end", delta: []byte{11, 5, 5, 'a'}, err: true},
+		{name: "reserved opcode", delta: []byte{11, 0, 0}, err: true},
+		{name: "truncated size", delta: []byte{0x8b}, err: true},
+		{name: "huge result size", delta: []byte{11, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x90, 5}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyDelta(base, tt.delta)
+			if tt.err {
+				if !errors.Is(err, errInvalidDelta) {
+					t.Fatalf("got %q, %v; want an invalid delta error", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyDelta: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package objects
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Link is a reference from one object to another, as followed by fsck and
+// reachability walks.
+type Link struct {
+	Hash string // Object referred to
+	Type string // Type the referring object expects it to have
+}
+
+// HashRaw returns the name of an object with the given type and payload.
+func HashRaw(objType string, data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", objType, len(data))
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// VerifyLooseObject reads a loose object and checks that its content still
+// hashes to its file name.
+func VerifyLooseObject(repoPath, sha string) (string, []byte, error) {
+	objType, data, err := readLooseObject(filepath.Join(repoPath, ".git", "objects", sha[:2], sha[2:]))
+	if err != nil {
+		return "", nil, err
+	}
+	if actual := HashRaw(objType, data); actual != sha {
+		return "", nil, fmt.Errorf("hash mismatch: content hashes to %s", actual)
+	}
+	return objType, data, nil
+}
+
+// VerifyChecksum checks the SHA-1 trailer that ends every packfile.
+func (p *Packfile) VerifyChecksum() error {
+	file, err := os.Open(p.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open packfile: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat packfile: %w", err)
+	}
+	if info.Size() < 12+20 {
+		return errors.New("packfile is truncated")
+	}
+
+	h := sha1.New()
+	if _, err := io.CopyN(h, file, info.Size()-20); err != nil {
+		return fmt.Errorf("failed to read packfile: %w", err)
+	}
+	trailer := make([]byte, 20)
+	if _, err := io.ReadFull(file, trailer); err != nil {
+		return fmt.Errorf("failed to read packfile checksum: %w", err)
+	}
+	if !bytes.Equal(h.Sum(nil), trailer) {
+		return errors.New("packfile checksum mismatch")
+	}
+	return nil
+}
+
+// CheckObject validates the syntax of an object's payload and returns the
+// objects it refers to. Submodule commits in trees are not links, since they
+// live in another repository.
+func CheckObject(objType string, data []byte) ([]Link, error) {
+	switch objType {
+	case "blob":
+		return nil, nil
+	case "tree":
+		return checkTree(data)
+	case "commit":
+		return checkCommit(data)
+	case "tag":
+		return checkTag(data)
+	default:
+		return nil, fmt.Errorf("unknown object type %q", objType)
+	}
+}
+
+// checkTree validates tree entries: well-formed modes and names, and the
+// order Git sorts them in.
+func checkTree(data []byte) ([]Link, error) {
+	var links []Link
+	var previous *TreeEntry
+	for len(data) > 0 {
+		nullIdx := bytes.IndexByte(data, 0)
+		if nullIdx < 0 || len(data) < nullIdx+21 {
+			return nil, errors.New("truncated tree entry")
+		}
+		mode, name, ok := strings.Cut(string(data[:nullIdx]), " ")
+		if !ok {
+			return nil, errors.New("tree entry has no mode")
+		}
+		entry := TreeEntry{Mode: mode, Name: name, Hash: encodeHex(data[nullIdx+1 : nullIdx+21])}
+		data = data[nullIdx+21:]
+
+		// Old versions of Git wrote "100664" and zero-padded "040000"; both
+		// are still accepted
+		switch mode {
+		case ModeFile, "100755", "120000", "100664":
+			links = append(links, Link{Hash: entry.Hash, Type: "blob"})
+		case ModeTree, "0" + ModeTree:
+			links = append(links, Link{Hash: entry.Hash, Type: "tree"})
+		case ModeGitlink:
+		default:
+			return nil, fmt.Errorf("bad file mode %s for %q", mode, name)
+		}
+		if err := CheckEntryName(name); err != nil {
+			return nil, err
+		}
+
+		if previous != nil {
+			if previous.Name == entry.Name {
+				return nil, fmt.Errorf("duplicate entry %q", name)
+			}
+			if entrySortKey(*previous) > entrySortKey(entry) {
+				return nil, fmt.Errorf("entries not sorted: %q comes after %q", name, previous.Name)
+			}
+		}
+		previous = &entry
+	}
+	return links, nil
+}
+
+// checkCommit validates the header lines Git requires in a commit: a tree,
+// any number of parents, an author and a committer.
+func checkCommit(data []byte) ([]Link, error) {
+	headers, _, err := checkHeaderBlock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []Link
+	expect := func(key string) (string, error) {
+		if len(headers) == 0 || headers[0].Key != key {
+			return "", fmt.Errorf("missing %s line", key)
+		}
+		value := headers[0].Value
+		headers = headers[1:]
+		return value, nil
+	}
+
+	tree, err := expect("tree")
+	if err != nil {
+		return nil, err
+	}
+	if !isObjectName(tree) {
+		return nil, fmt.Errorf("invalid tree %q", tree)
+	}
+	links = append(links, Link{Hash: tree, Type: "tree"})
+
+	for len(headers) > 0 && headers[0].Key == "parent" {
+		parent := headers[0].Value
+		if !isObjectName(parent) {
+			return nil, fmt.Errorf("invalid parent %q", parent)
+		}
+		links = append(links, Link{Hash: parent, Type: "commit"})
+		headers = headers[1:]
+	}
+
+	for _, key := range []string{"author", "committer"} {
+		value, err := expect(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSignature(value); err != nil {
+			return nil, fmt.Errorf("bad %s: %w", key, err)
+		}
+	}
+	return links, nil
+}
+
+// checkTag validates the object, type and tag lines of a tag, and its
+// tagger when present.
+func checkTag(data []byte) ([]Link, error) {
+	headers, _, err := checkHeaderBlock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for i, key := range []string{"object", "type", "tag"} {
+		if len(headers) <= i || headers[i].Key != key {
+			return nil, fmt.Errorf("missing %s line", key)
+		}
+		values[key] = headers[i].Value
+	}
+	if !isObjectName(values["object"]) {
+		return nil, fmt.Errorf("invalid object %q", values["object"])
+	}
+	if _, err := NewObject(values["type"]); err != nil {
+		return nil, fmt.Errorf("invalid type %q", values["type"])
+	}
+	if values["tag"] == "" {
+		return nil, errors.New("empty tag name")
+	}
+	if len(headers) > 3 && headers[3].Key == "tagger" {
+		if err := checkSignature(headers[3].Value); err != nil {
+			return nil, fmt.Errorf("bad tagger: %w", err)
+		}
+	}
+	return []Link{{Hash: values["object"], Type: values["type"]}}, nil
+}
+
+// checkHeaderBlock splits a commit or tag into headers and message, failing
+// when the headers contain a NUL byte or are not terminated.
+func checkHeaderBlock(data []byte) ([]Header, string, error) {
+	end := bytes.Index(data, []byte("\n\n"))
+	if end < 0 {
+		end = len(data)
+	}
+	if bytes.IndexByte(data[:end], 0) >= 0 {
+		return nil, "", errors.New("NUL byte in header")
+	}
+	if !bytes.Contains(data, []byte("\n\n")) && !bytes.HasSuffix(data, []byte("\n")) {
+		return nil, "", errors.New("unterminated header")
+	}
+	headers, message, _ := parseHeaders(data)
+	return headers, message, nil
+}
+
+// checkSignature validates a "Name <email> <unix seconds> <+hhmm>" value
+// strictly, without the leniency of ParseSignature.
+func checkSignature(value string) error {
+	open := strings.Index(value, "<")
+	closing := strings.Index(value, ">")
+	if open < 0 || closing < open {
+		return errors.New("missing email")
+	}
+	if strings.ContainsAny(value[open+1:], "<") || strings.Count(value, ">") != 1 {
+		return errors.New("bad email")
+	}
+	if open == 0 || value[open-1] != ' ' {
+		return errors.New("missing space before email")
+	}
+
+	rest := value[closing+1:]
+	if !strings.HasPrefix(rest, " ") {
+		return errors.New("missing space before date")
+	}
+	fields := strings.Split(rest[1:], " ")
+	if len(fields) != 2 {
+		return errors.New("bad date")
+	}
+	if _, err := strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return errors.New("bad date")
+	}
+	if _, ok := ParseTimezone(fields[1]); !ok {
+		return errors.New("bad time zone")
+	}
+	return nil
+}
+
+// isObjectName reports whether s is a full lowercase hex object name.
+func isObjectName(s string) bool {
+	return len(s) == 40 && isHex(s)
+}
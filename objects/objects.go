@@ -8,37 +8,155 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// GitObject is the interface for all Git object types (e.g., blob, tree, commit).
+// GitObject is the interface for all Git object types (e.g., blob, tree, commit, tag).
 type GitObject interface {
-	Type() string               // Returns the object type (e.g., "blob")
-	Serialize() ([]byte, error) // Converts the object into bytes for storage
-	Deserialize(data []byte)    // Populates the object from bytes
+	Type() string                  // Returns the object type (e.g., "blob")
+	Serialize() ([]byte, error)    // Converts the object into bytes for storage
+	Deserialize(data []byte) error // Populates the object from bytes
 }
 
 // ReadObject reads a Git object from the `.git/objects` directory using its SHA hash.
-// ReadObject reads a Git object from the `.git/objects` directory using its SHA hash.
+// Loose objects are preferred; objects that only exist inside a packfile are
+// read from `.git/objects/pack`.
 func ReadObject(repoPath, sha string) (GitObject, error) {
-	// Construct the path to the object file
+	objType, objData, err := ReadRawObject(repoPath, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and return the appropriate GitObject
+	obj, err := NewObject(objType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := obj.Deserialize(objData); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", objType, err)
+	}
+	return obj, nil
+}
+
+// NewObject returns an empty GitObject of the given type.
+func NewObject(objType string) (GitObject, error) {
+	switch objType {
+	case "blob":
+		return &Blob{}, nil
+	case "tree":
+		return &Tree{}, nil
+	case "commit":
+		return &Commit{}, nil
+	case "tag":
+		return &Tag{}, nil
+	default:
+		return nil, fmt.Errorf("unknown object type: %s", objType)
+	}
+}
+
+// ReadRawObject returns the type and undecoded payload of an object, looking
+// first for a loose object and then in every packfile of the repository.
+func ReadRawObject(repoPath, sha string) (string, []byte, error) {
+	// Short or malformed names must be resolved first (see package revparse)
+	if len(sha) != 40 || !isHex(sha) {
+		return "", nil, fmt.Errorf("invalid object name %q: expected a full 40-character SHA-1", sha)
+	}
+
 	objPath := filepath.Join(repoPath, ".git", "objects", sha[:2], sha[2:])
+	if _, err := os.Stat(objPath); err == nil {
+		return readLooseObject(objPath)
+	}
+
+	objType, objData, err := readPackedObject(repoPath, sha)
+	if err != nil {
+		return "", nil, err
+	}
+	return objType, objData, nil
+}
+
+// FindObjects returns the names of all objects, loose or packed, that start
+// with the given lowercase hex prefix, sorted and without duplicates.
+func FindObjects(repoPath, prefix string) ([]string, error) {
+	if len(prefix) < 2 || !isHex(prefix) {
+		return nil, fmt.Errorf("invalid object name prefix %q", prefix)
+	}
+	found := make(map[string]bool)
+
+	fanout := filepath.Join(repoPath, ".git", "objects", prefix[:2])
+	files, err := os.ReadDir(fanout)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read object directory %s: %w", prefix[:2], err)
+	}
+	for _, file := range files {
+		if name := prefix[:2] + file.Name(); len(name) == 40 && isHex(name) && strings.HasPrefix(name, prefix) {
+			found[name] = true
+		}
+	}
+
+	packs, err := OpenPackfiles(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		idx := pack.Index
+		first := sort.Search(idx.Count(), func(i int) bool { return idx.Hash(i) >= prefix })
+		for i := first; i < idx.Count() && strings.HasPrefix(idx.Hash(i), prefix); i++ {
+			found[idx.Hash(i)] = true
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// HasObject reports whether an object exists, loose or packed, without
+// reading it.
+func HasObject(repoPath, sha string) bool {
+	if len(sha) != 40 {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, ".git", "objects", sha[:2], sha[2:])); err == nil {
+		return true
+	}
+	packs, err := OpenPackfiles(repoPath)
+	if err != nil {
+		return false
+	}
+	for _, pack := range packs {
+		if _, ok := pack.Index.Find(sha); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readLooseObject decompresses a loose object file and splits off its header.
+func readLooseObject(objPath string) (string, []byte, error) {
 	file, err := os.Open(objPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open object file: %w", err)
+		return "", nil, fmt.Errorf("failed to open object file: %w", err)
 	}
 	defer file.Close()
 
 	// Decompress the file
 	zr, err := zlib.NewReader(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decompress object: %w", err)
+		return "", nil, fmt.Errorf("failed to decompress object: %w", err)
 	}
 	defer zr.Close()
 
 	// Read decompressed data
 	var buf bytes.Buffer
 	if _, err := io.Copy(&buf, zr); err != nil {
-		return nil, fmt.Errorf("failed to read decompressed data: %w", err)
+		return "", nil, fmt.Errorf("failed to read decompressed data: %w", err)
 	}
 	raw := buf.Bytes()
 
@@ -47,77 +165,68 @@ func ReadObject(repoPath, sha string) (GitObject, error) {
 	nullIdx := bytes.IndexByte(raw, '\x00')
 
 	if spaceIdx < 0 || nullIdx < 0 || nullIdx <= spaceIdx {
-		return nil, fmt.Errorf("invalid object header")
+		return "", nil, fmt.Errorf("invalid object header")
 	}
 
-	objType := string(raw[:spaceIdx])
-	objData := raw[nullIdx+1:]
-
-	// Create and return the appropriate GitObject
-	var obj GitObject
-	switch objType {
-	case "blob":
-		obj = &Blob{}
-	case "tree":
-		obj = &Tree{}
-	case "commit":
-		obj = &Commit{}
-	default:
-		return nil, fmt.Errorf("unknown object type: %s", objType)
+	// The declared size must match, or the object is truncated or padded
+	size, err := strconv.Atoi(string(raw[spaceIdx+1 : nullIdx]))
+	if err != nil || size != len(raw)-nullIdx-1 {
+		return "", nil, fmt.Errorf("object size does not match its header")
 	}
 
-	obj.Deserialize(objData)
-	return obj, nil
+	return string(raw[:spaceIdx]), raw[nullIdx+1:], nil
 }
 
-func WriteObject(obj GitObject, repoPath string) (string, error) {
-	// Serialize the object data
+// Hash computes the SHA-1 of an object without writing it to the database.
+func Hash(obj GitObject) (string, error) {
 	data, err := obj.Serialize()
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to serialize object: %v\n", err)
 		return "", fmt.Errorf("failed to serialize object: %w", err)
 	}
 
-	// Add the header and prepare the object data
 	header := fmt.Sprintf("%s %d\x00", obj.Type(), len(data))
-	storeData := append([]byte(header), data...)
+	return fmt.Sprintf("%x", sha1.Sum(append([]byte(header), data...))), nil
+}
+
+// zlibWriters are reused between objects: setting up a compressor costs far
+// more than compressing a typical source file.
+var zlibWriters = sync.Pool{New: func() any { return zlib.NewWriter(nil) }}
 
-	// Compute the SHA-1 hash
-	sha := fmt.Sprintf("%x", sha1.Sum(storeData))
-	fmt.Printf("DEBUG: Computed SHA-1: %s\n", sha)
+// WriteObject serializes an object, stores it as a loose object and returns
+// its hash.
+func WriteObject(obj GitObject, repoPath string) (string, error) {
+	data, err := obj.Serialize()
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize object: %w", err)
+	}
+	return WriteRawObject(repoPath, obj.Type(), data)
+}
 
-	// Determine the object path
+// WriteRawObject stores an already serialized payload as a loose object and
+// returns its hash. Existing objects are left untouched, and new ones are
+// written to a temporary file and renamed into place read-only, as Git does.
+func WriteRawObject(repoPath, objType string, data []byte) (string, error) {
+	sha := HashRaw(objType, data)
 	objDir := filepath.Join(repoPath, ".git", "objects", sha[:2])
 	objPath := filepath.Join(objDir, sha[2:])
-	fmt.Printf("DEBUG: Object directory: %s\n", objDir)
-	fmt.Printf("DEBUG: Object path: %s\n", objPath)
-
-	// Ensure the directory exists
+	if _, err := os.Stat(objPath); err == nil {
+		return sha, nil
+	}
 	if err := os.MkdirAll(objDir, 0755); err != nil {
-		fmt.Printf("DEBUG: Failed to create directory %s: %v\n", objDir, err)
 		return "", fmt.Errorf("failed to create object directory: %w", err)
 	}
-	fmt.Printf("DEBUG: Directory created: %s\n", objDir)
-
-	// Write the compressed object to the file
-	file, err := os.Create(objPath)
-	if err != nil {
-		fmt.Printf("DEBUG: Failed to create object file %s: %v\n", objPath, err)
-		return "", fmt.Errorf("failed to create object file: %w", err)
-	}
-	defer file.Close()
-	fmt.Printf("DEBUG: File created: %s\n", objPath)
 
-	zw := zlib.NewWriter(file)
-	if _, err := zw.Write(storeData); err != nil {
-		fmt.Printf("DEBUG: Failed to write compressed data: %v\n", err)
-		return "", fmt.Errorf("failed to write compressed data: %w", err)
-	}
+	var buf bytes.Buffer
+	zw := zlibWriters.Get().(*zlib.Writer)
+	defer zlibWriters.Put(zw)
+	zw.Reset(&buf)
+	fmt.Fprintf(zw, "%s %d\x00", objType, len(data))
+	zw.Write(data)
 	if err := zw.Close(); err != nil {
-		fmt.Printf("DEBUG: Failed to close zlib writer: %v\n", err)
-		return "", fmt.Errorf("failed to close zlib writer: %w", err)
+		return "", fmt.Errorf("failed to compress object: %w", err)
+	}
+	if err := writeFileAtomic(objPath, buf.Bytes(), 0444); err != nil {
+		return "", fmt.Errorf("failed to write object file: %w", err)
 	}
-	fmt.Printf("DEBUG: Object successfully written: %s\n", objPath)
-
 	return sha, nil
 }
@@ -0,0 +1,407 @@
+package objects
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object type numbers used inside packfiles.
+const (
+	packObjCommit   = 1
+	packObjTree     = 2
+	packObjBlob     = 3
+	packObjTag      = 4
+	packObjOfsDelta = 6
+	packObjRefDelta = 7
+)
+
+// maxDeltaChain guards against corrupt packs whose deltas refer to each other.
+const maxDeltaChain = 10000
+
+// maxDeflateRatio is the most deflate can expand compressed data by; an entry
+// claiming more than this times the bytes left in the pack is corrupt.
+const maxDeflateRatio = 1032
+
+// ErrObjectNotFound is returned when an object exists neither loose nor in a pack.
+var ErrObjectNotFound = errors.New("object not found")
+
+// PackIndex is a parsed version 2 `.idx` file.
+type PackIndex struct {
+	Fanout  [256]uint32 // Cumulative object counts by first SHA byte
+	Hashes  []byte      // Sorted 20-byte object names, back to back
+	CRCs    []uint32    // CRC32 of each packed entry
+	Offsets []uint64    // Offset of each entry inside the `.pack` file
+}
+
+// Packfile pairs a `.pack` file with its index.
+type Packfile struct {
+	Path  string     // Path to the `.pack` file
+	Index *PackIndex // Parsed `.idx` file
+}
+
+// packCache keeps parsed indexes around so repeated lookups do not re-read them.
+var packCache = struct {
+	sync.Mutex
+	entries map[string]cachedPack
+}{entries: make(map[string]cachedPack)}
+
+type cachedPack struct {
+	modTime time.Time
+	pack    *Packfile
+}
+
+// Count returns the number of objects in the index.
+func (idx *PackIndex) Count() int {
+	return int(idx.Fanout[255])
+}
+
+// Hash returns the hex object name stored at position i.
+func (idx *PackIndex) Hash(i int) string {
+	return hex.EncodeToString(idx.Hashes[i*20 : i*20+20])
+}
+
+// Find returns the pack offset of the given object, if present.
+func (idx *PackIndex) Find(sha string) (uint64, bool) {
+	raw, err := hex.DecodeString(sha)
+	if err != nil || len(raw) != 20 {
+		return 0, false
+	}
+
+	lo := 0
+	if raw[0] > 0 {
+		lo = int(idx.Fanout[raw[0]-1])
+	}
+	hi := int(idx.Fanout[raw[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(idx.Hashes[(lo+i)*20:(lo+i)*20+20], raw) >= 0
+	})
+	if i < hi && bytes.Equal(idx.Hashes[i*20:i*20+20], raw) {
+		return idx.Offsets[i], true
+	}
+	return 0, false
+}
+
+// ReadPackIndex parses a version 2 pack index file.
+func ReadPackIndex(path string) (*PackIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack index: %w", err)
+	}
+
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], []byte("\xfftOc")) {
+		return nil, fmt.Errorf("unsupported pack index format: %s", path)
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != 2 {
+		return nil, fmt.Errorf("unsupported pack index version %d: %s", version, path)
+	}
+
+	idx := &PackIndex{}
+	pos := 8
+	for i := 0; i < 256; i++ {
+		idx.Fanout[i] = binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+		// Counts never decrease, so none can exceed the total in the last
+		// slot and Find stays within Hashes
+		if i > 0 && idx.Fanout[i] < idx.Fanout[i-1] {
+			return nil, fmt.Errorf("corrupt fanout table in pack index: %s", path)
+		}
+	}
+
+	count := idx.Count()
+	need := pos + count*(20+4+4) + 40
+	if len(data) < need {
+		return nil, fmt.Errorf("truncated pack index: %s", path)
+	}
+
+	idx.Hashes = data[pos : pos+count*20]
+	pos += count * 20
+
+	idx.CRCs = make([]uint32, count)
+	for i := 0; i < count; i++ {
+		idx.CRCs[i] = binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+	}
+
+	smallOffsets := data[pos : pos+count*4]
+	pos += count * 4
+	largeOffsets := data[pos : len(data)-40]
+
+	idx.Offsets = make([]uint64, count)
+	for i := 0; i < count; i++ {
+		off := binary.BigEndian.Uint32(smallOffsets[i*4:])
+		if off&0x80000000 == 0 {
+			idx.Offsets[i] = uint64(off)
+			continue
+		}
+		large := int(off&0x7fffffff) * 8
+		if large+8 > len(largeOffsets) {
+			return nil, fmt.Errorf("invalid large offset in pack index: %s", path)
+		}
+		idx.Offsets[i] = binary.BigEndian.Uint64(largeOffsets[large:])
+	}
+
+	return idx, nil
+}
+
+// OpenPackfiles returns every packfile in the repository's `.git/objects/pack` directory.
+func OpenPackfiles(repoPath string) ([]*Packfile, error) {
+	packDir := filepath.Join(repoPath, ".git", "objects", "pack")
+	idxPaths, err := filepath.Glob(filepath.Join(packDir, "pack-*.idx"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packfiles: %w", err)
+	}
+	sort.Strings(idxPaths)
+
+	var packs []*Packfile
+	for _, idxPath := range idxPaths {
+		packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+		info, err := os.Stat(idxPath)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(packPath); err != nil {
+			continue
+		}
+
+		packCache.Lock()
+		cached, ok := packCache.entries[idxPath]
+		packCache.Unlock()
+		if ok && cached.modTime.Equal(info.ModTime()) {
+			packs = append(packs, cached.pack)
+			continue
+		}
+
+		idx, err := ReadPackIndex(idxPath)
+		if err != nil {
+			return nil, err
+		}
+		pack := &Packfile{Path: packPath, Index: idx}
+
+		packCache.Lock()
+		packCache.entries[idxPath] = cachedPack{modTime: info.ModTime(), pack: pack}
+		packCache.Unlock()
+
+		packs = append(packs, pack)
+	}
+
+	return packs, nil
+}
+
+// readPackedObject searches every packfile for the object and inflates it.
+func readPackedObject(repoPath, sha string) (string, []byte, error) {
+	packs, err := OpenPackfiles(repoPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, pack := range packs {
+		offset, ok := pack.Index.Find(sha)
+		if !ok {
+			continue
+		}
+		objType, data, err := pack.ReadAt(repoPath, offset)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read object %s from %s: %w", sha, filepath.Base(pack.Path), err)
+		}
+		return objType, data, nil
+	}
+
+	return "", nil, fmt.Errorf("failed to open object %s: %w", sha, ErrObjectNotFound)
+}
+
+// ReadAt reads and fully resolves the object stored at the given pack offset.
+// Bases of REF_DELTA entries that live outside this pack are looked up in the
+// rest of the repository.
+func (p *Packfile) ReadAt(repoPath string, offset uint64) (string, []byte, error) {
+	file, size, err := openPack(p.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	// Walk the delta chain down to a whole object, remembering each delta.
+	var deltas [][]byte
+	for depth := 0; ; depth++ {
+		if depth > maxDeltaChain {
+			return "", nil, fmt.Errorf("delta chain too long at offset %d", offset)
+		}
+
+		entry, err := readPackEntry(file, size, offset)
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch entry.kind {
+		case packObjOfsDelta:
+			deltas = append(deltas, entry.data)
+			offset = entry.baseOffset
+			continue
+		case packObjRefDelta:
+			deltas = append(deltas, entry.data)
+			if baseOffset, ok := p.Index.Find(entry.baseHash); ok {
+				offset = baseOffset
+				continue
+			}
+			baseType, baseData, err := ReadRawObject(repoPath, entry.baseHash)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to read delta base %s: %w", entry.baseHash, err)
+			}
+			return resolveDeltas(baseType, baseData, deltas)
+		}
+
+		objType, err := packTypeName(entry.kind)
+		if err != nil {
+			return "", nil, err
+		}
+		return resolveDeltas(objType, entry.data, deltas)
+	}
+}
+
+// openPack opens a `.pack` file and checks its header, returning the file
+// and its size.
+func openPack(path string) (*os.File, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open packfile: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat packfile: %w", err)
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header[:4], []byte("PACK")) {
+		file.Close()
+		return nil, 0, fmt.Errorf("unsupported packfile format: %s", path)
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		file.Close()
+		return nil, 0, fmt.Errorf("unsupported packfile version %d: %s", version, path)
+	}
+	return file, uint64(info.Size()), nil
+}
+
+// resolveDeltas applies the collected deltas, innermost first, to the base object.
+func resolveDeltas(objType string, data []byte, deltas [][]byte) (string, []byte, error) {
+	for i := len(deltas) - 1; i >= 0; i-- {
+		var err error
+		data, err = ApplyDelta(data, deltas[i])
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return objType, data, nil
+}
+
+// packEntry is a single undeltified or delta record inside a packfile.
+type packEntry struct {
+	kind       int
+	size       uint64
+	baseOffset uint64 // Set for OFS_DELTA entries
+	baseHash   string // Set for REF_DELTA entries
+	data       []byte // Inflated payload (the delta itself for delta entries)
+}
+
+// readPackEntry parses the entry header at offset and inflates its payload.
+// packSize bounds what the entry may claim, since the header is untrusted.
+func readPackEntry(file *os.File, packSize, offset uint64) (*packEntry, error) {
+	if offset < 12 || offset+20 >= packSize {
+		return nil, fmt.Errorf("pack entry offset %d is out of range", offset)
+	}
+	r := bufio.NewReader(io.NewSectionReader(file, int64(offset), 1<<62))
+
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack entry header: %w", err)
+	}
+	entry := &packEntry{kind: int(b>>4) & 7, size: uint64(b & 0x0f)}
+	for shift := uint(4); b&0x80 != 0; shift += 7 {
+		if b, err = r.ReadByte(); err != nil {
+			return nil, fmt.Errorf("failed to read pack entry header: %w", err)
+		}
+		entry.size |= uint64(b&0x7f) << shift
+	}
+
+	switch entry.kind {
+	case packObjOfsDelta:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delta offset: %w", err)
+		}
+		rel := uint64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return nil, fmt.Errorf("failed to read delta offset: %w", err)
+			}
+			rel = ((rel + 1) << 7) | uint64(b&0x7f)
+		}
+		if rel == 0 || rel > offset {
+			return nil, fmt.Errorf("invalid delta base offset at %d", offset)
+		}
+		entry.baseOffset = offset - rel
+	case packObjRefDelta:
+		var base [20]byte
+		if _, err := io.ReadFull(r, base[:]); err != nil {
+			return nil, fmt.Errorf("failed to read delta base: %w", err)
+		}
+		entry.baseHash = hex.EncodeToString(base[:])
+	case packObjCommit, packObjTree, packObjBlob, packObjTag:
+	default:
+		return nil, fmt.Errorf("unknown pack entry type %d at offset %d", entry.kind, offset)
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress pack entry: %w", err)
+	}
+	defer zr.Close()
+
+	// The payload is read as it inflates rather than allocated up front, so
+	// a corrupt size fails on the data instead of exhausting memory
+	remaining := packSize - 20 - offset
+	if entry.size/maxDeflateRatio > remaining {
+		return nil, fmt.Errorf("pack entry at %d claims %d bytes, more than the pack can hold", offset, entry.size)
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(min(entry.size, remaining)))
+	n, err := io.Copy(&buf, io.LimitReader(zr, int64(entry.size)))
+	if err == nil && uint64(n) != entry.size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inflate pack entry at %d: %w", offset, err)
+	}
+	entry.data = buf.Bytes()
+
+	return entry, nil
+}
+
+// packTypeName maps a pack type number to its object type name.
+func packTypeName(kind int) (string, error) {
+	switch kind {
+	case packObjCommit:
+		return "commit", nil
+	case packObjTree:
+		return "tree", nil
+	case packObjBlob:
+		return "blob", nil
+	case packObjTag:
+		return "tag", nil
+	default:
+		return "", fmt.Errorf("unknown pack object type %d", kind)
+	}
+}
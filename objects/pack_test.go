@@ -0,0 +1,61 @@
+package objects
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPackIndexFanout(t *testing.T) {
+	candidates := []*packCandidate{
+		{sha: "1111111111111111111111111111111111111111", offset: 12},
+		{sha: "c800000000000000000000000000000000000001", offset: 40},
+		{sha: "c8ffffffffffffffffffffffffffffffffffffff", offset: 80},
+	}
+	valid := encodePackIndex(candidates, make([]byte, 20))
+
+	corrupt := func(slot int, value uint32) []byte {
+		data := append([]byte{}, valid...)
+		binary.BigEndian.PutUint32(data[8+slot*4:], value)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  bool
+	}{
+		{name: "valid", data: valid},
+		{name: "count out of range", data: corrupt(0xc8, 0xffffffff), err: true},
+		{name: "count decreases", data: corrupt(0x12, 0), err: true},
+		{name: "total past the entries", data: corrupt(255, 4), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pack.idx")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			idx, err := ReadPackIndex(path)
+			if tt.err {
+				if err == nil {
+					t.Fatal("corrupt index was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadPackIndex: %v", err)
+			}
+			for _, c := range candidates {
+				if offset, ok := idx.Find(c.sha); !ok || offset != c.offset {
+					t.Errorf("Find(%s) = %d, %v; want %d", c.sha, offset, ok, c.offset)
+				}
+			}
+			if _, ok := idx.Find("c800000000000000000000000000000000000000"); ok {
+				t.Error("found an object that is not in the index")
+			}
+		})
+	}
+}
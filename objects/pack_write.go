@@ -0,0 +1,308 @@
+package objects
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Tuning knobs for delta selection while writing a pack.
+const (
+	deltaWindow   = 10 // Number of preceding objects tried as delta bases
+	maxDeltaDepth = 50 // Longest delta chain allowed in a written pack
+	minDeltaSize  = 64 // Objects smaller than this are always stored whole
+)
+
+// packCandidate is an object queued for writing into a new pack.
+type packCandidate struct {
+	sha    string
+	kind   int
+	data   []byte
+	base   *packCandidate // Chosen delta base, if any
+	delta  []byte         // Delta against base
+	depth  int            // Length of the delta chain ending here
+	offset uint64         // Offset of the entry once written
+	crc    uint32         // CRC32 of the written entry
+}
+
+// ListLooseObjects returns the SHA of every loose object in the repository.
+func ListLooseObjects(repoPath string) ([]string, error) {
+	objectsDir := filepath.Join(repoPath, ".git", "objects")
+	dirs, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read objects directory: %w", err)
+	}
+
+	var shas []string
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 || !isHex(dir.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(objectsDir, dir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object directory %s: %w", dir.Name(), err)
+		}
+		for _, file := range files {
+			if len(file.Name()) == 38 && isHex(file.Name()) {
+				shas = append(shas, dir.Name()+file.Name())
+			}
+		}
+	}
+
+	sort.Strings(shas)
+	return shas, nil
+}
+
+// isHex reports whether s consists only of lowercase hexadecimal digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// WritePackfile stores the given objects in a new packfile with a version 2
+// index under `.git/objects/pack`, delta-compressing similar objects. It
+// returns the path of the new `.pack` file.
+func WritePackfile(repoPath string, shas []string) (string, error) {
+	candidates := make([]*packCandidate, 0, len(shas))
+	seen := make(map[string]bool, len(shas))
+	for _, sha := range shas {
+		if seen[sha] {
+			continue
+		}
+		seen[sha] = true
+
+		objType, data, err := ReadRawObject(repoPath, sha)
+		if err != nil {
+			return "", fmt.Errorf("failed to read object %s: %w", sha, err)
+		}
+		kind, err := packTypeNumber(objType)
+		if err != nil {
+			return "", err
+		}
+		candidates = append(candidates, &packCandidate{sha: sha, kind: kind, data: data})
+	}
+
+	findDeltas(candidates)
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(len(candidates)))
+
+	for _, c := range candidates {
+		c.offset = uint64(pack.Len())
+		entry, err := encodePackEntry(c)
+		if err != nil {
+			return "", err
+		}
+		c.crc = crc32.ChecksumIEEE(entry)
+		pack.Write(entry)
+	}
+	packSum := sha1.Sum(pack.Bytes())
+	pack.Write(packSum[:])
+
+	index := encodePackIndex(candidates, packSum[:])
+
+	packDir := filepath.Join(repoPath, ".git", "objects", "pack")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create pack directory: %w", err)
+	}
+
+	base := filepath.Join(packDir, "pack-"+hex.EncodeToString(packSum[:]))
+	if err := writeFileAtomic(base+".pack", pack.Bytes(), 0444); err != nil {
+		return "", fmt.Errorf("failed to write packfile: %w", err)
+	}
+	if err := writeFileAtomic(base+".idx", index, 0444); err != nil {
+		return "", fmt.Errorf("failed to write pack index: %w", err)
+	}
+
+	return base + ".pack", nil
+}
+
+// findDeltas orders the candidates so that likely delta pairs sit next to
+// each other and picks, for each object, the best base within the window.
+func findDeltas(candidates []*packCandidate) {
+	order := make([]*packCandidate, len(candidates))
+	copy(order, candidates)
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].kind != order[j].kind {
+			return order[i].kind < order[j].kind
+		}
+		return len(order[i].data) > len(order[j].data)
+	})
+
+	for i, target := range order {
+		if len(target.data) < minDeltaSize {
+			continue
+		}
+		for j := i - 1; j >= 0 && j >= i-deltaWindow; j-- {
+			base := order[j]
+			if base.kind != target.kind || base.depth >= maxDeltaDepth {
+				continue
+			}
+			// Skip bases so different in size that a delta cannot win.
+			if len(base.data) < len(target.data)/2 {
+				continue
+			}
+			delta := CreateDelta(base.data, target.data)
+			limit := len(target.data) / 2
+			if target.delta != nil {
+				limit = len(target.delta)
+			}
+			if len(delta) < limit {
+				target.base, target.delta, target.depth = base, delta, base.depth+1
+			}
+		}
+	}
+
+	// A base must be written before anything deltified against it, so emit
+	// objects in the same order the bases were chosen in.
+	copy(candidates, order)
+}
+
+// encodePackEntry renders one object (or its delta) as a pack entry.
+func encodePackEntry(c *packCandidate) ([]byte, error) {
+	kind, payload := c.kind, c.data
+	if c.base != nil {
+		kind, payload = packObjOfsDelta, c.delta
+	}
+
+	var buf bytes.Buffer
+
+	// Type and size header
+	size := uint64(len(payload))
+	b := byte(kind<<4) | byte(size&0x0f)
+	size >>= 4
+	for size != 0 {
+		buf.WriteByte(b | 0x80)
+		b = byte(size & 0x7f)
+		size >>= 7
+	}
+	buf.WriteByte(b)
+
+	// Distance back to the delta base
+	if c.base != nil {
+		rel := c.offset - c.base.offset
+		var enc [10]byte
+		pos := len(enc) - 1
+		enc[pos] = byte(rel & 0x7f)
+		for rel >>= 7; rel != 0; rel >>= 7 {
+			rel--
+			pos--
+			enc[pos] = byte(0x80 | (rel & 0x7f))
+		}
+		buf.Write(enc[pos:])
+	}
+
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, fmt.Errorf("failed to compress object %s: %w", c.sha, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress object %s: %w", c.sha, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodePackIndex renders a version 2 `.idx` file for the written entries.
+func encodePackIndex(candidates []*packCandidate, packSum []byte) []byte {
+	sorted := make([]*packCandidate, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].sha < sorted[j].sha })
+
+	var buf bytes.Buffer
+	buf.WriteString("\xfftOc")
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+
+	var fanout [256]uint32
+	for _, c := range sorted {
+		first, _ := hex.DecodeString(c.sha[:2])
+		fanout[first[0]]++
+	}
+	var total uint32
+	for i := range fanout {
+		total += fanout[i]
+		binary.Write(&buf, binary.BigEndian, total)
+	}
+
+	for _, c := range sorted {
+		raw, _ := hex.DecodeString(c.sha)
+		buf.Write(raw)
+	}
+	for _, c := range sorted {
+		binary.Write(&buf, binary.BigEndian, c.crc)
+	}
+
+	var large []uint64
+	for _, c := range sorted {
+		if c.offset < 0x80000000 {
+			binary.Write(&buf, binary.BigEndian, uint32(c.offset))
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(0x80000000|len(large)))
+		large = append(large, c.offset)
+	}
+	for _, off := range large {
+		binary.Write(&buf, binary.BigEndian, off)
+	}
+
+	buf.Write(packSum)
+	idxSum := sha1.Sum(buf.Bytes())
+	buf.Write(idxSum[:])
+
+	return buf.Bytes()
+}
+
+// packTypeNumber maps an object type name to its pack type number.
+func packTypeNumber(objType string) (int, error) {
+	switch objType {
+	case "commit":
+		return packObjCommit, nil
+	case "tree":
+		return packObjTree, nil
+	case "blob":
+		return packObjBlob, nil
+	case "tag":
+		return packObjTag, nil
+	default:
+		return 0, fmt.Errorf("unknown object type: %s", objType)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place so readers never observe a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp_"+strings.TrimPrefix(filepath.Base(path), ".")+"_")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,100 @@
+package objects
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature identifies who created a commit or tag, and when. The time zone
+// of When is the author's offset and is written back out unchanged.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+
+	raw string // Header value this signature was parsed from, if any
+}
+
+// String formats the signature the way it appears in commit and tag
+// headers: "Name <email> <unix seconds> <+hhmm>".
+func (s Signature) String() string {
+	// Reproduce a parsed header byte for byte, even if it was not written
+	// in the canonical form, as long as the fields have not been changed
+	if s.raw != "" {
+		parsed, _ := ParseSignature(s.raw)
+		if parsed.Name == s.Name && parsed.Email == s.Email && parsed.When.Equal(s.When) &&
+			FormatTimezone(parsed.When) == FormatTimezone(s.When) {
+			return s.raw
+		}
+	}
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), FormatTimezone(s.When))
+}
+
+// IsZero reports whether the signature is unset.
+func (s Signature) IsZero() bool {
+	return s.Name == "" && s.Email == "" && s.When.IsZero() && s.raw == ""
+}
+
+// ParseSignature parses a "Name <email> <unix seconds> <+hhmm>" header value.
+// Even when an error is returned, the signature remembers the original
+// value so that it is written back unchanged.
+func ParseSignature(value string) (Signature, error) {
+	sig := Signature{raw: value}
+	open := strings.LastIndex(value, "<")
+	closing := strings.LastIndex(value, ">")
+	if open < 0 || closing < open {
+		return sig, fmt.Errorf("malformed signature: %q", value)
+	}
+	sig.Name = strings.TrimSpace(value[:open])
+	sig.Email = value[open+1 : closing]
+
+	fields := strings.Fields(value[closing+1:])
+	if len(fields) == 0 {
+		return sig, fmt.Errorf("signature has no timestamp: %q", value)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return sig, fmt.Errorf("malformed timestamp in signature: %q", value)
+	}
+	sig.When = time.Unix(seconds, 0).UTC()
+	if len(fields) > 1 {
+		loc, ok := ParseTimezone(fields[1])
+		if !ok {
+			return sig, fmt.Errorf("malformed timezone in signature: %q", value)
+		}
+		sig.When = sig.When.In(loc)
+	}
+	return sig, nil
+}
+
+// ParseTimezone converts a "+hhmm" offset into a fixed time zone named after
+// the offset, so that unusual spellings such as "-0000" survive a round trip.
+func ParseTimezone(tz string) (*time.Location, bool) {
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return nil, false
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return nil, false
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone(tz, offset), true
+}
+
+// FormatTimezone returns the "+hhmm" offset of a time, preferring the zone
+// name recorded by ParseTimezone when it still matches.
+func FormatTimezone(t time.Time) string {
+	name, offset := t.Zone()
+	if loc, ok := ParseTimezone(name); ok {
+		if _, parsed := time.Unix(0, 0).In(loc).Zone(); parsed == offset {
+			return name
+		}
+	}
+	return t.Format("-0700")
+}
@@ -0,0 +1,109 @@
+package objects
+
+import "bytes"
+
+// Tag represents an annotated Git tag object.
+type Tag struct {
+	Object       string     // SHA-1 hash of the tagged object
+	ObjectType   string     // Type of the tagged object (e.g., "commit")
+	Name         string     // Name of the tag
+	Tagger       *Signature // Whoever created the tag, and when; nil for very old tags
+	ExtraHeaders []Header   // Other headers in their original order
+	Message      string     // Tag message
+
+	headersOnly bool // Parsed from an object that ends after its headers, with no blank line
+}
+
+// Serialize converts the tag object into bytes for storage.
+func (t *Tag) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+
+	known := []Header{
+		{Key: "object", Value: t.Object},
+		{Key: "type", Value: t.ObjectType},
+		{Key: "tag", Value: t.Name},
+	}
+
+	// Very old tags carry no tagger line
+	if t.Tagger != nil {
+		known = append(known, Header{Key: "tagger", Value: t.Tagger.String()})
+	}
+
+	// Any other headers go back exactly where they were read
+	writeHeaders(&buf, known, t.ExtraHeaders)
+
+	if !t.headersOnly || t.Message != "" {
+		buf.WriteString("\n") // Separate metadata and message with a blank line
+	}
+	buf.WriteString(t.Message)
+
+	return buf.Bytes(), nil
+}
+
+// Deserialize populates the tag object from bytes.
+func (t *Tag) Deserialize(data []byte) error {
+	headers, message, separated := parseHeaders(data)
+	t.headersOnly = !separated
+
+	known := 0
+	for _, h := range headers {
+		switch h.Key {
+		case "object":
+			t.Object = h.Value
+		case "type":
+			t.ObjectType = h.Value
+		case "tag":
+			t.Name = h.Value
+		case "tagger":
+			// A malformed tagger is kept so that it is written back unchanged
+			sig, _ := ParseSignature(h.Value)
+			t.Tagger = &sig
+		default:
+			h.placed, h.after = true, known
+			t.ExtraHeaders = append(t.ExtraHeaders, h)
+			continue
+		}
+		known++
+	}
+
+	t.Message = message
+	return nil
+}
+
+// signatureMarkers start the signature Git appends to a signed tag message.
+var signatureMarkers = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN PGP MESSAGE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+}
+
+// SignedPayload splits a signed tag into the bytes that were signed and the
+// armored signature at the end of its message. Like Git, the last line that
+// starts a signature is taken as its beginning. The signature is empty for
+// unsigned tags.
+func (t *Tag) SignedPayload() ([]byte, string, error) {
+	data, err := t.Serialize()
+	if err != nil {
+		return nil, "", err
+	}
+	start := len(data)
+	for offset := 0; offset < len(data); {
+		for _, marker := range signatureMarkers {
+			if bytes.HasPrefix(data[offset:], []byte(marker)) {
+				start = offset
+			}
+		}
+		eol := bytes.IndexByte(data[offset:], '\n')
+		if eol < 0 {
+			break
+		}
+		offset += eol + 1
+	}
+	return data[:start], string(data[start:]), nil
+}
+
+// Type returns the type of the object ("tag").
+func (t *Tag) Type() string {
+	return "tag"
+}
@@ -2,14 +2,26 @@ package objects
 
 import (
 	"bytes"
-	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 )
 
+// Modes used in tree entries. Git writes directory modes without the leading
+// zero ("40000"), so that is what gets stored even though it is usually shown
+// as "040000".
+const (
+	ModeTree       = "40000"
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeGitlink    = "160000"
+)
+
 // TreeEntry represents a single entry in a tree object.
 type TreeEntry struct {
-	Mode string // File mode (e.g., "100644" for a file, "040000" for a directory)
+	Mode string // File mode (e.g., "100644" for a file, "40000" for a directory)
 	Hash string // SHA-1 hash of the referenced blob or tree
 	Name string // Name of the file or directory
 }
@@ -29,32 +41,39 @@ func (t *Tree) Serialize() ([]byte, error) {
 		buf.WriteString(entryData)
 
 		// Write the hash as raw bytes (not as a hex string)
-		hashBytes := decodeHex(entry.Hash)
+		hashBytes, err := decodeHex(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("tree entry %s: %w", entry.Name, err)
+		}
 		buf.Write(hashBytes)
 	}
 
 	return buf.Bytes(), nil
 }
 
-// Deserialize populates the tree object from bytes.
-func (t *Tree) Deserialize(data []byte) {
+// Deserialize populates the tree object from bytes. Malformed and
+// truncated entries are reported rather than skipped.
+func (t *Tree) Deserialize(data []byte) error {
 	var entries []TreeEntry
 	for len(data) > 0 {
 		// Find the null byte separating name and hash
 		nullIdx := bytes.IndexByte(data, '\x00')
 		if nullIdx < 0 {
-			break
+			return fmt.Errorf("tree entry %d has no NUL after its name", len(entries))
 		}
 
 		// Parse mode and name
 		metadata := string(data[:nullIdx])
 		parts := strings.SplitN(metadata, " ", 2)
 		if len(parts) != 2 {
-			break
+			return fmt.Errorf("tree entry %d has no mode", len(entries))
 		}
 		mode, name := parts[0], parts[1]
 
 		// Parse hash
+		if len(data) < nullIdx+21 {
+			return fmt.Errorf("tree entry %q is truncated", name)
+		}
 		hashBytes := data[nullIdx+1 : nullIdx+21]
 		hash := encodeHex(hashBytes)
 
@@ -69,6 +88,127 @@ func (t *Tree) Deserialize(data []byte) {
 		data = data[nullIdx+21:]
 	}
 	t.Entries = entries
+	return nil
+}
+
+// IsTree reports whether the entry refers to a subtree.
+func (e TreeEntry) IsTree() bool {
+	return e.Mode == ModeTree || e.Mode == "0"+ModeTree
+}
+
+// Sort orders the entries the way Git requires: by name, with subtrees
+// compared as if their name ended in a slash.
+func (t *Tree) Sort() {
+	sort.Slice(t.Entries, func(i, j int) bool {
+		return entrySortKey(t.Entries[i]) < entrySortKey(t.Entries[j])
+	})
+}
+
+// entrySortKey returns the name used when ordering a tree entry.
+func entrySortKey(e TreeEntry) string {
+	if e.IsTree() {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+// CheckEntryName rejects tree entry names Git refuses to store: empty
+// names, "." and "..", ".git" in any case, and names containing a slash.
+func CheckEntryName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.EqualFold(name, ".git") {
+		return fmt.Errorf("invalid entry name %q", name)
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("entry name %q contains a slash", name)
+	}
+	return nil
+}
+
+// CheckPath rejects a slash-separated path that is unsafe to write to the
+// worktree or index: an absolute path, one with a backslash (a separator on
+// Windows), or one with a component CheckEntryName rejects.
+func CheckPath(p string) error {
+	if strings.Contains(p, "\\") {
+		return fmt.Errorf("invalid path '%s'", p)
+	}
+	for _, part := range strings.Split(p, "/") {
+		if CheckEntryName(part) != nil {
+			return fmt.Errorf("invalid path '%s'", p)
+		}
+	}
+	return nil
+}
+
+// FlattenTree reads a tree and all of its subtrees and returns every
+// non-tree entry keyed by its full slash-separated path. The Name of each
+// returned entry is that full path.
+func FlattenTree(repoPath, treeHash string) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+	if err := flattenTree(repoPath, treeHash, "", entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func flattenTree(repoPath, treeHash, prefix string, entries map[string]TreeEntry) error {
+	obj, err := ReadObject(repoPath, treeHash)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %w", treeHash, err)
+	}
+	tree, ok := obj.(*Tree)
+	if !ok {
+		return fmt.Errorf("object %s is a %s, not a tree", treeHash, obj.Type())
+	}
+
+	for _, entry := range tree.Entries {
+		fullPath := entry.Name
+		if prefix != "" {
+			fullPath = prefix + "/" + entry.Name
+		}
+		if entry.IsTree() {
+			if err := flattenTree(repoPath, entry.Hash, fullPath, entries); err != nil {
+				return err
+			}
+			continue
+		}
+		entry.Name = fullPath
+		entries[fullPath] = entry
+	}
+	return nil
+}
+
+// LookupPath finds the entry at a slash-separated path below a tree. The
+// returned entry's Name is the last path component.
+func LookupPath(repoPath, treeHash, filePath string) (TreeEntry, bool, error) {
+	current := TreeEntry{Mode: ModeTree, Hash: treeHash}
+	for _, part := range strings.Split(strings.Trim(filePath, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if !current.IsTree() {
+			return TreeEntry{}, false, nil
+		}
+		obj, err := ReadObject(repoPath, current.Hash)
+		if err != nil {
+			return TreeEntry{}, false, fmt.Errorf("failed to read tree %s: %w", current.Hash, err)
+		}
+		tree, ok := obj.(*Tree)
+		if !ok {
+			return TreeEntry{}, false, fmt.Errorf("object %s is a %s, not a tree", current.Hash, obj.Type())
+		}
+
+		found := false
+		for _, entry := range tree.Entries {
+			if entry.Name == part {
+				current, found = entry, true
+				break
+			}
+		}
+		if !found {
+			return TreeEntry{}, false, nil
+		}
+	}
+	return current, true, nil
 }
 
 // Type returns the type of the object ("tree").
@@ -81,11 +221,11 @@ func encodeHex(data []byte) string {
 	return fmt.Sprintf("%x", data)
 }
 
-// decodeHex converts a hex string to raw bytes.
-func decodeHex(hexStr string) []byte {
-	data := make([]byte, len(hexStr)/2)
-	for i := 0; i < len(data); i++ {
-		binary.Read(strings.NewReader(hexStr[2*i:2*i+2]), binary.BigEndian, &data[i])
+// decodeHex converts a hex object name to its 20 raw bytes.
+func decodeHex(hexStr string) ([]byte, error) {
+	data, err := hex.DecodeString(hexStr)
+	if err != nil || len(data) != 20 {
+		return nil, fmt.Errorf("invalid object name %q", hexStr)
 	}
-	return data
+	return data, nil
 }
@@ -0,0 +1,41 @@
+package objects
+
+import "testing"
+
+func TestTreeDeserializeMalformed(t *testing.T) {
+	hash := string(make([]byte, 20))
+	tests := []struct {
+		name string
+		data string
+		err  bool
+	}{
+		{name: "empty", data: ""},
+		{name: "one entry", data: "100644 f\x00" + hash},
+		{name: "truncated hash", data: "100644 f\x00abc", err: true},
+		{name: "truncated second entry", data: "100644 f\x00" + hash + "100644 g\x00", err: true},
+		{name: "no NUL", data: "100644 f", err: true},
+		{name: "no mode", data: "f\x00" + hash, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := &Tree{}
+			err := tree.Deserialize([]byte(tt.data))
+			if tt.err && err == nil {
+				t.Fatalf("malformed tree was accepted as %v", tree.Entries)
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("Deserialize: %v", err)
+			}
+		})
+	}
+}
+
+func TestTreeSerializeInvalidHash(t *testing.T) {
+	for _, hash := range []string{"", "abc", "zz825dc642cb6eb9a060e54bf8d69288fbee4904", "4b825dc642cb6eb9a060e54bf8d69288fbee490400"} {
+		tree := &Tree{Entries: []TreeEntry{{Mode: ModeFile, Name: "f", Hash: hash}}}
+		if data, err := tree.Serialize(); err == nil {
+			t.Errorf("hash %q was written as %x", hash, data)
+		}
+	}
+}
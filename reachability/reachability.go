@@ -0,0 +1,119 @@
+// Package reachability finds the objects a repository still needs: those
+// that can be reached from its refs, the index or its reflogs. Everything
+// else is garbage that `gc` and `prune` may delete.
+package reachability
+
+import (
+	"errors"
+	"fmt"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/staging"
+)
+
+// pseudoRefs are the refs outside `.git/refs` that keep objects alive while
+// an operation is in progress.
+var pseudoRefs = []string{"HEAD", "MERGE_HEAD", "ORIG_HEAD", "CHERRY_PICK_HEAD", "REVERT_HEAD"}
+
+// Root is an object something in the repository points at directly.
+type Root struct {
+	Source string // What points at it, e.g. "refs/heads/master" or "index (path/to/file)"
+	Hash   string
+	Type   string // Expected type when known from the source ("blob" for the index), otherwise empty
+}
+
+// Roots returns every object referenced by a ref, HEAD and the other
+// pseudo refs, an index entry or a reflog entry.
+func Roots(repoPath string) ([]Root, error) {
+	var roots []Root
+
+	allRefs, err := refs.List(repoPath, "refs/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+	for _, ref := range allRefs {
+		roots = append(roots, Root{Source: ref.Name, Hash: ref.Hash})
+	}
+
+	for _, name := range pseudoRefs {
+		hash, err := refs.Resolve(repoPath, name)
+		if errors.Is(err, refs.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		roots = append(roots, Root{Source: name, Hash: hash})
+	}
+
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	for _, entry := range index.Entries {
+		if entry.Mode == 0160000 {
+			continue // Submodule commits live in another repository
+		}
+		roots = append(roots, Root{Source: "index (" + entry.FilePath + ")", Hash: entry.BlobHash, Type: "blob"})
+	}
+
+	logs, err := refs.ListReflogs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range logs {
+		entries, err := refs.ReadReflog(repoPath, name)
+		if err != nil {
+			return nil, err
+		}
+		for i, entry := range entries {
+			source := fmt.Sprintf("%s@{%d}", name, len(entries)-1-i)
+			for _, hash := range []string{entry.Old, entry.New} {
+				if hash != refs.ZeroHash {
+					roots = append(roots, Root{Source: source, Hash: hash})
+				}
+			}
+		}
+	}
+	return roots, nil
+}
+
+// Walk returns the type of every object reachable from the given roots. It
+// fails if any of them is missing or unreadable, since deleting objects on
+// the basis of an incomplete walk would lose data.
+func Walk(repoPath string, roots []Root) (map[string]string, error) {
+	reachable := make(map[string]string)
+	pending := make([]objects.Link, 0, len(roots))
+	for _, root := range roots {
+		pending = append(pending, objects.Link{Hash: root.Hash, Type: root.Type})
+	}
+
+	for len(pending) > 0 {
+		link := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, ok := reachable[link.Hash]; ok {
+			continue
+		}
+
+		// Blobs have no links, so there is no need to read them
+		if link.Type == "blob" {
+			if !objects.HasObject(repoPath, link.Hash) {
+				return nil, fmt.Errorf("missing blob %s", link.Hash)
+			}
+			reachable[link.Hash] = "blob"
+			continue
+		}
+
+		objType, data, err := objects.ReadRawObject(repoPath, link.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object %s: %w", link.Hash, err)
+		}
+		links, err := objects.CheckObject(objType, data)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt %s %s: %w", objType, link.Hash, err)
+		}
+		reachable[link.Hash] = objType
+		pending = append(pending, links...)
+	}
+	return reachable, nil
+}
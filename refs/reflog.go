@@ -0,0 +1,254 @@
+package refs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopract/config"
+	"gopract/objects"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReflogEntry is one line of a ref's log under `.git/logs`: the ref moved
+// from Old to New, by Committer, for the reason given in Message.
+type ReflogEntry struct {
+	Old       string
+	New       string
+	Committer objects.Signature
+	Message   string
+}
+
+// String formats the entry as a line of a reflog file, without the newline.
+func (e ReflogEntry) String() string {
+	return fmt.Sprintf("%s %s %s\t%s", e.Old, e.New, e.Committer, e.Message)
+}
+
+// logPath returns the file holding the reflog of a ref.
+func logPath(repoPath, name string) string {
+	return filepath.Join(gitDir(repoPath), "logs", filepath.FromSlash(name))
+}
+
+// ReadReflog returns the reflog of a ref, oldest entry first. A ref without
+// a reflog has no entries. Malformed lines are skipped.
+func ReadReflog(repoPath, name string) ([]ReflogEntry, error) {
+	data, err := os.ReadFile(logPath(repoPath, name))
+	if err != nil {
+		if isMissing(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read reflog of %s: %w", name, err)
+	}
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if entry, ok := parseReflogLine(scanner.Text()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseReflogLine parses "<old> <new> <name> <email> <time> <tz>\t<message>".
+func parseReflogLine(line string) (ReflogEntry, bool) {
+	header, message, _ := strings.Cut(line, "\t")
+	if len(header) < 82 || header[40] != ' ' || header[81] != ' ' {
+		return ReflogEntry{}, false
+	}
+	entry := ReflogEntry{Old: header[:40], New: header[41:81], Message: message}
+	if !isHash(entry.Old) || !isHash(entry.New) {
+		return ReflogEntry{}, false
+	}
+	entry.Committer, _ = objects.ParseSignature(header[82:])
+	return entry, true
+}
+
+// ListReflogs returns the names of all refs that have a reflog, sorted.
+func ListReflogs(repoPath string) ([]string, error) {
+	root := filepath.Join(gitDir(repoPath), "logs")
+	var names []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reflogs: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// shouldLog reports whether updates to a ref are recorded. As with Git's
+// core.logAllRefUpdates default, HEAD, branches, remote-tracking refs, notes
+// and the stash are logged, as is any ref that already has a reflog.
+func shouldLog(repoPath, name string) bool {
+	if _, err := os.Stat(logPath(repoPath, name)); err == nil {
+		return true
+	}
+	switch value, _ := config.ReadValue(repoPath, "core", "logAllRefUpdates"); strings.ToLower(value) {
+	case "false", "no", "off", "0":
+		return false
+	case "always":
+		return true
+	}
+	if name == "HEAD" || name == "refs/stash" {
+		return true
+	}
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/notes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendReflog records that a ref moved from oldHash to newHash. Refs that
+// are not logged are skipped.
+func appendReflog(repoPath, name, oldHash, newHash, message string) error {
+	if !shouldLog(repoPath, name) {
+		return nil
+	}
+	entry := ReflogEntry{Old: oldHash, New: newHash, Committer: reflogIdentity(repoPath), Message: normalizeMessage(message)}
+
+	path := logPath(repoPath, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create reflog directory for %s: %w", name, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open reflog of %s: %w", name, err)
+	}
+	if _, err := f.WriteString(entry.String() + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write reflog of %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write reflog of %s: %w", name, err)
+	}
+	return nil
+}
+
+// WriteReflog replaces the reflog of a ref with the given entries, oldest
+// first. It is used to expire or drop entries.
+func WriteReflog(repoPath, name string, entries []ReflogEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.WriteString(entry.String() + "\n")
+	}
+	path := logPath(repoPath, name)
+	tmp := path + ".lock"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write reflog of %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write reflog of %s: %w", name, err)
+	}
+	return nil
+}
+
+// CreateReflog makes sure a ref has a reflog, so that its updates are
+// logged whatever core.logAllRefUpdates says.
+func CreateReflog(repoPath, name string) error {
+	path := logPath(repoPath, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create reflog directory for %s: %w", name, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create reflog of %s: %w", name, err)
+	}
+	return f.Close()
+}
+
+// deleteReflog removes the reflog of a ref, if it has one.
+func deleteReflog(repoPath, name string) error {
+	path := logPath(repoPath, name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete reflog of %s: %w", name, err)
+	}
+	removeEmptyLogDirs(repoPath, path)
+	return nil
+}
+
+// moveReflog moves a reflog file, if there is one, creating the directories
+// the new path needs and removing those the old one leaves empty.
+func moveReflog(repoPath, oldPath, newPath string) error {
+	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create reflog directory: %w", err)
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to move reflog: %w", err)
+	}
+	removeEmptyLogDirs(repoPath, oldPath)
+	return nil
+}
+
+// removeEmptyLogDirs removes the directories below `logs/refs/<kind>` that
+// held a reflog and are now empty.
+func removeEmptyLogDirs(repoPath, path string) {
+	stop := filepath.Join(gitDir(repoPath), "logs", "refs")
+	for dir := filepath.Dir(path); dir != stop && filepath.Dir(dir) != stop && strings.HasPrefix(dir, stop); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+// reflogIdentity returns who is updating refs: the configured user,
+// overridden by GIT_COMMITTER_NAME and GIT_COMMITTER_EMAIL, falling back to
+// the login name and host so that ref updates never fail for lack of one.
+func reflogIdentity(repoPath string) objects.Signature {
+	name, email, _ := config.ReadUser(repoPath)
+	if value, ok := os.LookupEnv("GIT_COMMITTER_NAME"); ok {
+		name = value
+	}
+	if value, ok := os.LookupEnv("GIT_COMMITTER_EMAIL"); ok {
+		email = value
+	}
+	if name == "" || email == "" {
+		login := "unknown"
+		if u, err := user.Current(); err == nil {
+			login = u.Username
+		}
+		host, _ := os.Hostname()
+		if name == "" {
+			name = login
+		}
+		if email == "" {
+			email = login + "@" + host
+		}
+	}
+	when := time.Now()
+	_, offset := when.Zone()
+	return objects.Signature{Name: name, Email: email, When: when.In(time.FixedZone("", offset))}
+}
+
+// normalizeMessage folds a reflog message onto one line, collapsing runs of
+// whitespace as Git does.
+func normalizeMessage(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
@@ -0,0 +1,637 @@
+// Package refs reads and updates Git references: loose ref files under
+// `.git/refs`, the `.git/packed-refs` file and symbolic refs such as HEAD.
+package refs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// maxSymrefDepth bounds how many symbolic refs are followed before giving up.
+const maxSymrefDepth = 5
+
+// ErrNotFound is returned when a ref does not exist.
+var ErrNotFound = errors.New("ref not found")
+
+// Ref is a fully qualified ref name and the object it points at.
+type Ref struct {
+	Name string // e.g. "refs/heads/master"
+	Hash string // SHA-1 of the referenced object
+}
+
+// gitDir returns the `.git` directory of a repository.
+func gitDir(repoPath string) string {
+	return filepath.Join(repoPath, ".git")
+}
+
+// refPath returns the file that stores a loose ref.
+func refPath(repoPath, name string) string {
+	return filepath.Join(gitDir(repoPath), filepath.FromSlash(name))
+}
+
+// ReadSymbolic returns the target of a symbolic ref such as HEAD. The
+// boolean is false when the ref exists but holds a hash instead.
+func ReadSymbolic(repoPath, name string) (string, bool, error) {
+	data, err := os.ReadFile(refPath(repoPath, name))
+	if err != nil {
+		if isMissing(err) {
+			return "", false, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return "", false, fmt.Errorf("failed to read ref %s: %w", name, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if target, ok := strings.CutPrefix(value, "ref: "); ok {
+		return target, true, nil
+	}
+	return "", false, nil
+}
+
+// Follow resolves symbolic refs starting at name and returns the name of
+// the ref that actually stores (or would store) a hash.
+func Follow(repoPath, name string) (string, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		target, ok, err := ReadSymbolic(repoPath, name)
+		if errors.Is(err, ErrNotFound) || (err == nil && !ok) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		name = target
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs at %s", name)
+}
+
+// Resolve returns the hash a fully qualified ref points at, following
+// symbolic refs and falling back to packed refs.
+func Resolve(repoPath, name string) (string, error) {
+	name, err := Follow(repoPath, name)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(refPath(repoPath, name))
+	if err == nil {
+		hash := strings.TrimSpace(string(data))
+		if !isHash(hash) {
+			return "", fmt.Errorf("ref %s is corrupt: %q", name, hash)
+		}
+		return hash, nil
+	}
+	if !isMissing(err) {
+		return "", fmt.Errorf("failed to read ref %s: %w", name, err)
+	}
+
+	packed, err := ReadPacked(repoPath)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := packed[name]; ok {
+		return hash, nil
+	}
+	return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+}
+
+// Lookup expands a short name the way Git does (name, refs/name,
+// refs/tags/name, refs/heads/name, refs/remotes/name,
+// refs/remotes/name/HEAD) and returns the first ref that exists.
+func Lookup(repoPath, name string) (Ref, error) {
+	for _, candidate := range []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	} {
+		if candidate == name && !strings.HasPrefix(name, "refs/") && !isRootRef(name) {
+			continue
+		}
+		hash, err := Resolve(repoPath, candidate)
+		if err == nil {
+			return Ref{Name: candidate, Hash: hash}, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return Ref{}, err
+		}
+	}
+	return Ref{}, fmt.Errorf("%s: %w", name, ErrNotFound)
+}
+
+// isRootRef reports whether name is one of the all-caps refs that live
+// directly in `.git`, such as HEAD or MERGE_HEAD.
+func isRootRef(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Update points a ref (after following symbolic refs) at hash. When oldHash
+// is non-empty the update only happens if the ref still points there; use
+// a string of 40 zeros to require that the ref does not exist yet. The move
+// is recorded with message in the ref's reflog, and in HEAD's when HEAD
+// points at the ref.
+func Update(repoPath, name, hash, oldHash, message string) error {
+	if !isHash(hash) {
+		return fmt.Errorf("invalid object name for %s: %s", name, hash)
+	}
+
+	target, err := Follow(repoPath, name)
+	if err != nil {
+		return err
+	}
+	if target != "HEAD" && !isRootRef(target) && !ValidName(target) {
+		return fmt.Errorf("invalid ref name: %s", target)
+	}
+
+	var previous string
+	err = withLock(repoPath, target, func(lock *os.File) error {
+		current, err := Resolve(repoPath, target)
+		if errors.Is(err, ErrNotFound) {
+			current = ZeroHash
+		} else if err != nil {
+			return err
+		}
+		if oldHash != "" && current != oldHash {
+			return fmt.Errorf("ref %s moved: expected %s, found %s", target, oldHash, current)
+		}
+		previous = current
+		_, err = lock.WriteString(hash + "\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := appendReflog(repoPath, target, previous, hash, message); err != nil {
+		return err
+	}
+	if target != "HEAD" {
+		if head, symbolic, _ := ReadSymbolic(repoPath, "HEAD"); symbolic && head == target {
+			return appendReflog(repoPath, "HEAD", previous, hash, message)
+		}
+	}
+	return nil
+}
+
+// UpdateNoDeref writes hash into name itself, replacing a symbolic ref
+// instead of following it. This is how HEAD becomes detached.
+func UpdateNoDeref(repoPath, name, hash, message string) error {
+	if !isHash(hash) {
+		return fmt.Errorf("invalid object name for %s: %s", name, hash)
+	}
+	previous, err := Resolve(repoPath, name)
+	if err != nil {
+		previous = ZeroHash
+	}
+	err = withLock(repoPath, name, func(lock *os.File) error {
+		_, err := lock.WriteString(hash + "\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return appendReflog(repoPath, name, previous, hash, message)
+}
+
+// SetSymbolic makes name a symbolic ref pointing at target. Unless message
+// is empty, the change of the commit name resolves to is logged, as when
+// HEAD moves between branches.
+func SetSymbolic(repoPath, name, target, message string) error {
+	previous, err := Resolve(repoPath, name)
+	if err != nil {
+		previous = ZeroHash
+	}
+	return SetSymbolicFrom(repoPath, name, target, previous, message)
+}
+
+// SetSymbolicFrom is SetSymbolic for when the value name held is already
+// known, such as after renaming the branch it pointed at, which leaves it
+// dangling; previous is logged as the old value.
+func SetSymbolicFrom(repoPath, name, target, previous, message string) error {
+	err := withLock(repoPath, name, func(lock *os.File) error {
+		_, err := lock.WriteString("ref: " + target + "\n")
+		return err
+	})
+	if err != nil || message == "" {
+		return err
+	}
+	// Nothing is logged for a switch to a branch that does not exist yet
+	current, err := Resolve(repoPath, target)
+	if err != nil {
+		return nil
+	}
+	return appendReflog(repoPath, name, previous, current, message)
+}
+
+// Delete removes a ref from both the loose refs and packed-refs, along
+// with its reflog.
+func Delete(repoPath, name string) error {
+	if err := removeRef(repoPath, name); err != nil {
+		return err
+	}
+	return deleteReflog(repoPath, name)
+}
+
+// removeRef deletes the loose and packed copies of a ref.
+func removeRef(repoPath, name string) error {
+	found := false
+
+	loose := refPath(repoPath, name)
+	if err := os.Remove(loose); err == nil {
+		found = true
+		removeEmptyParents(repoPath, filepath.Dir(loose))
+	} else if !isMissing(err) {
+		return fmt.Errorf("failed to delete ref %s: %w", name, err)
+	}
+
+	packed, err := ReadPacked(repoPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := packed[name]; ok {
+		found = true
+		delete(packed, name)
+		if err := writePacked(repoPath, packed); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+// Rename moves a ref and its reflog to a new name, keeping its value. The
+// rename is logged for the new ref. As in Git, the reflog is parked under a
+// temporary name while the old ref is deleted, so that a ref can be renamed
+// to a name below itself (feature to feature/x) or above it, and a failure
+// part-way puts the old ref and reflog back. With overwrite an existing ref
+// at the new name is replaced under its lock, and its reflog is only dropped
+// once the rename has succeeded.
+func Rename(repoPath, oldName, newName string, overwrite bool) error {
+	hash, err := Resolve(repoPath, oldName)
+	if err != nil {
+		return err
+	}
+	if !ValidName(newName) {
+		return fmt.Errorf("invalid ref name: %s", newName)
+	}
+	replacing := false
+	if _, err := Resolve(repoPath, newName); err == nil {
+		if !overwrite {
+			return fmt.Errorf("ref %s already exists", newName)
+		}
+		replacing = true
+	}
+	oldLog, newLog := logPath(repoPath, oldName), logPath(repoPath, newName)
+	logsDir := filepath.Join(gitDir(repoPath), "logs", "refs")
+	tmpLog := filepath.Join(logsDir, ".tmp-renamed-log")
+	replacedLog := filepath.Join(logsDir, ".tmp-replaced-log")
+
+	if err := moveReflog(repoPath, oldLog, tmpLog); err != nil {
+		return err
+	}
+	if err := removeRef(repoPath, oldName); err != nil {
+		moveReflog(repoPath, tmpLog, oldLog)
+		return err
+	}
+	restore := func(parked string) {
+		if !replacing {
+			removeEmptyParents(repoPath, filepath.Dir(refPath(repoPath, newName)))
+		}
+		moveReflog(repoPath, parked, oldLog)
+		moveReflog(repoPath, replacedLog, newLog)
+		writeRef(repoPath, oldName, hash)
+	}
+	if replacing {
+		if err := moveReflog(repoPath, newLog, replacedLog); err != nil {
+			restore(tmpLog)
+			return err
+		}
+	}
+	if err := moveReflog(repoPath, tmpLog, newLog); err != nil {
+		restore(tmpLog)
+		return err
+	}
+	if err := writeRef(repoPath, newName, hash); err != nil {
+		restore(newLog)
+		return err
+	}
+
+	if replacing {
+		// The replaced ref is gone now; drop what is left of it
+		os.Remove(replacedLog)
+		packed, err := ReadPacked(repoPath)
+		if err != nil {
+			return err
+		}
+		if _, ok := packed[newName]; ok {
+			delete(packed, newName)
+			if err := writePacked(repoPath, packed); err != nil {
+				return err
+			}
+		}
+	}
+	// The value is unchanged, and logged as such
+	return appendReflog(repoPath, newName, hash, hash, RenameMessage(oldName, newName))
+}
+
+// writeRef points a ref at a hash without logging the change.
+func writeRef(repoPath, name, hash string) error {
+	return withLock(repoPath, name, func(lock *os.File) error {
+		_, err := lock.WriteString(hash + "\n")
+		return err
+	})
+}
+
+// RenameMessage is the reflog message for renaming a branch.
+func RenameMessage(oldName, newName string) string {
+	return fmt.Sprintf("Branch: renamed %s to %s", oldName, newName)
+}
+
+// List returns every ref under prefix (e.g. "refs/heads/"), combining loose
+// and packed refs, sorted by name.
+func List(repoPath, prefix string) ([]Ref, error) {
+	found, err := ReadPacked(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := refPath(repoPath, "refs")
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(gitDir(repoPath), path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		hash, err := Resolve(repoPath, name)
+		if err != nil {
+			return nil // Skip broken or dangling symbolic refs
+		}
+		found[name] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	var refs []Ref
+	for name, hash := range found {
+		if strings.HasPrefix(name, prefix) {
+			refs = append(refs, Ref{Name: name, Hash: hash})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+// CurrentBranch returns the branch HEAD points at (e.g. "refs/heads/master").
+// The boolean is false when HEAD is detached.
+func CurrentBranch(repoPath string) (string, bool, error) {
+	target, ok, err := ReadSymbolic(repoPath, "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	return target, ok, nil
+}
+
+// ValidName applies Git's ref name rules (see git-check-ref-format).
+func ValidName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "//") ||
+		strings.Contains(name, "@{") || strings.HasPrefix(name, "-") {
+		return false
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ShortName strips the well-known prefixes from a ref name for display.
+func ShortName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
+
+// ZeroHash is the hash Git uses to stand for "no object".
+const ZeroHash = "0000000000000000000000000000000000000000"
+
+// withLock creates `<ref>.lock`, lets fn write the new contents and renames
+// the lock over the ref, so readers never see a partially written ref.
+func withLock(repoPath, name string, fn func(lock *os.File) error) error {
+	path := refPath(repoPath, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for ref %s: %w", name, err)
+	}
+
+	lockPath := path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("ref %s is locked by another process: %s exists", name, lockPath)
+		}
+		return fmt.Errorf("failed to lock ref %s: %w", name, err)
+	}
+
+	if err := fn(lock); err != nil {
+		lock.Close()
+		os.Remove(lockPath)
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("failed to write ref %s: %w", name, err)
+	}
+	if err := os.Rename(lockPath, path); err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("failed to write ref %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadPacked parses `.git/packed-refs` into a map of ref name to hash.
+// Peeled lines ("^<hash>") are skipped.
+func ReadPacked(repoPath string) (map[string]string, error) {
+	packed := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(gitDir(repoPath), "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return packed, nil
+		}
+		return nil, fmt.Errorf("failed to read packed-refs: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if ok && isHash(hash) {
+			packed[name] = hash
+		}
+	}
+	return packed, nil
+}
+
+// writePacked rewrites `.git/packed-refs` under its lock file.
+func writePacked(repoPath string, packed map[string]string) error {
+	names := make([]string, 0, len(packed))
+	for name := range packed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return withLock(repoPath, "packed-refs", func(lock *os.File) error {
+		w := bufio.NewWriter(lock)
+		w.WriteString("# pack-refs with: sorted \n")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s %s\n", packed[name], name)
+		}
+		return w.Flush()
+	})
+}
+
+// Pack moves every loose ref under `.git/refs` into `.git/packed-refs` and
+// deletes the loose files, which keeps repositories with many tags and
+// branches fast. Symbolic refs stay loose. It returns how many refs were
+// packed.
+func Pack(repoPath string) (int, error) {
+	packed, err := ReadPacked(repoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	loose := make(map[string]string)
+	root := refPath(repoPath, "refs")
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(gitDir(repoPath), path)
+		if err != nil {
+			return err
+		}
+		if hash := strings.TrimSpace(string(data)); isHash(hash) {
+			loose[filepath.ToSlash(rel)] = hash
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list refs: %w", err)
+	}
+	if len(loose) == 0 {
+		return 0, nil
+	}
+
+	for name, hash := range loose {
+		packed[name] = hash
+	}
+	if err := writePacked(repoPath, packed); err != nil {
+		return 0, err
+	}
+
+	// Only remove loose refs nobody has moved since they were read
+	for name, hash := range loose {
+		path := refPath(repoPath, name)
+		if data, err := os.ReadFile(path); err != nil || strings.TrimSpace(string(data)) != hash {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return 0, fmt.Errorf("failed to remove loose ref %s: %w", name, err)
+		}
+		removeEmptyParents(repoPath, filepath.Dir(path))
+	}
+	return len(loose), nil
+}
+
+// removeEmptyParents deletes directories left empty by a ref deletion. Like
+// Git, it keeps `.git/refs` and the directories directly below it, such as
+// `.git/refs/heads`.
+func removeEmptyParents(repoPath, dir string) {
+	stop := refPath(repoPath, "refs")
+	for dir != stop && filepath.Dir(dir) != stop && strings.HasPrefix(dir, stop) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// isHash reports whether s is a full lowercase hex SHA-1.
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isMissing reports whether reading a ref failed because there is none: the
+// file does not exist, a directory of that name exists (refs/heads/feature
+// when refs/heads/feature/x exists), or a parent is a file
+// (refs/heads/feature/x when refs/heads/feature exists).
+func isMissing(err error) bool {
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		return true
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		if info, statErr := os.Stat(pathErr.Path); statErr == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
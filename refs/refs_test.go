@@ -0,0 +1,278 @@
+package refs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	hashA = "1111111111111111111111111111111111111111"
+	hashB = "2222222222222222222222222222222222222222"
+)
+
+// newTestRepo creates an empty `.git` directory with HEAD on master, and a
+// fixed identity for the reflog.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("GIT_COMMITTER_NAME", "Tester")
+	t.Setenv("GIT_COMMITTER_EMAIL", "tester@example.com")
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".git", "refs", "heads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, repo, "HEAD", "ref: refs/heads/master\n")
+	return repo
+}
+
+// writeTestFile writes a file below `.git`.
+func writeTestFile(t *testing.T, repo, name, contents string) {
+	t.Helper()
+	path := filepath.Join(repo, ".git", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		ref   string
+		want  string // Empty when the ref must not be found
+		err   bool   // Some other error is expected
+	}{
+		{
+			name:  "loose",
+			files: map[string]string{"refs/heads/master": hashA + "\n"},
+			ref:   "refs/heads/master",
+			want:  hashA,
+		},
+		{
+			name:  "packed",
+			files: map[string]string{"packed-refs": "# pack-refs with: sorted \n" + hashA + " refs/heads/master\n^" + hashB + "\n"},
+			ref:   "refs/heads/master",
+			want:  hashA,
+		},
+		{
+			name: "loose wins over packed",
+			files: map[string]string{
+				"refs/heads/master": hashB + "\n",
+				"packed-refs":       hashA + " refs/heads/master\n",
+			},
+			ref:  "refs/heads/master",
+			want: hashB,
+		},
+		{
+			name:  "symbolic",
+			files: map[string]string{"refs/heads/master": hashA + "\n"},
+			ref:   "HEAD",
+			want:  hashA,
+		},
+		{
+			name: "missing",
+			ref:  "refs/heads/master",
+		},
+		{
+			name:  "below an existing ref",
+			files: map[string]string{"refs/heads/feature": hashA + "\n"},
+			ref:   "refs/heads/feature/x",
+		},
+		{
+			name:  "directory of the same name",
+			files: map[string]string{"refs/heads/feature/x": hashA + "\n"},
+			ref:   "refs/heads/feature",
+		},
+		{
+			name:  "corrupt",
+			files: map[string]string{"refs/heads/master": "not a hash\n"},
+			ref:   "refs/heads/master",
+			err:   true,
+		},
+		{
+			name: "symbolic loop",
+			files: map[string]string{
+				"refs/heads/a": "ref: refs/heads/b\n",
+				"refs/heads/b": "ref: refs/heads/a\n",
+			},
+			ref: "refs/heads/a",
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			for name, contents := range tt.files {
+				writeTestFile(t, repo, name, contents)
+			}
+
+			got, err := Resolve(repo, tt.ref)
+			switch {
+			case tt.err:
+				if err == nil || errors.Is(err, ErrNotFound) {
+					t.Fatalf("got %q, %v; want an error other than not found", got, err)
+				}
+			case tt.want == "":
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("got %q, %v; want not found", got, err)
+				}
+			case err != nil || got != tt.want:
+				t.Fatalf("got %q, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCompareAndSwap(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string // Empty when the branch does not exist
+		oldHash string
+		ok      bool
+	}{
+		{name: "unconditional", current: hashA, oldHash: "", ok: true},
+		{name: "expected value", current: hashA, oldHash: hashA, ok: true},
+		{name: "moved meanwhile", current: hashA, oldHash: hashB, ok: false},
+		{name: "create", current: "", oldHash: ZeroHash, ok: true},
+		{name: "create over existing", current: hashA, oldHash: ZeroHash, ok: false},
+		{name: "expected value of a missing ref", current: "", oldHash: hashA, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			if tt.current != "" {
+				writeTestFile(t, repo, "refs/heads/master", tt.current+"\n")
+			}
+
+			err := Update(repo, "HEAD", hashB, tt.oldHash, "test")
+			got, _ := Resolve(repo, "refs/heads/master")
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+				if got != hashB {
+					t.Fatalf("branch is at %q, want %s", got, hashB)
+				}
+				entries, err := ReadReflog(repo, "HEAD")
+				if err != nil || len(entries) != 1 || entries[0].New != hashB {
+					t.Errorf("HEAD reflog = %v, %v; want the update", entries, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("update was not refused")
+			}
+			if got != tt.current {
+				t.Errorf("refused update moved the branch to %q", got)
+			}
+			if _, err := os.Stat(filepath.Join(repo, ".git", "refs", "heads", "master.lock")); !os.IsNotExist(err) {
+				t.Error("lock file left behind")
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  string
+		existing  map[string]string // Other refs, by name
+		packed    bool              // Old ref is only in packed-refs
+		overwrite bool
+		locked    bool // The new ref's lock file is held
+		ok        bool
+	}{
+		{name: "simple", from: "refs/heads/a", to: "refs/heads/b", ok: true},
+		{name: "below its own name", from: "refs/heads/feature", to: "refs/heads/feature/x", ok: true},
+		{name: "above its own name", from: "refs/heads/feature/x", to: "refs/heads/feature", ok: true},
+		{name: "packed", from: "refs/heads/a", to: "refs/heads/b", packed: true, ok: true},
+		{
+			name: "existing target", from: "refs/heads/a", to: "refs/heads/b",
+			existing: map[string]string{"refs/heads/b": hashB}, ok: false,
+		},
+		{
+			name: "existing target overwritten", from: "refs/heads/a", to: "refs/heads/b",
+			existing: map[string]string{"refs/heads/b": hashB}, overwrite: true, ok: true,
+		},
+		{
+			name: "overwrite with the target locked", from: "refs/heads/a", to: "refs/heads/b",
+			existing: map[string]string{"refs/heads/b": hashB}, overwrite: true, locked: true, ok: false,
+		},
+		{name: "new name locked", from: "refs/heads/a", to: "refs/heads/b", locked: true, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			if tt.packed {
+				writeTestFile(t, repo, "packed-refs", hashA+" "+tt.from+"\n")
+			} else {
+				writeTestFile(t, repo, tt.from, hashA+"\n")
+			}
+			writeTestFile(t, repo, "logs/"+tt.from, ZeroHash+" "+hashA+" Tester <tester@example.com> 1700000000 +0000\tcreated\n")
+			for name, hash := range tt.existing {
+				writeTestFile(t, repo, name, hash+"\n")
+				writeTestFile(t, repo, "logs/"+name, ZeroHash+" "+hash+" Tester <tester@example.com> 1700000000 +0000\tother\n")
+			}
+			if tt.locked {
+				writeTestFile(t, repo, tt.to+".lock", "")
+			}
+
+			err := Rename(repo, tt.from, tt.to, tt.overwrite)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("rename was not refused")
+				}
+				// Nothing may have changed
+				if got, err := Resolve(repo, tt.from); err != nil || got != hashA {
+					t.Errorf("old ref is %q, %v; want %s", got, err, hashA)
+				}
+				if entries, _ := ReadReflog(repo, tt.from); len(entries) != 1 || entries[0].Message != "created" {
+					t.Errorf("old reflog is %v", entries)
+				}
+				for name, hash := range tt.existing {
+					if got, err := Resolve(repo, name); err != nil || got != hash {
+						t.Errorf("%s is %q, %v; want %s", name, got, err, hash)
+					}
+					if entries, _ := ReadReflog(repo, name); len(entries) != 1 || entries[0].Message != "other" {
+						t.Errorf("reflog of %s is %v", name, entries)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Rename: %v", err)
+			}
+			if got, err := Resolve(repo, tt.to); err != nil || got != hashA {
+				t.Errorf("new ref is %q, %v; want %s", got, err, hashA)
+			}
+			if _, err := Resolve(repo, tt.from); tt.from != tt.to && !errors.Is(err, ErrNotFound) {
+				t.Errorf("old ref still resolves: %v", err)
+			}
+			entries, err := ReadReflog(repo, tt.to)
+			if err != nil || len(entries) != 2 || entries[0].Message != "created" ||
+				entries[1].Message != RenameMessage(tt.from, tt.to) {
+				t.Errorf("new reflog is %v, %v; want the old entries and the rename", entries, err)
+			}
+			packed, err := ReadPacked(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := packed[tt.from]; ok {
+				t.Error("old ref left in packed-refs")
+			}
+			leftovers, _ := filepath.Glob(filepath.Join(repo, ".git", "logs", "refs", ".tmp-*"))
+			if len(leftovers) > 0 {
+				t.Errorf("parked reflogs left behind: %s", strings.Join(leftovers, ", "))
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+// Package revparse turns the revision syntax accepted on the command line
+// into object names: full and abbreviated SHAs, ref names, ancestry
+// operators (`~`, `^`), peeling (`^{tree}`), paths (`rev:path`, `:path`) and
+// reflog entries (`@{n}`, `@{-n}`). See gitrevisions(7).
+package revparse
+
+import (
+	"errors"
+	"fmt"
+	"gopract/objects"
+	"gopract/refs"
+	"gopract/staging"
+	"strconv"
+	"strings"
+)
+
+// MinAbbrev is the shortest abbreviated SHA that is looked up.
+const MinAbbrev = 4
+
+// maxPeelDepth bounds how many nested tags are followed.
+const maxPeelDepth = 10
+
+// ErrUnknownRevision is returned when a name matches no ref or object.
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// AmbiguousError is returned when an abbreviated SHA matches several
+// objects.
+type AmbiguousError struct {
+	Prefix     string
+	Candidates []string // Full names of the matching objects
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("short object ID %s is ambiguous (%d candidates: %s)",
+		e.Prefix, len(e.Candidates), strings.Join(e.Candidates, ", "))
+}
+
+// Resolve returns the full SHA of the object a revision names.
+func Resolve(repoPath, rev string) (string, error) {
+	if rev == "" {
+		return "", fmt.Errorf("%w: empty revision", ErrUnknownRevision)
+	}
+
+	// ":path" and ":<stage>:path" name blobs in the index
+	if rest, ok := strings.CutPrefix(rev, ":"); ok {
+		return resolveIndexPath(repoPath, rest)
+	}
+
+	// "<rev>:<path>" names an entry in the tree of rev
+	if left, path, ok := strings.Cut(rev, ":"); ok {
+		sha, err := Resolve(repoPath, left)
+		if err != nil {
+			return "", err
+		}
+		return resolveTreePath(repoPath, sha, left, path)
+	}
+
+	// Split the base name from the ancestry and peeling operators
+	end := strings.IndexAny(rev, "^~")
+	if end < 0 {
+		end = len(rev)
+	}
+	sha, err := resolveBase(repoPath, rev[:end])
+	if err != nil {
+		return "", err
+	}
+
+	for ops := rev[end:]; ops != ""; {
+		op := ops[0]
+		ops = ops[1:]
+
+		// "^{type}" peels to a type; "^{}" peels tags
+		if op == '^' && strings.HasPrefix(ops, "{") {
+			closing := strings.Index(ops, "}")
+			if closing < 0 {
+				return "", fmt.Errorf("%w: %s", ErrUnknownRevision, rev)
+			}
+			if sha, err = Peel(repoPath, sha, ops[1:closing]); err != nil {
+				return "", fmt.Errorf("%s: %w", rev, err)
+			}
+			ops = ops[closing+1:]
+			continue
+		}
+
+		digits := 0
+		for digits < len(ops) && ops[digits] >= '0' && ops[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(ops[:digits]); err != nil {
+				return "", fmt.Errorf("%w: %s", ErrUnknownRevision, rev)
+			}
+			ops = ops[digits:]
+		}
+
+		switch op {
+		case '^':
+			sha, err = nthParent(repoPath, sha, n)
+		case '~':
+			for i := 0; i < n && err == nil; i++ {
+				sha, err = nthParent(repoPath, sha, 1)
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", rev, err)
+		}
+	}
+	return sha, nil
+}
+
+// resolveBase resolves a name without ancestry operators: a SHA, a ref or
+// a reflog entry.
+func resolveBase(repoPath, name string) (string, error) {
+	if name == "@" {
+		name = "HEAD"
+	}
+
+	if open := strings.Index(name, "@{"); open >= 0 && strings.HasSuffix(name, "}") {
+		return resolveReflog(repoPath, name[:open], name[open+2:len(name)-1])
+	}
+
+	if len(name) == 40 && isHex(name) {
+		return name, nil
+	}
+
+	// Refs take precedence over abbreviated SHAs, as in Git
+	ref, err := refs.Lookup(repoPath, name)
+	if err == nil {
+		return ref.Hash, nil
+	}
+	if !errors.Is(err, refs.ErrNotFound) {
+		return "", err
+	}
+
+	if len(name) >= MinAbbrev && isHex(strings.ToLower(name)) {
+		matches, err := objects.FindObjects(repoPath, strings.ToLower(name))
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 0:
+		case 1:
+			return matches[0], nil
+		default:
+			return "", &AmbiguousError{Prefix: name, Candidates: matches}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownRevision, name)
+}
+
+// resolveReflog looks up "<ref>@{n}", the value a ref had n changes ago,
+// or "@{-n}", the branch checked out n checkouts ago.
+func resolveReflog(repoPath, name, spec string) (string, error) {
+	n, err := strconv.Atoi(spec)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s@{%s} (only @{<n>} and @{-<n>} are supported)", ErrUnknownRevision, name, spec)
+	}
+
+	if n < 0 {
+		if name != "" {
+			return "", fmt.Errorf("%w: %s@{%s}", ErrUnknownRevision, name, spec)
+		}
+		return previousCheckout(repoPath, -n)
+	}
+
+	// "@{n}" on its own refers to the current branch
+	refName := "HEAD"
+	switch {
+	case name == "":
+		branch, onBranch, err := refs.CurrentBranch(repoPath)
+		if err != nil {
+			return "", err
+		}
+		if onBranch {
+			refName = branch
+		}
+	case name != "HEAD" && name != "@":
+		ref, err := refs.Lookup(repoPath, name)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrUnknownRevision, name)
+		}
+		refName = ref.Name
+	}
+
+	entries, err := refs.ReadReflog(repoPath, refName)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for '%s' only has %d entries", refs.ShortName(refName), len(entries))
+	}
+	return entries[len(entries)-1-n].New, nil
+}
+
+// previousCheckout resolves "@{-n}" to the commit of the n-th last branch
+// or commit HEAD was moved away from.
+func previousCheckout(repoPath string, n int) (string, error) {
+	from, err := PreviousCheckout(repoPath, n)
+	if err != nil {
+		return "", err
+	}
+	if ref, err := refs.Lookup(repoPath, "refs/heads/"+from); err == nil {
+		return ref.Hash, nil
+	}
+	return resolveBase(repoPath, from)
+}
+
+// PreviousCheckout returns the name of the n-th last branch (or the SHA of
+// the detached commit) that HEAD was moved away from, using the
+// "checkout: moving from A to B" entries of its reflog.
+func PreviousCheckout(repoPath string, n int) (string, error) {
+	entries, err := refs.ReadReflog(repoPath, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	remaining := n
+	for i := len(entries) - 1; i >= 0; i-- {
+		rest, ok := strings.CutPrefix(entries[i].Message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		if remaining--; remaining > 0 {
+			continue
+		}
+		from, _, _ := strings.Cut(rest, " to ")
+		return from, nil
+	}
+	return "", fmt.Errorf("%w: @{-%d} (not enough checkouts in the reflog)", ErrUnknownRevision, n)
+}
+
+// nthParent returns the n-th parent of a commit; the 0th is the commit
+// itself.
+func nthParent(repoPath, sha string, n int) (string, error) {
+	sha, err := Peel(repoPath, sha, "commit")
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return sha, nil
+	}
+	obj, err := objects.ReadObject(repoPath, sha)
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit %s: %w", sha, err)
+	}
+	parents := obj.(*objects.Commit).Parents
+	if n > len(parents) {
+		return "", fmt.Errorf("commit %s has no parent %d", sha, n)
+	}
+	return parents[n-1], nil
+}
+
+// Peel follows tags (and, for trees, commits) from an object until it
+// reaches one of the wanted type. An empty type peels tags only, and
+// "object" accepts anything.
+func Peel(repoPath, sha, objType string) (string, error) {
+	switch objType {
+	case "", "object", "commit", "tree", "blob", "tag":
+	default:
+		return "", fmt.Errorf("unknown object type %q", objType)
+	}
+
+	for depth := 0; depth < maxPeelDepth; depth++ {
+		obj, err := objects.ReadObject(repoPath, sha)
+		if err != nil {
+			return "", fmt.Errorf("failed to read object %s: %w", sha, err)
+		}
+		if objType == "object" || obj.Type() == objType {
+			return sha, nil
+		}
+		switch o := obj.(type) {
+		case *objects.Tag:
+			sha = o.Object
+			continue
+		case *objects.Commit:
+			if objType == "tree" {
+				return o.Tree, nil
+			}
+		}
+		if objType == "" {
+			return sha, nil
+		}
+		return "", fmt.Errorf("object %s is a %s, not a %s", sha, obj.Type(), objType)
+	}
+	return "", fmt.Errorf("too many nested tags at %s", sha)
+}
+
+// resolveTreePath finds the object at path in the tree of a revision.
+func resolveTreePath(repoPath, sha, rev, path string) (string, error) {
+	treeHash, err := Peel(repoPath, sha, "tree")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", rev, err)
+	}
+	path = strings.TrimPrefix(path, "./")
+	if path == "" {
+		return treeHash, nil
+	}
+	entry, found, err := objects.LookupPath(repoPath, treeHash, path)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", path, rev)
+	}
+	return entry.Hash, nil
+}
+
+// resolveIndexPath finds the blob staged at "path" or "<stage>:path".
+func resolveIndexPath(repoPath, spec string) (string, error) {
+	stage := 0
+	if len(spec) > 2 && spec[1] == ':' && spec[0] >= '0' && spec[0] <= '3' {
+		stage, spec = int(spec[0]-'0'), spec[2:]
+	}
+	path := strings.TrimPrefix(spec, "./")
+
+	index, err := staging.ReadIndex(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read index: %w", err)
+	}
+	inIndex := false
+	for _, entry := range index.Entries {
+		if entry.FilePath != path {
+			continue
+		}
+		if entry.Stage == stage {
+			return entry.BlobHash, nil
+		}
+		inIndex = true
+	}
+	if inIndex {
+		return "", fmt.Errorf("path '%s' is in the index, but not at stage %d", path, stage)
+	}
+	return "", fmt.Errorf("path '%s' does not exist in the index", path)
+}
+
+// Abbreviate returns the shortest prefix of sha, at least minLength long,
+// that names no other object.
+func Abbreviate(repoPath, sha string, minLength int) (string, error) {
+	if minLength < MinAbbrev {
+		minLength = MinAbbrev
+	}
+	for length := minLength; length < len(sha); length++ {
+		matches, err := objects.FindObjects(repoPath, sha[:length])
+		if err != nil {
+			return "", err
+		}
+		if len(matches) <= 1 {
+			return sha[:length], nil
+		}
+	}
+	return sha, nil
+}
+
+// isHex reports whether s consists only of lowercase hexadecimal digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return s != ""
+}
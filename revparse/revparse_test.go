@@ -0,0 +1,255 @@
+package revparse
+
+import (
+	"errors"
+	"fmt"
+	"gopract/objects"
+	"gopract/staging"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRepo is a small history built for the tests:
+//
+//	c1 - c2 - c3 - merge   (master, HEAD)
+//	  \           /
+//	   side ------         (side)
+//
+// with an annotated tag v1 on c2.
+type testRepo struct {
+	path                        string
+	c1, c2, c3, side, merge, v1 string
+	trees                       map[string]string // Root tree of each commit
+	blobs                       map[string]string // Blob at "f" in each commit
+	subBlob                     string            // Blob at "dir/g", the same in every commit
+	staged, ours                string            // Blobs in the index at stage 0 and stage 2
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	repo := &testRepo{path: t.TempDir(), trees: make(map[string]string), blobs: make(map[string]string)}
+	if err := os.MkdirAll(filepath.Join(repo.path, ".git", "refs", "heads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sig := objects.Signature{Name: "Tester", Email: "tester@example.com", When: time.Unix(1700000000, 0).UTC()}
+
+	write := func(obj objects.GitObject) string {
+		t.Helper()
+		hash, err := objects.WriteObject(obj, repo.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	repo.subBlob = write(&objects.Blob{Data: []byte("g\n")})
+	subTree := write(&objects.Tree{Entries: []objects.TreeEntry{{Mode: objects.ModeFile, Name: "g", Hash: repo.subBlob}}})
+	commit := func(message string, parents ...string) string {
+		t.Helper()
+		blob := write(&objects.Blob{Data: []byte(message + "\n")})
+		tree := write(&objects.Tree{Entries: []objects.TreeEntry{
+			{Mode: objects.ModeTree, Name: "dir", Hash: subTree},
+			{Mode: objects.ModeFile, Name: "f", Hash: blob},
+		}})
+		hash := write(&objects.Commit{Tree: tree, Parents: parents, Author: sig, Committer: sig, Message: message + "\n"})
+		repo.trees[hash], repo.blobs[hash] = tree, blob
+		return hash
+	}
+	repo.c1 = commit("c1")
+	repo.c2 = commit("c2", repo.c1)
+	repo.c3 = commit("c3", repo.c2)
+	repo.side = commit("side", repo.c1)
+	repo.merge = commit("merge", repo.c3, repo.side)
+	repo.v1 = write(&objects.Tag{Object: repo.c2, ObjectType: "commit", Name: "v1", Tagger: &sig, Message: "v1\n"})
+
+	repo.file(t, "HEAD", "ref: refs/heads/master\n")
+	repo.file(t, "refs/heads/master", repo.merge+"\n")
+	repo.file(t, "refs/heads/side", repo.side+"\n")
+	repo.file(t, "refs/tags/v1", repo.v1+"\n")
+
+	line := func(old, new, message string) string {
+		return fmt.Sprintf("%s %s Tester <tester@example.com> 1700000000 +0000\t%s\n", old, new, message)
+	}
+	zero := strings.Repeat("0", 40)
+	repo.file(t, "logs/refs/heads/master",
+		line(zero, repo.c1, "commit (initial): c1")+
+			line(repo.c1, repo.c3, "reset: moving to c3")+
+			line(repo.c3, repo.merge, "merge side"))
+	repo.file(t, "logs/HEAD",
+		line(zero, repo.c1, "commit (initial): c1")+
+			line(repo.c1, repo.side, "checkout: moving from master to side")+
+			line(repo.side, repo.merge, "checkout: moving from side to master"))
+
+	repo.staged = write(&objects.Blob{Data: []byte("staged\n")})
+	repo.ours = write(&objects.Blob{Data: []byte("ours\n")})
+	index := &staging.Index{Entries: []staging.IndexEntry{
+		{Mode: 0100644, BlobHash: repo.staged, FilePath: "f"},
+		{Mode: 0100644, BlobHash: repo.ours, Stage: 2, FilePath: "conflicted"},
+	}}
+	if err := staging.WriteIndex(repo.path, index); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// file writes a file below `.git`.
+func (r *testRepo) file(t *testing.T, name, contents string) {
+	t.Helper()
+	path := filepath.Join(r.path, ".git", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	repo := newTestRepo(t)
+
+	tests := []struct {
+		rev  string
+		want string // Empty when resolving must fail
+	}{
+		// Names
+		{"HEAD", repo.merge},
+		{"@", repo.merge},
+		{"master", repo.merge},
+		{"refs/heads/side", repo.side},
+		{repo.c2, repo.c2},
+		{repo.c3[:7], repo.c3},
+		{strings.ToUpper(repo.c3[:7]), repo.c3},
+		{"nonexistent", ""},
+
+		// Ancestry
+		{"master^", repo.c3},
+		{"master^1", repo.c3},
+		{"master^2", repo.side},
+		{"master^3", ""},
+		{"master^0", repo.merge},
+		{"master~", repo.c3},
+		{"master~2", repo.c2},
+		{"master~3", repo.c1},
+		{"master~4", ""},
+		{"master^^", repo.c2},
+		{"master^2~1", repo.c1},
+		{"HEAD~1^2", ""},
+
+		// Peeling
+		{"v1", repo.v1},
+		{"v1^{}", repo.c2},
+		{"v1^{commit}", repo.c2},
+		{"v1^{tree}", repo.trees[repo.c2]},
+		{"v1^{tag}", repo.v1},
+		{"v1^{object}", repo.v1},
+		{"v1^{blob}", ""},
+		{"v1~1", repo.c1},
+		{"master^{tree}", repo.trees[repo.merge]},
+		{"master^{bogus}", ""},
+		{"master^{tree", ""},
+
+		// Paths
+		{"master:f", repo.blobs[repo.merge]},
+		{"master~3:f", repo.blobs[repo.c1]},
+		{"master:dir/g", repo.subBlob},
+		{"master:./f", repo.blobs[repo.merge]},
+		{"master:", repo.trees[repo.merge]},
+		{"master:missing", ""},
+		{"v1:f", repo.blobs[repo.c2]},
+		{":f", repo.staged},
+		{":0:f", repo.staged},
+		{":2:conflicted", repo.ours},
+		{":conflicted", ""},
+		{":3:conflicted", ""},
+		{":missing", ""},
+
+		// Reflogs
+		{"master@{0}", repo.merge},
+		{"master@{1}", repo.c3},
+		{"master@{2}", repo.c1},
+		{"master@{3}", ""},
+		{"@{1}", repo.c3},
+		{"HEAD@{1}", repo.side},
+		{"@{-1}", repo.side},
+		{"@{-2}", repo.merge},
+		{"@{-3}", ""},
+		{"master@{1}~1", repo.c2},
+		{"master@{yesterday}", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			got, err := Resolve(repo.path, tt.rev)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("resolved to %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Find two blobs whose names share their first MinAbbrev digits
+	byPrefix := make(map[string][]byte)
+	var prefix string
+	var pair [2][]byte
+	for i := 0; prefix == ""; i++ {
+		data := []byte(fmt.Sprintf("blob %d\n", i))
+		p := objects.HashRaw("blob", data)[:MinAbbrev]
+		if other, ok := byPrefix[p]; ok {
+			prefix, pair = p, [2][]byte{other, data}
+		}
+		byPrefix[p] = data
+	}
+	var hashes []string
+	for _, data := range pair {
+		hash, err := objects.WriteRawObject(repo.path, "blob", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	_, err := Resolve(repo.path, prefix)
+	var ambiguous *AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("got %v, want an ambiguity error", err)
+	}
+	for _, hash := range hashes {
+		found := false
+		for _, c := range ambiguous.Candidates {
+			found = found || c == hash
+		}
+		if !found {
+			t.Errorf("candidates %v do not include %s", ambiguous.Candidates, hash)
+		}
+	}
+
+	// One more digit tells them apart, unless they share it too
+	for _, hash := range hashes {
+		if hashes[0][MinAbbrev] == hashes[1][MinAbbrev] {
+			break
+		}
+		if got, err := Resolve(repo.path, hash[:MinAbbrev+1]); err != nil || got != hash {
+			t.Errorf("Resolve(%s) = %s, %v; want %s", hash[:MinAbbrev+1], got, err, hash)
+		}
+	}
+
+	// Refs win over abbreviated names
+	repo.file(t, "refs/heads/"+prefix, repo.c1+"\n")
+	if got, err := Resolve(repo.path, prefix); err != nil || got != repo.c1 {
+		t.Errorf("Resolve(%s) = %s, %v; want the branch at %s", prefix, got, err, repo.c1)
+	}
+}
@@ -0,0 +1,203 @@
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// AllowedSigner is one line of an allowed-signers file (see the
+// ALLOWED SIGNERS section of ssh-keygen(1)).
+type AllowedSigner struct {
+	Principals  []string  // Identity patterns, e.g. "jane@example.com" or "*@example.com"
+	Namespaces  []string  // Namespaces the key may sign for; empty means any
+	ValidAfter  time.Time // Zero when unrestricted
+	ValidBefore time.Time // Zero when unrestricted
+	Key         ed25519.PublicKey
+}
+
+// LoadAllowedSigners parses an allowed-signers file. Lines with key types
+// other than ed25519 and certificate authorities are skipped.
+func LoadAllowedSigners(filePath string) ([]AllowedSigner, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed signers file: %w", err)
+	}
+
+	var signers []AllowedSigner
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		signer, ok, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filePath, lineNo, err)
+		}
+		if ok {
+			signers = append(signers, signer)
+		}
+	}
+	return signers, nil
+}
+
+// parseAllowedSigner parses "principals [options] keytype key [comment]".
+// The boolean is false for lines that cannot apply to an ed25519 signature.
+func parseAllowedSigner(line string) (AllowedSigner, bool, error) {
+	fields := splitFields(line)
+	if len(fields) < 3 {
+		return AllowedSigner{}, false, fmt.Errorf("expected principals, key type and key")
+	}
+
+	signer := AllowedSigner{Principals: strings.Split(fields[0], ",")}
+	rest := fields[1:]
+	if !isKeyType(rest[0]) {
+		for _, option := range splitOptions(rest[0]) {
+			name, value, _ := strings.Cut(option, "=")
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				return AllowedSigner{}, false, nil
+			case "namespaces":
+				signer.Namespaces = strings.Split(value, ",")
+			case "valid-after":
+				t, err := parseValidity(value)
+				if err != nil {
+					return AllowedSigner{}, false, err
+				}
+				signer.ValidAfter = t
+			case "valid-before":
+				t, err := parseValidity(value)
+				if err != nil {
+					return AllowedSigner{}, false, err
+				}
+				signer.ValidBefore = t
+			}
+		}
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return AllowedSigner{}, false, fmt.Errorf("expected key type and key")
+	}
+	if rest[0] != keyTypeEd25519 {
+		return AllowedSigner{}, false, nil
+	}
+	key, err := ParsePublicKey(rest[0], rest[1])
+	if err != nil {
+		return AllowedSigner{}, false, err
+	}
+	signer.Key = key
+	return signer, true, nil
+}
+
+// FindPrincipals returns the principals allowed to make a signature with
+// key in namespace at time when.
+func FindPrincipals(signers []AllowedSigner, key ed25519.PublicKey, namespace string, when time.Time) []string {
+	var principals []string
+	for _, s := range signers {
+		if !s.Key.Equal(key) {
+			continue
+		}
+		if len(s.Namespaces) > 0 && !matchesAny(s.Namespaces, namespace) {
+			continue
+		}
+		if !s.ValidAfter.IsZero() && when.Before(s.ValidAfter) {
+			continue
+		}
+		if !s.ValidBefore.IsZero() && !when.Before(s.ValidBefore) {
+			continue
+		}
+		principals = append(principals, strings.Join(s.Principals, ","))
+	}
+	return principals
+}
+
+// matchesAny reports whether value matches one of the wildcard patterns.
+// A pattern starting with "!" excludes matching values.
+func matchesAny(patterns []string, value string) bool {
+	matched := false
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		if ok, _ := path.Match(strings.TrimPrefix(p, "!"), value); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// isKeyType reports whether a field names an SSH key type rather than
+// holding options.
+func isKeyType(field string) bool {
+	return strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") ||
+		strings.HasPrefix(field, "sk-")
+}
+
+// parseValidity parses the YYYYMMDD[HHMM[SS]] timestamps of valid-after and
+// valid-before, optionally suffixed with "Z" for UTC.
+func parseValidity(value string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		value, loc = strings.TrimSuffix(value, "Z"), time.UTC
+	}
+	for _, layout := range []string{"20060102150405", "200601021504", "20060102"} {
+		if len(value) == len(layout) {
+			if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid validity time %q", value)
+}
+
+// splitFields splits a line on whitespace, keeping quoted strings together.
+func splitFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted := false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			current.WriteRune(c)
+		case (c == ' ' || c == '\t') && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// splitOptions splits a comma-separated option list, keeping quoted values
+// (which may themselves contain commas) intact.
+func splitOptions(s string) []string {
+	var options []string
+	start, quoted := 0, false
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			options = append(options, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(options, s[start:])
+}
@@ -0,0 +1,171 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keyTypeEd25519 is the only key type supported.
+const keyTypeEd25519 = "ssh-ed25519"
+
+// opensshMagic starts every private key in the openssh-key-v1 format.
+const opensshMagic = "openssh-key-v1\x00"
+
+// LoadPrivateKey reads an unencrypted OpenSSH ed25519 private key. A path
+// to the ".pub" half is accepted too, in which case the private key next
+// to it is used.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	path = strings.TrimSuffix(path, ".pub")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	return ParsePrivateKey(data)
+}
+
+// ParsePrivateKey decodes a PEM-armored openssh-key-v1 ed25519 private key.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil, errors.New("not an OpenSSH private key")
+	}
+	r := &reader{data: block.Bytes}
+	if !bytes.HasPrefix(r.data, []byte(opensshMagic)) {
+		return nil, errors.New("not an OpenSSH private key")
+	}
+	r.data = r.data[len(opensshMagic):]
+
+	cipher := r.string()
+	kdf := r.string()
+	r.string() // KDF options
+	if r.err == nil && (string(cipher) != "none" || string(kdf) != "none") {
+		return nil, errors.New("encrypted private keys are not supported; remove the passphrase or use an unencrypted key")
+	}
+	if count := r.uint32(); r.err == nil && count != 1 {
+		return nil, fmt.Errorf("expected one key in file, found %d", count)
+	}
+	r.string() // Public key, repeated in the private section
+
+	private := &reader{data: r.string()}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed private key: %w", r.err)
+	}
+	if check1, check2 := private.uint32(), private.uint32(); check1 != check2 {
+		return nil, errors.New("malformed private key: check bytes differ")
+	}
+	keyType := private.string()
+	public := private.string()
+	secret := private.string()
+	if private.err != nil {
+		return nil, fmt.Errorf("malformed private key: %w", private.err)
+	}
+	if string(keyType) != keyTypeEd25519 {
+		return nil, fmt.Errorf("unsupported key type %s; only ed25519 keys can sign", keyType)
+	}
+	if len(public) != ed25519.PublicKeySize || len(secret) != ed25519.PrivateKeySize {
+		return nil, errors.New("malformed ed25519 private key")
+	}
+	return ed25519.PrivateKey(secret), nil
+}
+
+// ParsePublicKey decodes the authorized_keys form "ssh-ed25519 AAAA...".
+func ParsePublicKey(keyType, encoded string) (ed25519.PublicKey, error) {
+	if keyType != keyTypeEd25519 {
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+	wire, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed public key: %w", err)
+	}
+	return parseWirePublicKey(wire)
+}
+
+// parseWirePublicKey decodes a public key in SSH wire format.
+func parseWirePublicKey(wire []byte) (ed25519.PublicKey, error) {
+	r := &reader{data: wire}
+	keyType := r.string()
+	key := r.string()
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed public key: %w", r.err)
+	}
+	if string(keyType) != keyTypeEd25519 {
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("malformed ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// marshalPublicKey encodes a public key in SSH wire format.
+func marshalPublicKey(pub ed25519.PublicKey) []byte {
+	var w writer
+	w.string([]byte(keyTypeEd25519))
+	w.string(pub)
+	return w.bytes()
+}
+
+// Fingerprint returns the "SHA256:..." fingerprint OpenSSH shows for a key.
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(marshalPublicKey(pub))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// reader decodes the length-prefixed fields of the SSH wire format. The
+// first error sticks and later reads return zero values.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = errors.New("unexpected end of data")
+		return 0
+	}
+	v := uint32(r.data[0])<<24 | uint32(r.data[1])<<16 | uint32(r.data[2])<<8 | uint32(r.data[3])
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *reader) string() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.data)) < n {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	s := r.data[:n]
+	r.data = r.data[n:]
+	return s
+}
+
+// writer encodes SSH wire format fields.
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) uint32(v uint32) {
+	w.buf.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+func (w *writer) string(s []byte) {
+	w.uint32(uint32(len(s)))
+	w.buf.Write(s)
+}
+
+func (w *writer) bytes() []byte {
+	return w.buf.Bytes()
+}
@@ -0,0 +1,137 @@
+// Package sshsig creates and checks SSH signatures in OpenSSH's "sshsig"
+// format (see PROTOCOL.sshsig), which Git stores in commit `gpgsig` headers
+// and at the end of tag messages when signing with an SSH key.
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	magic         = "SSHSIG"
+	version       = 1
+	hashAlgorithm = "sha512"
+
+	// Namespace is the signature namespace Git uses for commits and tags.
+	Namespace = "git"
+
+	armorBegin = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd   = "-----END SSH SIGNATURE-----"
+	armorWidth = 70
+)
+
+// Sign signs message with an ed25519 key and returns the armored
+// signature, ending in a newline.
+func Sign(key ed25519.PrivateKey, namespace string, message []byte) string {
+	signature := ed25519.Sign(key, signedData(namespace, message))
+
+	var sigBlob writer
+	sigBlob.string([]byte(keyTypeEd25519))
+	sigBlob.string(signature)
+
+	var w writer
+	w.buf.WriteString(magic)
+	w.uint32(version)
+	w.string(marshalPublicKey(key.Public().(ed25519.PublicKey)))
+	w.string([]byte(namespace))
+	w.string(nil) // Reserved
+	w.string([]byte(hashAlgorithm))
+	w.string(sigBlob.bytes())
+
+	encoded := base64.StdEncoding.EncodeToString(w.bytes())
+	var out strings.Builder
+	out.WriteString(armorBegin + "\n")
+	for len(encoded) > armorWidth {
+		out.WriteString(encoded[:armorWidth] + "\n")
+		encoded = encoded[armorWidth:]
+	}
+	out.WriteString(encoded + "\n")
+	out.WriteString(armorEnd + "\n")
+	return out.String()
+}
+
+// Verify checks an armored signature over message and returns the public
+// key that made it. It does not decide whether that key is trusted.
+func Verify(armored, namespace string, message []byte) (ed25519.PublicKey, error) {
+	blob, err := unarmor(armored)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(blob, []byte(magic)) {
+		return nil, errors.New("not an SSH signature")
+	}
+	r := &reader{data: blob[len(magic):]}
+	sigVersion := r.uint32()
+	publicKey := r.string()
+	sigNamespace := r.string()
+	r.string() // Reserved
+	hashAlg := r.string()
+	sigBlob := r.string()
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed SSH signature: %w", r.err)
+	}
+	if sigVersion != version {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", sigVersion)
+	}
+	if string(sigNamespace) != namespace {
+		return nil, fmt.Errorf("signature namespace is %q, expected %q", sigNamespace, namespace)
+	}
+	if string(hashAlg) != hashAlgorithm {
+		return nil, fmt.Errorf("unsupported signature hash algorithm %s", hashAlg)
+	}
+
+	pub, err := parseWirePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	sr := &reader{data: sigBlob}
+	sigType := sr.string()
+	signature := sr.string()
+	if sr.err != nil || string(sigType) != keyTypeEd25519 {
+		return nil, errors.New("malformed SSH signature")
+	}
+
+	if !ed25519.Verify(pub, signedData(namespace, message), signature) {
+		return nil, errors.New("signature does not match the signed data")
+	}
+	return pub, nil
+}
+
+// signedData builds the blob that is actually signed: the message is hashed
+// and wrapped together with the namespace.
+func signedData(namespace string, message []byte) []byte {
+	digest := sha512.Sum512(message)
+	var w writer
+	w.buf.WriteString(magic)
+	w.string([]byte(namespace))
+	w.string(nil) // Reserved
+	w.string([]byte(hashAlgorithm))
+	w.string(digest[:])
+	return w.bytes()
+}
+
+// unarmor strips the BEGIN/END lines and decodes the base64 body.
+func unarmor(armored string) ([]byte, error) {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, armorBegin) || !strings.HasSuffix(armored, armorEnd) {
+		return nil, errors.New("not an armored SSH signature")
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(armored, armorBegin), armorEnd)
+	body = strings.Join(strings.Fields(body), "")
+	blob, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	return blob, nil
+}
+
+// IsArmored reports whether s starts like an armored SSH signature.
+func IsArmored(s string) bool {
+	return strings.HasPrefix(s, armorBegin)
+}
@@ -2,25 +2,73 @@ package staging
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"gopract/objects"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"time"
 )
 
+// Index file layout constants (see Git's index-format documentation).
+const (
+	indexSignature   = "DIRC"
+	indexVersion     = 2
+	entryFixedSize   = 62     // Stat fields, SHA-1 and flags
+	flagAssumeValid  = 0x8000 // Entry is assumed unchanged in the worktree
+	flagExtended     = 0x4000 // Version 3 extended flags follow
+	flagStageMask    = 0x3000 // Merge stage of the entry
+	flagStageShift   = 12
+	flagNameMask     = 0x0fff // Path length, saturated at 0xfff
+	extSkipWorktree  = 0x4000 // Extended flag: the path is not checked out
+	extIntentToAdd   = 0x2000 // Extended flag: the path is recorded by `add -N` only
+	extKnownMask     = extSkipWorktree | extIntentToAdd
+	modeRegularFile  = 0100644
+	modeExecutable   = 0100755
+	modeSymlink      = 0120000
+	indexChecksumLen = sha1.Size
+)
+
+// Index is the parsed contents of the `.git/index` file.
+type Index struct {
+	Version uint32       // Index format version
+	Entries []IndexEntry // Entries sorted by path, then stage
+}
+
 // IndexEntry represents a single entry in the staging area.
 type IndexEntry struct {
-	FilePath string // Relative path of the file
-	BlobHash string // SHA-1 hash of the blob
+	CTime        time.Time // Last metadata change of the file
+	MTime        time.Time // Last data change of the file
+	Dev          uint32    // Device containing the file
+	Ino          uint32    // Inode number
+	Mode         uint32    // Object type and Unix permissions (e.g., 0100644)
+	UID          uint32    // Owner user ID
+	GID          uint32    // Owner group ID
+	Size         uint32    // File size, truncated to 32 bits
+	BlobHash     string    // SHA-1 hash of the blob
+	Stage        int       // Merge stage (0 for normal entries)
+	AssumeValid  bool      // Skip worktree checks for this entry
+	SkipWorktree bool      // Path is left out of the worktree (needs version 3)
+	IntentToAdd  bool      // Placeholder recorded by `add -N` (needs version 3)
+	FilePath     string    // Path relative to the worktree, using forward slashes
+
+	racy bool // Not older than the index it was read from, so its stat data is not trusted
 }
 
 // ReadIndex reads the contents of the `.git/index` file.
-func ReadIndex(repoPath string) (map[string]string, error) {
+func ReadIndex(repoPath string) (*Index, error) {
 	indexPath := filepath.Join(repoPath, ".git", "index")
-	index := make(map[string]string)
+	index := &Index{Version: indexVersion}
 
 	// Check if the index file exists
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+	info, err := os.Stat(indexPath)
+	if os.IsNotExist(err) {
 		return index, nil // Return an empty index if the file doesn't exist
 	}
 
@@ -30,28 +78,58 @@ func ReadIndex(repoPath string) (map[string]string, error) {
 		return nil, fmt.Errorf("failed to read index file: %w", err)
 	}
 
-	// Decode the index file
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	if err := decoder.Decode(&index); err != nil {
+	// Indexes written before the binary format are gob-encoded maps
+	if !bytes.HasPrefix(data, []byte(indexSignature)) {
+		return migrateLegacyIndex(repoPath, data)
+	}
+
+	if err := index.decode(data); err != nil {
 		return nil, fmt.Errorf("failed to decode index file: %w", err)
 	}
 
+	// A file changed in the same timestamp tick as the index was written
+	// can keep the stat data recorded for it, so such entries are checked
+	// by content (see Git's racy-git documentation)
+	if info != nil {
+		for i := range index.Entries {
+			e := &index.Entries[i]
+			e.racy = !e.MTime.IsZero() && !e.MTime.Before(info.ModTime())
+		}
+	}
 	return index, nil
 }
 
 // WriteIndex writes the given index to the `.git/index` file.
-func WriteIndex(repoPath string, index map[string]string) error {
+func WriteIndex(repoPath string, index *Index) error {
 	indexPath := filepath.Join(repoPath, ".git", "index")
+	lockPath := indexPath + ".lock"
 
-	// Encode the index into bytes
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(index); err != nil {
-		return fmt.Errorf("failed to encode index: %w", err)
+	data, err := index.encode()
+	if err != nil {
+		return err
 	}
 
-	// Write the encoded index to the file
-	if err := os.WriteFile(indexPath, buffer.Bytes(), 0644); err != nil {
+	// Take the index lock so concurrent writers fail instead of racing
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("index is locked by another process: %s exists", lockPath)
+		}
+		return fmt.Errorf("failed to lock index: %w", err)
+	}
+
+	if _, err := lock.Write(data); err != nil {
+		lock.Close()
+		os.Remove(lockPath)
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	if err := lock.Close(); err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+
+	if err := os.Rename(lockPath, indexPath); err != nil {
+		os.Remove(lockPath)
 		return fmt.Errorf("failed to write index file: %w", err)
 	}
 
@@ -66,8 +144,14 @@ func UpdateIndex(repoPath, filePath, blobHash string) error {
 		return fmt.Errorf("failed to read index: %w", err)
 	}
 
-	// Update the index
-	index[filePath] = blobHash
+	// Capture the file's stat data alongside the blob hash
+	info, err := os.Lstat(filepath.Join(repoPath, filePath))
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	if err := index.Add(NewEntry(filePath, blobHash, info)); err != nil {
+		return err
+	}
 
 	// Write the updated index back to the file
 	if err := WriteIndex(repoPath, index); err != nil {
@@ -76,3 +160,280 @@ func UpdateIndex(repoPath, filePath, blobHash string) error {
 
 	return nil
 }
+
+// NewEntry builds a stage 0 index entry for a file from its stat data.
+func NewEntry(filePath, blobHash string, info os.FileInfo) IndexEntry {
+	entry := IndexEntry{
+		MTime:    info.ModTime(),
+		CTime:    info.ModTime(),
+		Mode:     ModeFor(info, 0, true),
+		Size:     uint32(info.Size()),
+		BlobHash: blobHash,
+		FilePath: filepath.ToSlash(filepath.Clean(filePath)),
+	}
+	fillStat(&entry, info)
+	return entry
+}
+
+// Add inserts an entry, replacing any existing entries for the same path.
+// Paths that could not be checked out safely are refused.
+func (idx *Index) Add(entry IndexEntry) error {
+	if err := objects.CheckPath(entry.FilePath); err != nil {
+		return err
+	}
+	lo, hi := idx.span(entry.FilePath)
+	idx.Entries = slices.Replace(idx.Entries, lo, hi, entry)
+	return nil
+}
+
+// AddConflict replaces every entry for a path with its conflicted stages
+// (1 for the common ancestor, 2 for ours, 3 for theirs).
+func (idx *Index) AddConflict(stages ...IndexEntry) error {
+	if len(stages) == 0 {
+		return nil
+	}
+	if err := objects.CheckPath(stages[0].FilePath); err != nil {
+		return err
+	}
+	stages = slices.Clone(stages)
+	sort.SliceStable(stages, func(i, j int) bool { return stages[i].Stage < stages[j].Stage })
+	lo, hi := idx.span(stages[0].FilePath)
+	idx.Entries = slices.Replace(idx.Entries, lo, hi, stages...)
+	return nil
+}
+
+// Remove deletes every entry, at any stage, for a path. It reports whether
+// anything was removed.
+func (idx *Index) Remove(filePath string) bool {
+	lo, hi := idx.span(filePath)
+	idx.Entries = slices.Delete(idx.Entries, lo, hi)
+	return hi > lo
+}
+
+// Entry returns the stage 0 entry for a path.
+func (idx *Index) Entry(filePath string) (*IndexEntry, bool) {
+	lo, hi := idx.span(filePath)
+	for i := lo; i < hi; i++ {
+		if idx.Entries[i].Stage == 0 {
+			return &idx.Entries[i], true
+		}
+	}
+	return nil, false
+}
+
+// span returns the range of entries, at any stage, for a path. Since the
+// entries are kept sorted it is found by binary search, so that adding
+// every file of a large tree one by one stays fast.
+func (idx *Index) span(filePath string) (int, int) {
+	lo := sort.Search(len(idx.Entries), func(i int) bool { return idx.Entries[i].FilePath >= filePath })
+	hi := lo
+	for hi < len(idx.Entries) && idx.Entries[hi].FilePath == filePath {
+		hi++
+	}
+	return lo, hi
+}
+
+// sort orders entries by path and then stage, as the format requires.
+func (idx *Index) sort() {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		a, b := idx.Entries[i], idx.Entries[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		return a.Stage < b.Stage
+	})
+}
+
+// decode parses a version 2 or 3 binary index.
+func (idx *Index) decode(data []byte) error {
+	if len(data) < 12+indexChecksumLen {
+		return errors.New("index file is truncated")
+	}
+
+	body, sum := data[:len(data)-indexChecksumLen], data[len(data)-indexChecksumLen:]
+	if actual := sha1.Sum(body); !bytes.Equal(actual[:], sum) {
+		return errors.New("index checksum mismatch")
+	}
+
+	idx.Version = binary.BigEndian.Uint32(body[4:8])
+	if idx.Version != 2 && idx.Version != 3 {
+		return fmt.Errorf("unsupported index version %d", idx.Version)
+	}
+	count := binary.BigEndian.Uint32(body[8:12])
+
+	// The count comes from the file; every entry takes at least the fixed
+	// part, so more than that cannot fit and is not preallocated
+	pos := 12
+	if limit := uint32((len(body) - pos) / entryFixedSize); count > limit {
+		return fmt.Errorf("index claims %d entries but can hold at most %d", count, limit)
+	}
+	idx.Entries = make([]IndexEntry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		if pos+entryFixedSize > len(body) {
+			return errors.New("index entry is truncated")
+		}
+		raw := body[pos:]
+
+		u32 := func(off int) uint32 { return binary.BigEndian.Uint32(raw[off:]) }
+		entry := IndexEntry{
+			CTime:    time.Unix(int64(u32(0)), int64(u32(4))),
+			MTime:    time.Unix(int64(u32(8)), int64(u32(12))),
+			Dev:      u32(16),
+			Ino:      u32(20),
+			Mode:     u32(24),
+			UID:      u32(28),
+			GID:      u32(32),
+			Size:     u32(36),
+			BlobHash: hex.EncodeToString(raw[40:60]),
+		}
+		flags := binary.BigEndian.Uint16(raw[60:62])
+		entry.AssumeValid = flags&flagAssumeValid != 0
+		entry.Stage = int(flags&flagStageMask) >> flagStageShift
+
+		headerLen := entryFixedSize
+		if flags&flagExtended != 0 {
+			if idx.Version < 3 || len(raw) < entryFixedSize+2 {
+				return errors.New("index entry has unexpected extended flags")
+			}
+			ext := binary.BigEndian.Uint16(raw[62:64])
+			if ext&^extKnownMask != 0 {
+				return fmt.Errorf("unknown extended index flags %#04x", ext)
+			}
+			entry.SkipWorktree = ext&extSkipWorktree != 0
+			entry.IntentToAdd = ext&extIntentToAdd != 0
+			headerLen += 2
+		}
+
+		nameEnd := bytes.IndexByte(raw[headerLen:], 0)
+		if nameEnd < 0 {
+			return errors.New("index entry path is not terminated")
+		}
+		entry.FilePath = string(raw[headerLen : headerLen+nameEnd])
+
+		// Entries are NUL-padded to a multiple of eight bytes
+		pos += (headerLen + nameEnd + 8) &^ 7
+		idx.Entries = append(idx.Entries, entry)
+	}
+
+	// Extensions (cached trees, resolve-undo, ...) are skipped; they are
+	// optional and get rebuilt by tools that want them.
+	return nil
+}
+
+// encode renders the index in the version 2 binary format, or version 3 if
+// an entry has extended flags.
+func (idx *Index) encode() ([]byte, error) {
+	idx.sort()
+
+	version := uint32(indexVersion)
+	for _, e := range idx.Entries {
+		if e.extendedFlags() != 0 {
+			version = 3
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(indexSignature)
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	for _, e := range idx.Entries {
+		start := buf.Len()
+
+		ctimeSec, ctimeNsec := timeFields(e.CTime)
+		mtimeSec, mtimeNsec := timeFields(e.MTime)
+		size := e.Size
+		if e.racy {
+			// Its stat data may hide a change, so make sure it never
+			// matches again and the file is hashed instead
+			size = 0
+		}
+		for _, v := range []uint32{
+			ctimeSec, ctimeNsec, mtimeSec, mtimeNsec,
+			e.Dev, e.Ino, e.Mode, e.UID, e.GID, size,
+		} {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+
+		hash, err := hex.DecodeString(e.BlobHash)
+		if err != nil || len(hash) != sha1.Size {
+			return nil, fmt.Errorf("invalid object name %q for index entry %s", e.BlobHash, e.FilePath)
+		}
+		buf.Write(hash)
+
+		flags := uint16(len(e.FilePath))
+		if len(e.FilePath) > flagNameMask {
+			flags = flagNameMask
+		}
+		flags |= uint16(e.Stage<<flagStageShift) & flagStageMask
+		if e.AssumeValid {
+			flags |= flagAssumeValid
+		}
+		ext := e.extendedFlags()
+		if ext != 0 {
+			flags |= flagExtended
+		}
+		binary.Write(&buf, binary.BigEndian, flags)
+		if ext != 0 {
+			binary.Write(&buf, binary.BigEndian, ext)
+		}
+
+		buf.WriteString(e.FilePath)
+		padding := 8 - (buf.Len()-start)%8
+		buf.Write(make([]byte, padding))
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes(), nil
+}
+
+// extendedFlags returns the version 3 flags of an entry.
+func (e IndexEntry) extendedFlags() uint16 {
+	var ext uint16
+	if e.SkipWorktree {
+		ext |= extSkipWorktree
+	}
+	if e.IntentToAdd {
+		ext |= extIntentToAdd
+	}
+	return ext
+}
+
+// timeFields splits a timestamp into the 32-bit seconds and nanoseconds
+// stored in an index entry; the zero time is stored as zero.
+func timeFields(t time.Time) (uint32, uint32) {
+	if t.IsZero() {
+		return 0, 0
+	}
+	return uint32(t.Unix()), uint32(t.Nanosecond())
+}
+
+// migrateLegacyIndex converts a gob-encoded index from older versions of
+// this tool and rewrites it in the binary format.
+func migrateLegacyIndex(repoPath string, data []byte) (*Index, error) {
+	legacy := make(map[string]string)
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&legacy); err != nil {
+		return nil, fmt.Errorf("failed to decode index file: %w", err)
+	}
+
+	index := &Index{Version: indexVersion}
+	for filePath, blobHash := range legacy {
+		entry := IndexEntry{
+			Mode:     modeRegularFile,
+			BlobHash: blobHash,
+			FilePath: filepath.ToSlash(filepath.Clean(filePath)),
+		}
+		// Stat data is left zeroed so every migrated entry gets re-checked
+		// against the worktree the next time it is compared.
+		index.Entries = append(index.Entries, entry)
+	}
+	index.sort()
+
+	if err := WriteIndex(repoPath, index); err != nil {
+		return nil, fmt.Errorf("failed to migrate index: %w", err)
+	}
+	return index, nil
+}
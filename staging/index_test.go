@@ -0,0 +1,154 @@
+package staging
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexExtendedFlagsRoundTrip(t *testing.T) {
+	const emptyBlob = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	tests := []struct {
+		name    string
+		entries []IndexEntry
+		version uint32
+	}{
+		{
+			name:    "no extended flags",
+			entries: []IndexEntry{{Mode: modeRegularFile, BlobHash: emptyBlob, FilePath: "a"}},
+			version: 2,
+		},
+		{
+			name: "intent to add and skip-worktree",
+			entries: []IndexEntry{
+				{Mode: modeRegularFile, BlobHash: emptyBlob, FilePath: "a"},
+				{Mode: modeRegularFile, BlobHash: emptyBlob, IntentToAdd: true, FilePath: "n"},
+				{Mode: modeRegularFile, BlobHash: emptyBlob, SkipWorktree: true, FilePath: "s"},
+			},
+			version: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := (&Index{Entries: tt.entries}).encode()
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if version := binary.BigEndian.Uint32(data[4:8]); version != tt.version {
+				t.Errorf("written as version %d, want %d", version, tt.version)
+			}
+
+			got := &Index{}
+			if err := got.decode(data); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(got.Entries) != len(tt.entries) {
+				t.Fatalf("got %d entries, want %d", len(got.Entries), len(tt.entries))
+			}
+			for i, want := range tt.entries {
+				e := got.Entries[i]
+				if e.FilePath != want.FilePath || e.IntentToAdd != want.IntentToAdd || e.SkipWorktree != want.SkipWorktree {
+					t.Errorf("entry %d = %s (intent to add %v, skip-worktree %v), want %s (%v, %v)",
+						i, e.FilePath, e.IntentToAdd, e.SkipWorktree, want.FilePath, want.IntentToAdd, want.SkipWorktree)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexEncodeInvalidHash(t *testing.T) {
+	for _, hash := range []string{"", "e69de29b", "zz9de29bb2d1d6434b8b29ae775ad8c2e48c5391"} {
+		index := &Index{Entries: []IndexEntry{{Mode: modeRegularFile, BlobHash: hash, FilePath: "a"}}}
+		if _, err := index.encode(); err == nil {
+			t.Errorf("entry with hash %q was encoded", hash)
+		}
+	}
+}
+
+func TestIndexDecodeHugeCount(t *testing.T) {
+	data, err := (&Index{}).encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	body := data[:len(data)-sha1.Size]
+	binary.BigEndian.PutUint32(body[8:12], 0xffffffff)
+	sum := sha1.Sum(body)
+	data = append(body, sum[:]...)
+
+	if err := (&Index{}).decode(data); err == nil {
+		t.Fatal("index with an impossible entry count was accepted")
+	}
+}
+
+func TestRacilyCleanEntries(t *testing.T) {
+	const emptyBlob = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(repo, "f")
+	if err := os.WriteFile(file, []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := &Index{}
+	if err := index.Add(NewEntry("f", emptyBlob, info)); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(repo, index); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file changes, keeping its size, within the tick the index was written in
+	indexInfo, err := os.Stat(filepath.Join(repo, ".git", "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tick := indexInfo.ModTime()
+	if err := os.Chtimes(file, tick, tick); err != nil {
+		t.Fatal(err)
+	}
+	index.Entries[0].MTime = tick
+	if err := WriteIndex(repo, index); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(repo, ".git", "index"), tick, tick); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err = os.Lstat(file); err != nil {
+		t.Fatal(err)
+	}
+	if read.Entries[0].StatMatches(info) {
+		t.Fatal("entry as new as the index was trusted by its stat data")
+	}
+
+	// Written again later, the entry must still not match by stat data
+	if err := WriteIndex(repo, read); err != nil {
+		t.Fatal(err)
+	}
+	later := tick.Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(repo, ".git", "index"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if read, err = ReadIndex(repo); err != nil {
+		t.Fatal(err)
+	}
+	if read.Entries[0].StatMatches(info) {
+		t.Fatal("racy entry matched by stat data after the index was rewritten")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package staging
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fillStat copies the platform-specific stat fields into an index entry.
+func fillStat(entry *IndexEntry, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	entry.CTime = time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+	entry.Dev = uint32(st.Dev)
+	entry.Ino = uint32(st.Ino)
+	entry.UID = st.Uid
+	entry.GID = st.Gid
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package staging
+
+import "os"
+
+// fillStat is a no-op where only the portable stat fields are available;
+// ctime falls back to the modification time set by NewEntry.
+func fillStat(entry *IndexEntry, info os.FileInfo) {}
@@ -0,0 +1,100 @@
+package staging
+
+import (
+	"fmt"
+	"gopract/objects"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// treeNode is a directory in the tree being built from the index.
+type treeNode struct {
+	files map[string]IndexEntry // File name -> index entry
+	dirs  map[string]*treeNode  // Subdirectory name -> node
+}
+
+func newTreeNode() *treeNode {
+	return &treeNode{files: make(map[string]IndexEntry), dirs: make(map[string]*treeNode)}
+}
+
+// WriteTree writes one tree object per directory in the index and returns
+// the hash of the root tree.
+func WriteTree(repoPath string, index *Index) (string, error) {
+	root := newTreeNode()
+
+	for _, entry := range index.Entries {
+		if entry.Stage != 0 {
+			return "", fmt.Errorf("cannot write a tree with unmerged path %s", entry.FilePath)
+		}
+		if entry.IntentToAdd {
+			// Like Git, leave out paths that are only marked for adding
+			continue
+		}
+
+		filePath := entry.FilePath
+		clean := path.Clean(filepath.ToSlash(filePath))
+		parts := strings.Split(clean, "/")
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", fmt.Errorf("invalid path in index: %s", filePath)
+		}
+
+		node := root
+		for _, dir := range parts[:len(parts)-1] {
+			child, ok := node.dirs[dir]
+			if !ok {
+				child = newTreeNode()
+				node.dirs[dir] = child
+			}
+			node = child
+		}
+
+		name := parts[len(parts)-1]
+		if _, ok := node.dirs[name]; ok {
+			return "", fmt.Errorf("path %s is both a file and a directory in the index", clean)
+		}
+		node.files[name] = entry
+	}
+
+	return writeTreeNode(repoPath, root, "")
+}
+
+// writeTreeNode writes the subtrees of node first, then node itself. Trees
+// that already exist are not written again.
+func writeTreeNode(repoPath string, node *treeNode, prefix string) (string, error) {
+	tree := &objects.Tree{}
+
+	for name, child := range node.dirs {
+		if _, ok := node.files[name]; ok {
+			return "", fmt.Errorf("path %s is both a file and a directory in the index", path.Join(prefix, name))
+		}
+		hash, err := writeTreeNode(repoPath, child, path.Join(prefix, name))
+		if err != nil {
+			return "", err
+		}
+		tree.Entries = append(tree.Entries, objects.TreeEntry{Mode: objects.ModeTree, Hash: hash, Name: name})
+	}
+
+	for name, entry := range node.files {
+		mode := fmt.Sprintf("%o", entry.Mode)
+		tree.Entries = append(tree.Entries, objects.TreeEntry{Mode: mode, Hash: entry.BlobHash, Name: name})
+	}
+
+	tree.Sort()
+
+	data, err := tree.Serialize()
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize tree %s: %w", prefix, err)
+	}
+
+	// Most subtrees are unchanged since the last commit and already stored,
+	// loose or packed
+	hash := objects.HashRaw(tree.Type(), data)
+	if objects.HasObject(repoPath, hash) {
+		return hash, nil
+	}
+	if _, err := objects.WriteRawObject(repoPath, tree.Type(), data); err != nil {
+		return "", fmt.Errorf("failed to write tree %s: %w", prefix, err)
+	}
+	return hash, nil
+}
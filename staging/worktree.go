@@ -0,0 +1,99 @@
+package staging
+
+import (
+	"fmt"
+	"gopract/config"
+	"gopract/objects"
+	"os"
+	"path/filepath"
+)
+
+// StatMatches reports whether a file's stat data is unchanged since the entry
+// was recorded, in which case its contents need not be re-hashed. Racily
+// clean entries never match.
+func (e IndexEntry) StatMatches(info os.FileInfo) bool {
+	if e.AssumeValid || e.SkipWorktree {
+		return true
+	}
+	if e.racy {
+		return false
+	}
+	if e.MTime.IsZero() || e.MTime.Unix() == 0 {
+		return false
+	}
+	return e.Size == uint32(info.Size()) && e.MTime.Equal(info.ModTime())
+}
+
+// TrustFileMode reports whether the executable bit of worktree files is
+// meaningful, which it is unless core.filemode is set to false (e.g. on
+// filesystems that mark every file executable).
+func TrustFileMode(repoPath string) bool {
+	_, set := config.ReadValue(repoPath, "core", "filemode")
+	return !set || config.ReadBool(repoPath, "core", "filemode")
+}
+
+// ModeFor returns the index mode of a worktree file: a symlink, an
+// executable or a regular file. When the executable bit cannot be trusted
+// the file keeps the previous mode of its entry, if it was a regular file.
+// Anything else, such as a directory, yields 0.
+func ModeFor(info os.FileInfo, previous uint32, trustFileMode bool) uint32 {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return modeSymlink
+	case !info.Mode().IsRegular():
+		return 0
+	case !trustFileMode && (previous == modeRegularFile || previous == modeExecutable):
+		return previous
+	case trustFileMode && info.Mode()&0100 != 0:
+		return modeExecutable
+	}
+	return modeRegularFile
+}
+
+// ReadFile returns the blob contents of a worktree file: the data of a
+// regular file or the target of a symlink.
+func ReadFile(repoPath, filePath string) ([]byte, error) {
+	fullPath := filepath.Join(repoPath, filepath.FromSlash(filePath))
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read symlink %s: %w", filePath, err)
+		}
+		return []byte(filepath.ToSlash(target)), nil
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	return data, nil
+}
+
+// HashFile computes the blob hash of a worktree file without storing it.
+func HashFile(repoPath, filePath string) (string, error) {
+	data, err := ReadFile(repoPath, filePath)
+	if err != nil {
+		return "", err
+	}
+	return objects.Hash(&objects.Blob{Data: data})
+}
+
+// FileMatches reports whether the worktree file described by info still has
+// the contents and mode recorded in the entry, re-hashing it only when the
+// stat data has changed.
+func FileMatches(repoPath string, entry IndexEntry, info os.FileInfo, trustFileMode bool) (bool, error) {
+	if ModeFor(info, entry.Mode, trustFileMode) != entry.Mode {
+		return false, nil
+	}
+	if entry.StatMatches(info) {
+		return true, nil
+	}
+	hash, err := HashFile(repoPath, entry.FilePath)
+	if err != nil {
+		return false, err
+	}
+	return hash == entry.BlobHash, nil
+}